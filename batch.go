@@ -0,0 +1,195 @@
+package wuw
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+)
+
+// batchDirs are the dirs given to 'wuw batch', scanned by the commands of its
+// script that name none of their own.
+var batchDirs []string
+
+// scanCache is set while running a batch, so that every dir is read once
+// however many of its commands scan it.
+var scanCache *ScanCache
+
+// ScanCache keeps the packages and errors found in each dir scanned.
+type ScanCache struct {
+	dirs map[string]*cachedDir
+}
+
+type cachedDir struct {
+	pkgs    []Package
+	errs    []error
+	skipped []SkippedDir
+}
+
+func NewScanCache() *ScanCache {
+	return &ScanCache{dirs: make(map[string]*cachedDir)}
+}
+
+// Scan returns what s would find in dirs, only reading those that weren't
+// scanned before. Packages are copies, for callers to change as they please.
+func (c *ScanCache) Scan(s *Scanner, dirs []string) ([]Package, []error) {
+	var pkgs []Package
+	var errs []error
+	for _, d := range dirs {
+		e, ok := c.dirs[d]
+		if !ok {
+			// scan with the std deps, which are only filtered out below,
+			// for them to be there when a later command wants them
+			inner := &Scanner{FS: s.FS, Now: s.Now, MaxOpen: s.MaxOpen, Timings: s.Timings, Coverage: &Coverage{}}
+			e = &cachedDir{}
+			e.pkgs, e.errs = inner.scan([]string{d})
+			e.skipped = inner.Coverage.Skipped
+			c.dirs[d] = e
+		}
+		if s.Coverage != nil {
+			s.Coverage.Skipped = append(s.Coverage.Skipped, e.skipped...)
+		}
+
+		for _, p := range e.pkgs {
+			p.Files = slices.Clone(p.Files)
+			p.Deps = FilterDependencies(p.Deps, s.NoStd)
+			p.Generated = slices.Clone(p.Generated)
+			p.Sources = slices.Clone(p.Sources)
+			p.Qualifiers = maps.Clone(p.Qualifiers)
+			p.Aliases = maps.Clone(p.Aliases)
+			pkgs = append(pkgs, p)
+		}
+		errs = append(errs, e.errs...)
+	}
+	return pkgs, errs
+}
+
+// SplitWords splits a line of a batch script into words at spaces, like a
+// shell would, keeping what is in single or double quotes together.
+func SplitWords(line string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	var quote rune
+	inWord := false
+	for _, r := range line {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			word.WriteRune(r)
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// BatchLine is a command of a batch script, and where in it it was.
+type BatchLine struct {
+	Line int
+	Args []string
+}
+
+// ReadBatch reads the commands of the batch script at path, one per line.
+// Blank lines and those starting with # are left out.
+func ReadBatch(path string) ([]BatchLine, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var ret []BatchLine
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		args, err := SplitWords(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+		ret = append(ret, BatchLine{Line: n, Args: args})
+	}
+	return ret, scanner.Err()
+}
+
+func RunBatch(args []string) {
+	fs := flag.NewFlagSet("batch", flag.ExitOnError)
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintln(w, "'wuw batch' runs the commands of a script, one per line as they would follow 'wuw' on the command line, reading each dir only once for all of them. Commands that name no dirs scan the dirs given to batch. The batch stops at the first command that fails.")
+		fmt.Fprintf(w, "Usage: %s batch [-opts] script [dirs...]\nopts:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+
+	verboseVar := fs.Bool("v", false, "Print each command to stderr before running it")
+
+	ParseFlags(fs, args)
+
+	if fs.NArg() < 1 {
+		fmt.Fprintln(os.Stderr, "Need the script to run. Displaying usage...")
+		fs.Usage()
+		os.Exit(1)
+	}
+	script := fs.Arg(0)
+	lines, err := ReadBatch(script)
+	if err != nil {
+		Fatal(err)
+	}
+
+	batchDirs = fs.Args()[1:]
+	scanCache = NewScanCache()
+
+	global := saveGlobalFlags()
+	defer global.restore()
+	for _, l := range lines {
+		global.restore()
+		if *verboseVar {
+			fmt.Fprintf(os.Stderr, "%s:%d: wuw %s\n", script, l.Line, strings.Join(l.Args, " "))
+		}
+		if l.Args[0] == "batch" {
+			Fatal(fmt.Errorf("%s:%d: batches can't be nested", script, l.Line))
+		}
+		if !RunCommand(l.Args[0], l.Args[1:]) {
+			RunGraph(flag.NewFlagSet("wuw", flag.ExitOnError), l.Args)
+		}
+	}
+}
+
+// globalFlags are the settings of the flags every command takes, which are
+// kept in globals that one command of a batch setting them would otherwise
+// leave set for the rest.
+type globalFlags struct {
+	profile      string
+	noClassCache bool
+	skipDirs     []string
+	daemonSocket string
+}
+
+func saveGlobalFlags() globalFlags {
+	return globalFlags{profile: profile, noClassCache: noClassCache, skipDirs: slices.Clone(skipDirs), daemonSocket: daemonSocket}
+}
+
+func (g globalFlags) restore() {
+	profile, noClassCache, skipDirs, daemonSocket = g.profile, g.noClassCache, slices.Clone(g.skipDirs), g.daemonSocket
+}
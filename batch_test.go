@@ -0,0 +1,45 @@
+package wuw
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestBatchFlags checks that the flags every command takes only apply to
+// the command of a batch they are given to, not to those after it.
+func TestBatchFlags(t *testing.T) {
+	tmp := t.TempDir()
+	first, second := filepath.Join(tmp, "first.txt"), filepath.Join(tmp, "second.txt")
+	script := filepath.Join(tmp, "script")
+	lines := "-skip-dirs store,web -no-class-cache -o " + first + " ./...\n" +
+		"-o " + second + " ./...\n"
+	if err := os.WriteFile(script, []byte(lines), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(filepath.Join("testdata", "fixture"))
+
+	before := saveGlobalFlags()
+	RunBatch([]string{script})
+	if after := saveGlobalFlags(); after.noClassCache != before.noClassCache || !slices.Equal(after.skipDirs, before.skipDirs) {
+		t.Errorf("the flags of the batch were left set: %+v, want %+v", after, before)
+	}
+
+	for _, tt := range []struct {
+		path string
+		want bool
+	}{
+		{first, false},
+		{second, true},
+	} {
+		out, err := os.ReadFile(tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Contains("\n"+string(out), "\nstore:\n"); got != tt.want {
+			t.Errorf("%s lists store: %v, want %v:\n%s", filepath.Base(tt.path), got, tt.want, out)
+		}
+	}
+}
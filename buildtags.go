@@ -0,0 +1,141 @@
+package wuw
+
+import (
+	"bufio"
+	"flag"
+	"go/build"
+	"go/build/constraint"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// knownOS and knownArch are the values of GOOS and GOARCH that restrict a file
+// to a platform when they end its name, as in foo_linux_amd64.go.
+var (
+	knownOS = []string{
+		"aix", "android", "darwin", "dragonfly", "freebsd", "hurd", "illumos", "ios", "js",
+		"linux", "nacl", "netbsd", "openbsd", "plan9", "solaris", "wasip1", "windows", "zos",
+	}
+	knownArch = []string{
+		"386", "amd64", "amd64p32", "arm", "armbe", "arm64", "arm64be", "loong64", "mips",
+		"mipsle", "mips64", "mips64le", "mips64p32", "mips64p32le", "ppc", "ppc64", "ppc64le",
+		"riscv", "riscv64", "s390", "s390x", "sparc", "sparc64", "wasm",
+	}
+)
+
+// ReadConstraint returns the //go:build (or old // +build) expression of the
+// file at name, or nil when it has none.
+func ReadConstraint(fsys fs.FS, name string) constraint.Expr {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var plus []constraint.Expr
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "package ") {
+			break
+		}
+		if constraint.IsGoBuild(line) {
+			if x, err := constraint.Parse(line); err == nil {
+				return x
+			}
+		} else if constraint.IsPlusBuild(line) {
+			if x, err := constraint.Parse(line); err == nil {
+				plus = append(plus, x)
+			}
+		}
+	}
+
+	// multiple +build lines are ANDed together
+	var x constraint.Expr
+	for _, p := range plus {
+		if x == nil {
+			x = p
+		} else {
+			x = &constraint.AndExpr{X: x, Y: p}
+		}
+	}
+	return x
+}
+
+// PlatformSuffix returns the GOOS and GOARCH that the name of a Go file
+// restricts it to, if any, ignoring a _test suffix.
+func PlatformSuffix(name string) (goos, goarch string) {
+	name = strings.TrimSuffix(strings.TrimSuffix(filepath.Base(name), ".go"), "_test")
+	elems := strings.Split(name, "_")
+	if len(elems) < 2 {
+		return "", ""
+	}
+
+	last := elems[len(elems)-1]
+	if slices.Contains(knownArch, last) {
+		goarch = last
+		if len(elems) > 2 && slices.Contains(knownOS, elems[len(elems)-2]) {
+			goos = elems[len(elems)-2]
+		}
+		return goos, goarch
+	}
+	if slices.Contains(knownOS, last) {
+		return last, ""
+	}
+	return "", ""
+}
+
+// Constrained reports whether the file at name is only built on some
+// platforms or with some tags.
+func Constrained(fsys fs.FS, name string) bool {
+	goos, goarch := PlatformSuffix(name)
+	return goos != "" || goarch != "" || ReadConstraint(fsys, name) != nil
+}
+
+// scanBuild is set with -tags, for new scanners to only read the files of
+// that build configuration.
+var scanBuild *build.Context
+
+// TagsFlag adds -tags to fs, starting off without a build configuration so
+// that one set by an earlier command of a batch doesn't carry over.
+func TagsFlag(fs *flag.FlagSet) {
+	scanBuild = nil
+	fs.Var(tagsFlag{}, "tags", "Only scan the files that build with these comma-separated `tags`, for the GOOS and GOARCH of the environment like the go command, rather than every file; set to \"\" for no tags")
+}
+
+// tagsFlag is the flag.Value of -tags. Setting it at all, even to nothing,
+// sets scanBuild.
+type tagsFlag struct{}
+
+func (tagsFlag) String() string {
+	if scanBuild == nil {
+		return ""
+	}
+	return strings.Join(scanBuild.BuildTags, ",")
+}
+
+func (tagsFlag) Set(s string) error {
+	ctx := build.Default
+	ctx.BuildTags = nil
+	for _, t := range strings.Split(s, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			ctx.BuildTags = append(ctx.BuildTags, t)
+		}
+	}
+	scanBuild = &ctx
+	return nil
+}
+
+// BuildFiles returns the files of dir, read from fsys, that ctx builds,
+// going by their //go:build lines and GOOS and GOARCH suffixes.
+func BuildFiles(ctx *build.Context, fsys fs.FS, dir string, files []string) []string {
+	c := *ctx
+	c.OpenFile = func(path string) (io.ReadCloser, error) { return fsys.Open(path) }
+	return slices.DeleteFunc(slices.Clone(files), func(f string) bool {
+		ok, err := c.MatchFile(dir, filepath.Base(f))
+		return err != nil || !ok
+	})
+}
@@ -0,0 +1,178 @@
+package wuw
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+)
+
+// Capability is something a package can do beyond computing in-process,
+// known by the packages that have to be imported for it. Together they are
+// the capability surface security reviews ask about.
+type Capability struct {
+	Name        string
+	Description string
+	Imports     []string
+}
+
+var capabilities = []*Capability{
+	{"plugin", "Loads Go plugins at runtime", []string{"plugin"}},
+	{"exec", "Runs external programs", []string{"os/exec"}},
+	{"syscall", "Makes system calls directly", []string{"syscall", "golang.org/x/sys/unix", "golang.org/x/sys/windows"}},
+	// net/http and the like are left out, only those handing out sockets
+	// and packets themselves count
+	{"net", "Opens raw network connections", []string{"net", "golang.org/x/net/icmp", "golang.org/x/net/ipv4", "golang.org/x/net/ipv6", "golang.org/x/net/bpf"}},
+	{"cgo", "Calls C code through cgo", []string{"C"}},
+}
+
+// CapabilityUse is a package importing one of the packages of a capability.
+type CapabilityUse struct {
+	Package string
+	Import  string
+	Site    *ImportSite
+	// Binaries are the main packages linking the package in.
+	Binaries []string
+}
+
+// CapabilityUses returns, for each capability, the internal packages that
+// import it directly, and which main packages include them.
+func CapabilityUses(g *Graph) map[*Capability][]*CapabilityUse {
+	reach := make(map[string][]string)
+	for _, p := range g.Order {
+		if g.Pkgs[p].Name == "main" {
+			reach[p] = g.Reachable(p)
+		}
+	}
+
+	ret := make(map[*Capability][]*CapabilityUse)
+	for _, p := range g.Order {
+		pkg := g.Pkgs[p]
+		// "C" is kept out of the deps, cgo being marked on the package
+		deps := pkg.Deps
+		if pkg.Cgo {
+			deps = append(slices.Clip(deps), "C")
+		}
+		var sites []ImportSite
+		for _, c := range capabilities {
+			for _, d := range deps {
+				if !slices.Contains(c.Imports, d) {
+					continue
+				}
+				if sites == nil {
+					sites = ImportSites(pkg)
+				}
+				u := &CapabilityUse{Package: p, Import: d}
+				if i := slices.IndexFunc(sites, func(s ImportSite) bool { return s.Path == d }); i != -1 {
+					u.Site = &sites[i]
+				}
+				for _, m := range g.Order {
+					if slices.Contains(reach[m], p) {
+						u.Binaries = append(u.Binaries, m)
+					}
+				}
+				ret[c] = append(ret[c], u)
+			}
+		}
+	}
+	return ret
+}
+
+// WriteCapabilities lists the packages using each capability, with where
+// they import it and the binaries including them.
+func WriteCapabilities(w io.Writer, uses map[*Capability][]*CapabilityUse) {
+	for _, c := range capabilities {
+		if len(uses[c]) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "%s: %s\n", c.Name, c.Description)
+		for _, u := range uses[c] {
+			fmt.Fprintf(w, "\t%s imports %s", u.Package, u.Import)
+			if u.Site != nil {
+				fmt.Fprintf(w, " at %s:%d:%d", u.Site.File, u.Site.Line, u.Site.Col)
+			}
+			fmt.Fprintln(w)
+			for _, b := range u.Binaries {
+				fmt.Fprintf(w, "\t\tin %s\n", b)
+			}
+		}
+	}
+}
+
+// WriteCapabilitiesMarkdown writes the capability surface as Markdown, a
+// summary table answering whether each capability is used at all followed
+// by a section for each used one, to paste into security questionnaires.
+func WriteCapabilitiesMarkdown(w io.Writer, uses map[*Capability][]*CapabilityUse) {
+	fmt.Fprintln(w, "# Capability surface")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "| capability | used | packages | binaries |")
+	fmt.Fprintln(w, "| --- | --- | --- | --- |")
+	for _, c := range capabilities {
+		var binaries []string
+		for _, u := range uses[c] {
+			for _, b := range u.Binaries {
+				if !slices.Contains(binaries, b) {
+					binaries = append(binaries, b)
+				}
+			}
+		}
+		used := "no"
+		if len(uses[c]) != 0 {
+			used = "yes"
+		}
+		fmt.Fprintf(w, "| %s (%s) | %s | %d | %d |\n", c.Description, markdownCode(c.Imports), used, len(uses[c]), len(binaries))
+	}
+
+	for _, c := range capabilities {
+		if len(uses[c]) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n## %s\n\n", c.Description)
+		fmt.Fprintln(w, "| package | imports | where | binaries |")
+		fmt.Fprintln(w, "| --- | --- | --- | --- |")
+		for _, u := range uses[c] {
+			where := "-"
+			if u.Site != nil {
+				where = markdownCode([]string{fmt.Sprintf("%s:%d", u.Site.File, u.Site.Line)})
+			}
+			fmt.Fprintf(w, "| `%s` | `%s` | %s | %s |\n", markdownCell(u.Package), markdownCell(u.Import), where, orNone(markdownCode(u.Binaries)))
+		}
+	}
+}
+
+func RunCapabilities(args []string) {
+	fs := flag.NewFlagSet("capabilities", flag.ExitOnError)
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintln(w, "'wuw capabilities' reports the capability surface of the scanned packages: those importing plugin, os/exec, syscall, raw networking or cgo, where they do, and which binaries include them, for security reviews.")
+		fmt.Fprintf(w, "Usage: %s capabilities [-opts] [dirs...]\nopts:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+
+	formatVar := fs.String("format", "text", "Output format: text, or markdown for security questionnaires")
+	outVar := OutputFlag(fs)
+
+	ParseFlags(fs, args)
+
+	if *formatVar != "text" && *formatVar != "markdown" {
+		Fatal(fmt.Errorf("unknown format %q", *formatVar))
+	}
+
+	dirs := ReadArgs(fs.Args(), fs.Usage)
+	pkgs, errs := ScanDirs(dirs, false)
+
+	PrintErrors(errs)
+
+	g := NewGraph(pkgs)
+
+	w := OpenOutput(*outVar)
+	defer w.Close()
+
+	uses := CapabilityUses(g)
+	if *formatVar == "markdown" {
+		WriteCapabilitiesMarkdown(w, uses)
+		return
+	}
+	WriteCapabilities(w, uses)
+}
@@ -0,0 +1,288 @@
+package wuw
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing/fstest"
+	"time"
+)
+
+// refFS is the tree at a ref, as far as scanning it needs: its Go files and
+// go.mod files. It is a pointer to a map so it can key the module cache.
+type refFS struct {
+	fstest.MapFS
+}
+
+// RefFS returns the Go files of dirs at the ref of v, and the go.mod files
+// above them, for scans of the tree at a commit, or of what the next one holds
+// when ref is Index.
+func RefFS(v VCS, ref string, dirs []string) (fs.FS, error) {
+	fsys := &refFS{fstest.MapFS{}}
+	read := func(name string) error {
+		src, err := v.ReadFile(ref, name)
+		if err != nil {
+			return err
+		}
+		fsys.MapFS[filepath.ToSlash(name)] = &fstest.MapFile{Data: []byte(src)}
+		return nil
+	}
+
+	for _, d := range dirs {
+		files, err := v.ListFiles(ref, d)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			if filepath.Ext(f) != ".go" {
+				continue
+			}
+			if err := read(f); err != nil {
+				return nil, err
+			}
+		}
+
+		for dir := d; ; dir = filepath.Dir(dir) {
+			mod := filepath.Join(dir, "go.mod")
+			if _, ok := fsys.MapFS[filepath.ToSlash(mod)]; ok || read(mod) == nil || dir == "." {
+				break
+			}
+		}
+	}
+	return fsys, nil
+}
+
+// Snapshot is the tree at a ref, scanned.
+type Snapshot struct {
+	Ref     string
+	Graph   *Graph
+	Modules *ModuleVersions
+}
+
+// ScanRef scans the packages of the tree of v at ref, below the dir v was
+// opened at, skipping the dirs dir/... would.
+func ScanRef(v VCS, ref string, noStd bool) (*Snapshot, []error, error) {
+	files, err := v.Files(ref)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fsys := &refFS{fstest.MapFS{}}
+	mv := &ModuleVersions{Versions: make(map[string]string), Packages: make(map[string]string)}
+	var dirs []string
+	for _, f := range files {
+		name := filepath.ToSlash(f)
+		isGo := path.Ext(name) == ".go"
+		if !isGo && path.Base(name) != "go.mod" {
+			continue
+		}
+		dir := path.Dir(name)
+		if _, rule := skipPath(".", filepath.FromSlash(dir)); rule != "" {
+			continue
+		}
+		src, err := v.ReadFile(ref, f)
+		if err != nil {
+			return nil, nil, err
+		}
+		fsys.MapFS[name] = &fstest.MapFile{Data: []byte(src)}
+		if !isGo {
+			mv.parseGoMod(strings.NewReader(src))
+		} else if !slices.Contains(dirs, dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+	slices.Sort(dirs)
+
+	s := &Scanner{FS: fsys, Now: time.Now, NoStd: noStd, MaxOpen: DefaultMaxOpen}
+	pkgs, errs := s.Scan(dirs)
+	return &Snapshot{Ref: ref, Graph: NewGraph(pkgs), Modules: mv}, errs, nil
+}
+
+// externalImporters returns the internal packages importing each external
+// module of s.
+func (s *Snapshot) externalImporters() map[string][]string {
+	ret := make(map[string][]string)
+	for _, p := range s.Graph.Order {
+		for _, d := range s.Graph.Pkgs[p].Deps {
+			if s.Graph.Category(d) != CategoryExternal {
+				continue
+			}
+			m := s.Modules.ModuleOf(d)
+			if !slices.Contains(ret[m], p) {
+				ret[m] = append(ret[m], p)
+			}
+		}
+	}
+	return ret
+}
+
+// Changelog is what changed in the architecture between two refs, for
+// release notes.
+type Changelog struct {
+	From, To string
+
+	AddedPackages   []string
+	RemovedPackages []string
+	// AddedModules maps the external modules imported since From to their
+	// importers at To.
+	AddedModules   map[string][]string
+	RemovedModules []string
+	// CrossLayer are the imports between packages of different groups that
+	// are new since From, when there are groups.
+	CrossLayer []Edge
+}
+
+// NewChangelog compares the snapshots from and to, taking groups, if set,
+// as the layers imports may cross.
+func NewChangelog(from, to *Snapshot, groups *Groups) *Changelog {
+	c := &Changelog{From: from.Ref, To: to.Ref, AddedModules: make(map[string][]string)}
+	for _, p := range to.Graph.Order {
+		if !from.Graph.IsInternal(p) {
+			c.AddedPackages = append(c.AddedPackages, p)
+		}
+	}
+	for _, p := range from.Graph.Order {
+		if !to.Graph.IsInternal(p) {
+			c.RemovedPackages = append(c.RemovedPackages, p)
+		}
+	}
+
+	before, after := from.externalImporters(), to.externalImporters()
+	for m, importers := range after {
+		if _, ok := before[m]; !ok {
+			c.AddedModules[m] = importers
+		}
+	}
+	for m := range before {
+		if _, ok := after[m]; !ok {
+			c.RemovedModules = append(c.RemovedModules, m)
+		}
+	}
+	slices.Sort(c.RemovedModules)
+
+	if groups != nil {
+		for _, p := range to.Graph.Order {
+			for _, d := range to.Graph.InternalDeps(p) {
+				if groups.Of(p) == groups.Of(d) {
+					continue
+				}
+				if old, ok := from.Graph.Pkgs[p]; ok && slices.Contains(old.Deps, d) {
+					continue
+				}
+				c.CrossLayer = append(c.CrossLayer, Edge{From: p, To: d})
+			}
+		}
+	}
+	return c
+}
+
+// WriteChangelog writes c as Markdown, leaving out the kinds of change there
+// were none of.
+func WriteChangelog(w io.Writer, c *Changelog, groups *Groups) {
+	fmt.Fprintf(w, "# Architecture changes from %s to %s\n", c.From, c.To)
+	if len(c.AddedPackages)+len(c.RemovedPackages)+len(c.AddedModules)+len(c.RemovedModules)+len(c.CrossLayer) == 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "No architecture-relevant changes.")
+		return
+	}
+
+	list := func(heading string, items []string) {
+		if len(items) == 0 {
+			return
+		}
+		fmt.Fprintf(w, "\n## %s (%d)\n\n", heading, len(items))
+		for _, i := range items {
+			fmt.Fprintf(w, "- %s\n", i)
+		}
+	}
+
+	code := func(paths []string) []string {
+		var ret []string
+		for _, p := range paths {
+			ret = append(ret, markdownCode([]string{p}))
+		}
+		return ret
+	}
+
+	list("New packages", code(c.AddedPackages))
+	list("Removed packages", code(c.RemovedPackages))
+
+	var modules []string
+	for _, m := range slices.Sorted(maps.Keys(c.AddedModules)) {
+		modules = append(modules, fmt.Sprintf("%s, imported by %s", markdownCode([]string{m}), markdownCode(c.AddedModules[m])))
+	}
+	list("New external modules", modules)
+	list("Removed external modules", code(c.RemovedModules))
+
+	var edges []string
+	for _, e := range c.CrossLayer {
+		edges = append(edges, fmt.Sprintf("%s (%s) imports %s (%s)", markdownCode([]string{e.From}), orNone(groups.Of(e.From)), markdownCode([]string{e.To}), orNone(groups.Of(e.To))))
+	}
+	list("New cross-layer imports", edges)
+}
+
+func RunChangelog(args []string) {
+	fs := flag.NewFlagSet("changelog", flag.ExitOnError)
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintln(w, "'wuw changelog' sums up the architecture changes of the repo between two git refs as Markdown for release notes: packages added and removed, external modules newly imported or no longer, and with -groups new imports across groups.")
+		fmt.Fprintf(w, "Usage: %s changelog -from ref [-opts]\nopts:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+
+	fromVar := fs.String("from", "", "Git `ref` to compare from, such as the previous release tag")
+	toVar := fs.String("to", "HEAD", "Git `ref` to compare to")
+	groupsVar := fs.String("groups", "", "YAML `file` of groups, taken as the layers whose new crossings to report")
+	noStdVar := fs.Bool("no-std", false, "Exclude stdlib packages (including golang.org/x/)")
+	outVar := OutputFlag(fs)
+
+	ParseFlags(fs, args)
+
+	if *fromVar == "" {
+		Fatal(errors.New("-from is required"))
+	}
+
+	var groups *Groups
+	if *groupsVar != "" {
+		var err error
+		if groups, err = LoadGroups(*groupsVar); err != nil {
+			Fatal(err)
+		}
+	}
+
+	// the whole repo is scanned, for the go.mod files above the working
+	// dir to be found
+	v, err := OpenVCS(".")
+	if err != nil {
+		Fatal(err)
+	}
+	root, err := v.Root()
+	if err != nil {
+		Fatal(err)
+	}
+	if v, err = OpenVCS(root); err != nil {
+		Fatal(err)
+	}
+	from, errs, err := ScanRef(v, *fromVar, *noStdVar)
+	if err != nil {
+		Fatal(err)
+	}
+	PrintErrors(errs)
+	to, errs, err := ScanRef(v, *toVar, *noStdVar)
+	if err != nil {
+		Fatal(err)
+	}
+	PrintErrors(errs)
+
+	w := OpenOutput(*outVar)
+	defer w.Close()
+	WriteChangelog(w, NewChangelog(from, to, groups), groups)
+}
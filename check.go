@@ -0,0 +1,273 @@
+package wuw
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+func RunCheck(args []string) {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintln(w, "'wuw check' enforces the import rules in the config file, and that every external module imported has an owner in the owners file if there is one, exiting with 1 if any of them are broken. With -exp-audit, packages tied to a toolchain or experimental APIs, such as golang.org/x/exp, may only be imported by the packages matching toolchain_allow in the config file.")
+		fmt.Fprintf(w, "Usage: %s check [-opts] [dirs...]\nopts:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+
+	configVar := fs.String("config", DefaultConfig, "Config file to read rules from")
+	statsVar := fs.Bool("stats", false, "Report how many violations and packages each rule produced, noisiest first")
+	explainVar := fs.String("explain", "", "Print how every rule was evaluated for the imports of this `package`")
+	positionsVar := fs.Bool("positions", false, "Prefix violations with the file:line:col of the import")
+	excludeVar := QualifierFlag(fs)
+	baseVar := fs.String("base", "", "Only warn about heavyweight modules imported since this git `ref`")
+	ownersVar := fs.String("owners", DefaultOwners, "YAML `file` mapping external modules to the team owning them, checked if it exists")
+	expAuditVar := fs.Bool("exp-audit", false, "List the imports of toolchain-specific and experimental packages, failing on those outside toolchain_allow packages")
+	TagsFlag(fs)
+	formatVar := fs.String("format", "text", "Output format: text, or codeclimate for the code quality reports of GitLab and other CI systems")
+	outVar := OutputFlag(fs)
+
+	ParseFlags(fs, args)
+
+	codeClimate := *formatVar == "codeclimate"
+	switch {
+	case *formatVar != "text" && !codeClimate:
+		Fatal(fmt.Errorf("unknown format %q", *formatVar))
+	case codeClimate && (*statsVar || *explainVar != ""):
+		Fatal(errors.New("-stats and -explain can't be used with -format codeclimate"))
+	}
+
+	c, err := LoadConfig(*configVar)
+	if err != nil {
+		Fatal(err)
+	}
+	excluded, err := ParseQualifiers(*excludeVar)
+	if err != nil {
+		Fatal(err)
+	}
+	owners, err := LoadOwners(*ownersVar)
+	if os.IsNotExist(err) {
+		owners = nil
+	} else if err != nil {
+		Fatal(err)
+	}
+
+	dirs := ReadArgs(fs.Args(), fs.Usage)
+	pkgs, errs := ScanDirs(dirs, false)
+	pkgs = ExcludeQualified(pkgs, excluded)
+
+	PrintErrors(errs)
+
+	g := NewGraph(pkgs)
+
+	var base map[string][]string
+	if *baseVar != "" {
+		v, err := OpenVCS(".")
+		if err != nil {
+			Fatal(err)
+		}
+		if base, err = BaseImports(v, *baseVar, dirs); err != nil {
+			Fatal(err)
+		}
+	}
+	for _, h := range HeavyImports(g, c.HeavyModules(), base) {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", h)
+	}
+
+	w := OpenOutput(*outVar)
+	defer w.Close()
+
+	if *explainVar != "" {
+		pkg, ok := g.Lookup(*explainVar)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "error: package %s was not scanned\n", *explainVar)
+			os.Exit(1)
+		}
+		c.Explain(w, pkg)
+	}
+
+	violations := c.Check(g)
+
+	sites := make(map[Edge][]ImportSite)
+	if *positionsVar || codeClimate {
+		for _, p := range g.Order {
+			for _, st := range ImportSites(g.Pkgs[p]) {
+				e := Edge{From: p, To: st.Path}
+				sites[e] = append(sites[e], st)
+			}
+		}
+	}
+
+	var issues []CodeClimateIssue
+	for _, v := range violations {
+		if codeClimate {
+			desc := fmt.Sprintf("%s imports %s, breaking rule %s", v.From, v.To, v.Rule.Name)
+			if v.Rule.Reason != "" {
+				desc += ": " + v.Rule.Reason
+			}
+			issues = append(issues, NewCodeClimateIssue(g, v.Edge, sites[v.Edge], "wuw/rule/"+v.Rule.Name, "major", desc))
+			continue
+		}
+		if st := sites[v.Edge]; len(st) != 0 {
+			fmt.Fprintf(w, "%s:%d:%d: ", st[0].File, st[0].Line, st[0].Col)
+		}
+		fmt.Fprintf(w, "%s -> %s: %s", v.From, v.To, v.Rule.Name)
+		if v.Rule.Reason != "" {
+			fmt.Fprintf(w, " (%s)", v.Rule.Reason)
+		}
+		fmt.Fprintln(w)
+	}
+
+	var unowned []UnownedImport
+	if owners != nil {
+		unowned = UnownedImports(g, owners)
+	}
+	for _, u := range unowned {
+		if codeClimate {
+			issues = append(issues, NewCodeClimateIssue(g, u.Edge, sites[u.Edge], "wuw/owners", "minor", fmt.Sprintf("%s in %s", u, *ownersVar)))
+			continue
+		}
+		if st := sites[u.Edge]; len(st) != 0 {
+			fmt.Fprintf(w, "%s:%d:%d: ", st[0].File, st[0].Line, st[0].Col)
+		}
+		fmt.Fprintf(w, "%s in %s\n", u, *ownersVar)
+	}
+
+	var forbidden int
+	if *expAuditVar {
+		imports, err := c.ToolchainImports(g)
+		if err != nil {
+			Fatal(fmt.Errorf("%s: %w", *configVar, err))
+		}
+		for _, t := range imports {
+			if !t.Allowed {
+				forbidden++
+			}
+			if codeClimate {
+				if !t.Allowed {
+					issues = append(issues, NewCodeClimateIssue(g, t.Edge, sites[t.Edge], "wuw/toolchain", "major", t.String()))
+				}
+				continue
+			}
+			if st := sites[t.Edge]; len(st) != 0 {
+				fmt.Fprintf(w, "%s:%d:%d: ", st[0].File, st[0].Line, st[0].Col)
+			}
+			fmt.Fprintln(w, t)
+		}
+	}
+
+	if codeClimate {
+		if err := WriteCodeClimate(w, issues); err != nil {
+			Fatal(err)
+		}
+		if len(issues) != 0 {
+			w.Close()
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *statsVar {
+		fmt.Fprintln(w)
+		PrintRuleStats(w, c.Stats(g, violations))
+	}
+
+	if n := len(violations) + len(unowned) + forbidden; n != 0 {
+		fmt.Fprintf(w, "%d violations\n", n)
+		w.Close()
+		os.Exit(1)
+	}
+}
+
+func PrintRuleStats(w io.Writer, stats []RuleStats) {
+	fmt.Fprintf(w, "%-24s%12s%12s%12s\n", "rule", "violations", "violating", "applies to")
+	for _, s := range stats {
+		fmt.Fprintf(w, "%-24s%12d%12d%12d\n", s.Rule.Name, s.Violations, s.Packages, s.Applies)
+	}
+}
+
+// Explain prints every rule evaluated for the imports of pkg, and why each
+// import was allowed or denied.
+func (c *Config) Explain(w io.Writer, pkg *Package) {
+	fmt.Fprintf(w, "explaining %s\n", pkg.ImportPath)
+	for _, r := range c.Rules {
+		if !r.Applies(pkg.ImportPath) {
+			fmt.Fprintf(w, "rule %s: does not apply, from %q does not match\n", r.Name, r.From)
+			continue
+		}
+
+		fmt.Fprintf(w, "rule %s: applies, from %q matches\n", r.Name, r.From)
+		for _, d := range pkg.Deps {
+			fmt.Fprintf(w, "\t%s: %s\n", d, r.Evaluate(pkg.ImportPath, d))
+		}
+	}
+
+	var denied int
+	for _, d := range pkg.Deps {
+		for _, r := range c.Rules {
+			if r.Denies(pkg.ImportPath, d) {
+				denied++
+				break
+			}
+		}
+	}
+	fmt.Fprintf(w, "%d of %d imports denied\n\n", denied, len(pkg.Deps))
+}
+
+func RunConfig(args []string) {
+	if len(args) == 0 || args[0] != "validate" {
+		fmt.Fprintf(os.Stderr, "Usage: %s config validate [-opts] [dirs...]\n", os.Args[0])
+		os.Exit(1)
+	}
+
+	fs := flag.NewFlagSet("config validate", flag.ExitOnError)
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintln(w, "'wuw config validate' checks the config file for unknown keys and bad regexes. When given dirs, it also reports rules whose patterns match nothing that was scanned.")
+		fmt.Fprintf(w, "Usage: %s config validate [-opts] [dirs...]\nopts:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+
+	configVar := fs.String("config", DefaultConfig, "Config file to validate")
+	outVar := OutputFlag(fs)
+
+	ParseFlags(fs, args[1:])
+
+	w := OpenOutput(*outVar)
+	defer w.Close()
+
+	problems, err := ValidateConfig(*configVar)
+	if err != nil {
+		Fatal(err)
+	}
+	for _, p := range problems {
+		fmt.Fprintf(w, "%s:%s\n", *configVar, p)
+	}
+
+	if len(problems) == 0 && (fs.NArg() != 0 || HasStdin()) {
+		c, err := LoadConfig(*configVar)
+		if err != nil {
+			Fatal(err)
+		}
+
+		dirs := ReadArgs(fs.Args(), fs.Usage)
+		pkgs, _ := ScanDirs(dirs, false)
+
+		unreachable := c.Unreachable(NewGraph(pkgs))
+		for _, u := range unreachable {
+			fmt.Fprintf(w, "%s: %s\n", *configVar, u)
+		}
+		if len(unreachable) != 0 {
+			w.Close()
+			os.Exit(1)
+		}
+	}
+
+	if len(problems) != 0 {
+		w.Close()
+		os.Exit(1)
+	}
+	fmt.Fprintf(w, "%s: ok\n", *configVar)
+}
@@ -0,0 +1,150 @@
+package wuw
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"go/build"
+	"os"
+	"path/filepath"
+)
+
+// noClassCache is set by -no-class-cache, and makes every run work out the
+// classification of imports afresh.
+var noClassCache bool
+
+// ClassCache keeps what was worked out about the packages imported between
+// runs: whether they are in the standard library, and for each module, which
+// module they belong to and at what version, along with its module graph.
+// Only the answers to questions that needed the go command or a read of the
+// module's files are kept, and they are thrown away when the GOROOT or the
+// files they came from change.
+type ClassCache struct {
+	GOROOT  string                    `json:"goroot"`
+	Std     map[string]bool           `json:"std"`
+	Modules map[string]*ModuleClasses `json:"modules"`
+
+	dirty bool
+}
+
+// ModuleClasses is what the cache knows about the imports of the module at a
+// root.
+type ModuleClasses struct {
+	// Hash is of the go.mod and vendor/modules.txt the answers came from.
+	Hash  string               `json:"hash"`
+	Paths map[string]PathClass `json:"paths"`
+	// Graph is the output of 'go mod graph', if it was needed.
+	Graph map[string][]string `json:"graph,omitempty"`
+}
+
+type PathClass struct {
+	Module  string `json:"module"`
+	Version string `json:"version,omitempty"`
+}
+
+// classCache is the cache of this run, loaded on first use.
+var classCache *ClassCache
+
+// ClassCachePath returns where the cache is kept, or "" if there is no
+// user cache dir.
+func ClassCachePath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "wuw", "classes.json")
+}
+
+// Classes returns the cache, reading it the first time. It is empty if
+// there is none yet, it can't be read, -no-class-cache is set or the GOROOT
+// changed since it was written.
+func Classes() *ClassCache {
+	if classCache != nil {
+		return classCache
+	}
+	classCache = &ClassCache{GOROOT: build.Default.GOROOT, Std: make(map[string]bool), Modules: make(map[string]*ModuleClasses)}
+	if noClassCache {
+		return classCache
+	}
+	data, err := os.ReadFile(ClassCachePath())
+	if err != nil {
+		return classCache
+	}
+	var c ClassCache
+	if json.Unmarshal(data, &c) == nil && c.GOROOT == classCache.GOROOT && c.Std != nil && c.Modules != nil {
+		classCache = &c
+	}
+	return classCache
+}
+
+// SaveClassCache writes the cache back if this run added to it. Failing to
+// is not worth stopping for, as it only makes the next run slower.
+func SaveClassCache() {
+	c := classCache
+	if c == nil || !c.dirty || noClassCache {
+		return
+	}
+	path := ClassCachePath()
+	if path == "" {
+		return
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return
+	}
+	if os.MkdirAll(filepath.Dir(path), 0o755) != nil {
+		return
+	}
+	tmp := path + ".tmp"
+	if os.WriteFile(tmp, data, 0o644) == nil {
+		os.Rename(tmp, path)
+	}
+}
+
+func (c *ClassCache) SetStd(path string, std bool) {
+	c.Std[path] = std
+	c.dirty = true
+}
+
+// Module returns what is known about the module at root, forgetting it if
+// the files it was learned from changed.
+func (c *ClassCache) Module(root string) *ModuleClasses {
+	hash := moduleFilesHash(root)
+	m, ok := c.Modules[root]
+	if !ok || m.Hash != hash {
+		m = &ModuleClasses{Hash: hash, Paths: make(map[string]PathClass)}
+		c.Modules[root] = m
+		c.dirty = true
+	}
+	return m
+}
+
+func (m *ModuleClasses) lookup(path string) (PathClass, bool) {
+	if m == nil {
+		return PathClass{}, false
+	}
+	c, ok := m.Paths[path]
+	return c, ok
+}
+
+func (c *ClassCache) SetPath(m *ModuleClasses, path string, class PathClass) {
+	m.Paths[path] = class
+	c.dirty = true
+}
+
+func (c *ClassCache) SetGraph(m *ModuleClasses, graph map[string][]string) {
+	m.Graph = graph
+	c.dirty = true
+}
+
+// moduleFilesHash hashes the files the modules of the module at root are
+// read from.
+func moduleFilesHash(root string) string {
+	h := sha256.New()
+	for _, name := range []string{"go.mod", filepath.Join("vendor", "modules.txt")} {
+		data, _ := os.ReadFile(filepath.Join(root, name))
+		h.Write([]byte(name))
+		h.Write(data)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,9 @@
+// Command wuw shows what parts of a Go project depend on what other parts,
+// and on what external dependencies.
+package main
+
+import "github.com/krbreyn/wuw"
+
+func main() {
+	wuw.Main()
+}
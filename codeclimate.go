@@ -0,0 +1,63 @@
+package wuw
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// CodeClimateIssue is an issue of the Code Climate report format, which
+// GitLab and other CI systems show on merge requests.
+type CodeClimateIssue struct {
+	Type        string              `json:"type"`
+	CheckName   string              `json:"check_name"`
+	Description string              `json:"description"`
+	Categories  []string            `json:"categories"`
+	Severity    string              `json:"severity"`
+	Fingerprint string              `json:"fingerprint"`
+	Location    CodeClimateLocation `json:"location"`
+}
+
+type CodeClimateLocation struct {
+	Path  string           `json:"path"`
+	Lines CodeClimateLines `json:"lines"`
+}
+
+type CodeClimateLines struct {
+	Begin int `json:"begin"`
+}
+
+// NewCodeClimateIssue returns the issue checkName found with the import of
+// e, at the first of sites if there is one and otherwise at the top of the
+// first file of the importer. The fingerprint only depends on the check and
+// the import, so the issue is tracked across changes moving it around.
+func NewCodeClimateIssue(g *Graph, e Edge, sites []ImportSite, checkName, severity, description string) CodeClimateIssue {
+	loc := CodeClimateLocation{Path: RelPath(g.Pkgs[e.From].Path), Lines: CodeClimateLines{Begin: 1}}
+	if len(sites) != 0 {
+		loc = CodeClimateLocation{Path: RelPath(sites[0].File), Lines: CodeClimateLines{Begin: sites[0].Line}}
+	} else if files := g.Pkgs[e.From].Files; len(files) != 0 {
+		loc.Path = RelPath(files[0])
+	}
+	return CodeClimateIssue{
+		Type:        "issue",
+		CheckName:   checkName,
+		Description: description,
+		Categories:  []string{"Style"},
+		Severity:    severity,
+		Fingerprint: fmt.Sprintf("%x", sha256.Sum256([]byte(checkName+"\x00"+e.From+"\x00"+e.To))),
+		Location:    loc,
+	}
+}
+
+// WriteCodeClimate writes issues as a Code Climate report, an array that is
+// empty rather than null when there are none.
+func WriteCodeClimate(w io.Writer, issues []CodeClimateIssue) error {
+	if issues == nil {
+		issues = []CodeClimateIssue{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(issues)
+}
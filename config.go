@@ -0,0 +1,287 @@
+package wuw
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+const DefaultConfig = ".wuw.yaml"
+
+type Config struct {
+	Rules []*Rule `yaml:"rules"`
+	// Heavy lists modules to warn about small packages importing, on top of
+	// DefaultHeavyModules.
+	Heavy []string `yaml:"heavy"`
+	// TestSupport holds regexes of packages that only exist to support
+	// tests, on top of DefaultTestSupport.
+	TestSupport []string `yaml:"test_support"`
+	// Profiles are named sets of flags, and rules on top of Rules, picked
+	// with -profile.
+	Profiles map[string]*Profile `yaml:"profiles"`
+	// Descriptions says what packages, and areas or layers, are for, keyed
+	// by import path or area name, for 'wuw docs' to include.
+	Descriptions map[string]string `yaml:"descriptions"`
+	// Experimental holds regexes of packages to treat as experimental, on
+	// top of those marked //wuw:experimental.
+	Experimental []string `yaml:"experimental"`
+	// Toolchain lists modules tied to a toolchain or experimental APIs, on
+	// top of DefaultToolchainModules, for check -exp-audit.
+	Toolchain []string `yaml:"toolchain"`
+	// ToolchainAllow holds regexes of the packages allowed to import them,
+	// such as tools.
+	ToolchainAllow []string `yaml:"toolchain_allow"`
+	// Metrics are derived metrics of packages, keyed by name, as
+	// expressions over the built-in ones.
+	Metrics map[string]string `yaml:"metrics"`
+
+	metrics []*DerivedMetric
+}
+
+type Profile struct {
+	Flags map[string]string `yaml:"flags"`
+	Rules []*Rule           `yaml:"rules"`
+}
+
+// Rule forbids packages matching From from importing anything matching one of
+// Deny, unless it also matches one of Allow. All patterns are regexes matched
+// against import paths.
+type Rule struct {
+	Name   string   `yaml:"name"`
+	From   string   `yaml:"from"`
+	Deny   []string `yaml:"deny"`
+	Allow  []string `yaml:"allow"`
+	Reason string   `yaml:"reason"`
+
+	from  *regexp.Regexp
+	deny  []*regexp.Regexp
+	allow []*regexp.Regexp
+}
+
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var c Config
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if profile != "" {
+		p, ok := c.Profiles[profile]
+		if !ok {
+			return nil, fmt.Errorf("%s: no profile %s", path, profile)
+		}
+		c.Rules = append(c.Rules, p.Rules...)
+	}
+
+	for _, r := range c.Rules {
+		if err := r.Compile(); err != nil {
+			return nil, fmt.Errorf("%s: rule %s: %w", path, r.Name, err)
+		}
+	}
+	if err := c.compileMetrics(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &c, nil
+}
+
+func (r *Rule) Compile() error {
+	var err error
+	if r.from, err = regexp.Compile(r.From); err != nil {
+		return err
+	}
+
+	r.deny = nil
+	for _, d := range r.Deny {
+		re, err := regexp.Compile(d)
+		if err != nil {
+			return err
+		}
+		r.deny = append(r.deny, re)
+	}
+
+	r.allow = nil
+	for _, a := range r.Allow {
+		re, err := regexp.Compile(a)
+		if err != nil {
+			return err
+		}
+		r.allow = append(r.allow, re)
+	}
+	return nil
+}
+
+func (r *Rule) Applies(importer string) bool {
+	return r.from.MatchString(importer)
+}
+
+// Denies reports whether the rule forbids importer from importing imported.
+func (r *Rule) Denies(importer, imported string) bool {
+	return r.Evaluate(importer, imported).Denied
+}
+
+// Decision records how a rule was evaluated for a single edge.
+type Decision struct {
+	Applies bool
+	Denied  bool
+	// the first deny and allow patterns that matched, if any
+	Deny  *regexp.Regexp
+	Allow *regexp.Regexp
+}
+
+func (r *Rule) Evaluate(importer, imported string) Decision {
+	var d Decision
+	if d.Applies = r.Applies(importer); !d.Applies {
+		return d
+	}
+
+	for _, re := range r.deny {
+		if re.MatchString(imported) {
+			d.Deny = re
+			break
+		}
+	}
+	if d.Deny == nil {
+		return d
+	}
+
+	for _, re := range r.allow {
+		if re.MatchString(imported) {
+			d.Allow = re
+			break
+		}
+	}
+	d.Denied = d.Allow == nil
+	return d
+}
+
+func (d Decision) String() string {
+	switch {
+	case !d.Applies:
+		return "rule does not apply"
+	case d.Deny == nil:
+		return "allowed, matches no deny pattern"
+	case d.Allow != nil:
+		return fmt.Sprintf("allowed, matches deny %q but also allow %q", d.Deny, d.Allow)
+	}
+	return fmt.Sprintf("denied, matches deny %q", d.Deny)
+}
+
+type Violation struct {
+	Edge
+	Rule *Rule
+}
+
+func (c *Config) Check(g *Graph) []Violation {
+	var ret []Violation
+	for _, p := range g.Order {
+		if g.Pkgs[p].Experimental {
+			continue
+		}
+		for _, d := range g.Pkgs[p].Deps {
+			for _, r := range c.Rules {
+				if r.Denies(p, d) {
+					ret = append(ret, Violation{Edge: Edge{From: p, To: d}, Rule: r})
+				}
+			}
+		}
+	}
+	return ret
+}
+
+// ValidateConfig checks the config file at path against the embedded schema.
+func ValidateConfig(path string) ([]SchemaError, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return ConfigSchema().Validate(&doc), nil
+}
+
+// Unreachable describes every rule pattern that matches none of the scanned
+// packages or their imports, since those usually mean a typo.
+func (c *Config) Unreachable(g *Graph) []string {
+	var ret []string
+	for _, r := range c.Rules {
+		var importers []string
+		for _, p := range g.Order {
+			if r.Applies(p) {
+				importers = append(importers, p)
+			}
+		}
+		if len(importers) == 0 {
+			ret = append(ret, fmt.Sprintf("rule %s: from %q matches no packages", r.Name, r.From))
+			continue
+		}
+
+		patterns := map[string][]*regexp.Regexp{"deny": r.deny, "allow": r.allow}
+		for _, kind := range []string{"deny", "allow"} {
+			for _, re := range patterns[kind] {
+				var matched bool
+				for _, p := range importers {
+					for _, d := range g.Pkgs[p].Deps {
+						if re.MatchString(d) {
+							matched = true
+						}
+					}
+				}
+				if !matched {
+					ret = append(ret, fmt.Sprintf("rule %s: %s %q matches no imports of the packages it applies to", r.Name, kind, re.String()))
+				}
+			}
+		}
+	}
+	return ret
+}
+
+type RuleStats struct {
+	Rule       *Rule
+	Violations int
+	// Packages is how many packages broke the rule, Applies how many it was
+	// evaluated for.
+	Packages int
+	Applies  int
+}
+
+// Stats summarises violations per rule, sorted with the noisiest rule first.
+func (c *Config) Stats(g *Graph, violations []Violation) []RuleStats {
+	var ret []RuleStats
+	for _, r := range c.Rules {
+		s := RuleStats{Rule: r}
+
+		violating := make(map[string]bool)
+		for _, v := range violations {
+			if v.Rule == r {
+				s.Violations++
+				violating[v.From] = true
+			}
+		}
+		s.Packages = len(violating)
+
+		for _, p := range g.Order {
+			if r.Applies(p) {
+				s.Applies++
+			}
+		}
+		ret = append(ret, s)
+	}
+
+	slices.SortStableFunc(ret, func(a, b RuleStats) int {
+		if a.Violations != b.Violations {
+			return b.Violations - a.Violations
+		}
+		return b.Packages - a.Packages
+	})
+	return ret
+}
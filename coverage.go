@@ -0,0 +1,58 @@
+package wuw
+
+import (
+	"fmt"
+	"io"
+)
+
+// Why a dir was left out of a scan.
+const (
+	SkipUnreadable = "unreadable"
+	SkipIgnored    = "ignored"
+	SkipNoGo       = "no Go files"
+	SkipBuild      = "excluded by build constraints"
+	SkipHook       = "skipped by hook"
+)
+
+// SkippedDir is a dir a scan didn't read any package from, and why. Detail
+// says more about the reason, such as the error reading the dir or the
+// ignore rule it matched.
+type SkippedDir struct {
+	Dir    string `json:"dir" yaml:"dir"`
+	Reason string `json:"reason" yaml:"reason"`
+	Detail string `json:"detail,omitempty" yaml:"detail,omitempty"`
+}
+
+// Coverage is what expanding dir/... and scanning left out, to check that a
+// scan saw the whole tree. Dirs below an ignored one aren't listed, as they
+// weren't looked at.
+type Coverage struct {
+	Skipped []SkippedDir
+}
+
+// scanCoverage is set with -coverage, for ExpandDirs and new scanners to
+// record what they skip to.
+var scanCoverage *Coverage
+
+// Skip records that dir was skipped for reason. It does nothing if c is nil.
+func (c *Coverage) Skip(dir, reason, detail string) {
+	if c == nil {
+		return
+	}
+	c.Skipped = append(c.Skipped, SkippedDir{Dir: dir, Reason: reason, Detail: detail})
+}
+
+// WriteCoverage writes the coverage section of text output.
+func WriteCoverage(w io.Writer, c *Coverage) {
+	fmt.Fprintln(w, "coverage:")
+	if len(c.Skipped) == 0 {
+		fmt.Fprintln(w, "\tno dirs skipped")
+	}
+	for _, s := range c.Skipped {
+		if s.Detail != "" {
+			fmt.Fprintf(w, "\t%s: %s (%s)\n", RelPath(s.Dir), s.Reason, s.Detail)
+		} else {
+			fmt.Fprintf(w, "\t%s: %s\n", RelPath(s.Dir), s.Reason)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package wuw
+
+import (
+	"encoding/json"
+	"io"
+	"path"
+)
+
+type cytoscapeDoc struct {
+	Elements cytoscapeElements `json:"elements"`
+}
+
+type cytoscapeElements struct {
+	Nodes []cytoscapeElement `json:"nodes"`
+	Edges []cytoscapeElement `json:"edges"`
+}
+
+type cytoscapeElement struct {
+	Data    cytoscapeData `json:"data"`
+	Classes string        `json:"classes,omitempty"`
+}
+
+// cytoscapeData holds the fields of both nodes and edges, those of the other
+// kind left empty.
+type cytoscapeData struct {
+	ID           string   `json:"id"`
+	Label        string   `json:"label,omitempty"`
+	Parent       string   `json:"parent,omitempty"`
+	Path         string   `json:"path,omitempty"`
+	Category     string   `json:"category,omitempty"`
+	Experimental bool     `json:"experimental,omitempty"`
+	Source       string   `json:"source,omitempty"`
+	Target       string   `json:"target,omitempty"`
+	Qualifiers   []string `json:"qualifiers,omitempty"`
+}
+
+// WriteCytoscape writes g in the elements JSON of Cytoscape.js, as taken by
+// cytoscape({elements}) and cy.json(). Every package is a node, deps
+// included, keyed by import path and with its category as a class for
+// stylesheets to pick out. Groups become compound nodes holding their
+// packages.
+func WriteCytoscape(w io.Writer, g *Graph, groups *Groups) error {
+	doc := cytoscapeDoc{Elements: cytoscapeElements{Nodes: []cytoscapeElement{}, Edges: []cytoscapeElement{}}}
+
+	for _, name := range groups.Names() {
+		doc.Elements.Nodes = append(doc.Elements.Nodes, cytoscapeElement{Data: cytoscapeData{ID: "group:" + name, Label: name}, Classes: "group"})
+	}
+
+	seen := make(map[string]bool)
+	addNode := func(p string) {
+		if seen[p] {
+			return
+		}
+		seen[p] = true
+		n := cytoscapeElement{Data: cytoscapeData{ID: p, Label: path.Base(p), Category: g.Category(p)}, Classes: g.Category(p)}
+		if pkg, ok := g.Pkgs[p]; ok {
+			n.Data.Label = LayoutLabel(g, p)
+			n.Data.Path = RelPath(pkg.Path)
+			n.Data.Experimental = pkg.Experimental
+		}
+		if group := groups.Of(p); group != "" {
+			n.Data.Parent = "group:" + group
+		}
+		doc.Elements.Nodes = append(doc.Elements.Nodes, n)
+	}
+
+	for _, p := range g.Order {
+		addNode(p)
+	}
+	for _, p := range g.Order {
+		pkg := g.Pkgs[p]
+		for _, d := range pkg.Deps {
+			addNode(d)
+			doc.Elements.Edges = append(doc.Elements.Edges, cytoscapeElement{Data: cytoscapeData{
+				ID:         p + " -> " + d,
+				Source:     p,
+				Target:     d,
+				Qualifiers: pkg.Qualifiers[d],
+			}})
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
@@ -0,0 +1,84 @@
+package wuw
+
+import (
+	"fmt"
+	"io"
+)
+
+// WriteD2 writes the internal packages of g and the imports between them as
+// D2 source, for its automatic layouts to arrange. Groups, if there are any,
+// are containers around their packages. Generated packages are drawn as
+// pages, packages only there to support tests dashed and experimental ones
+// faded, and with status packages and imports are colored by how they fare
+// against the rules. The metadata of info goes in comments.
+func WriteD2(w io.Writer, g *Graph, groups *Groups, status *RuleStatus, info *DiagramInfo) {
+	if info != nil {
+		for _, l := range info.Lines() {
+			fmt.Fprintf(w, "# %s\n", l)
+		}
+	}
+	fmt.Fprintln(w, "direction: right")
+
+	// packages in a container are referred to through it
+	key := func(p string) string {
+		if group := groups.Of(p); group != "" {
+			return MermaidID("group:"+group) + "." + MermaidID(p)
+		}
+		return MermaidID(p)
+	}
+
+	node := func(indent, p string) {
+		pkg := g.Pkgs[p]
+		var attrs []string
+		if len(pkg.Generated) != 0 && len(pkg.Generated) == len(pkg.Files) {
+			attrs = append(attrs, "shape: page")
+		}
+		if pkg.TestSupport {
+			attrs = append(attrs, "style.stroke-dash: 3")
+		}
+		if pkg.Experimental {
+			attrs = append(attrs, "style.opacity: 0.4")
+		}
+		if s := status.Of(p); s != "" {
+			attrs = append(attrs, "style.stroke: "+D2Quote(statusColors[s]))
+		}
+
+		fmt.Fprintf(w, "%s%s: %s", indent, MermaidID(p), D2Quote(p))
+		if len(attrs) == 0 {
+			fmt.Fprintln(w)
+			return
+		}
+		fmt.Fprintln(w, " {")
+		for _, a := range attrs {
+			fmt.Fprintf(w, "%s  %s\n", indent, a)
+		}
+		fmt.Fprintf(w, "%s}\n", indent)
+	}
+
+	if groups != nil {
+		for _, name := range groups.Names() {
+			fmt.Fprintf(w, "%s: %s {\n", MermaidID("group:"+name), D2Quote(name))
+			for _, p := range g.Order {
+				if groups.Of(p) == name {
+					node("  ", p)
+				}
+			}
+			fmt.Fprintln(w, "}")
+		}
+	}
+	for _, p := range g.Order {
+		if groups.Of(p) == "" {
+			node("", p)
+		}
+	}
+
+	for _, p := range g.Order {
+		for _, d := range g.InternalDeps(p) {
+			if s := status.Edge(Edge{From: p, To: d}); s != "" {
+				fmt.Fprintf(w, "%s -> %s: {style.stroke: %s}\n", key(p), key(d), D2Quote(statusColors[s]))
+			} else {
+				fmt.Fprintf(w, "%s -> %s\n", key(p), key(d))
+			}
+		}
+	}
+}
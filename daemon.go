@@ -0,0 +1,313 @@
+package wuw
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// daemonSocket is set by the top-level -use-daemon flag, and makes every
+// scan ask the daemon listening there instead of reading the files itself.
+var daemonSocket string
+
+func RunDaemon(args []string) {
+	fs := flag.NewFlagSet("daemon", flag.ExitOnError)
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintln(w, "'wuw daemon' keeps scanned packages in memory and serves them to 'wuw -use-daemon' clients, rescanning only the dirs whose files changed. Other clients can send {\"dirs\": [...], \"schema\": \"v2\"} to get the JSON output of the scan in that schema.")
+		fmt.Fprintf(w, "Usage: %s daemon [-opts]\nopts:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+
+	socketVar := fs.String("socket", DefaultSocket(), "Unix socket to listen on")
+
+	ParseFlags(fs, args)
+
+	os.Remove(*socketVar)
+	l, err := net.Listen("unix", *socketVar)
+	if err != nil {
+		Fatal(err)
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sig
+		l.Close()
+	}()
+
+	fmt.Fprintf(os.Stderr, "listening on %s\n", *socketVar)
+
+	d := &Daemon{cache: make(map[string]*daemonEntry)}
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			fmt.Fprintln(os.Stderr, err)
+			continue
+		}
+		go d.Serve(conn)
+	}
+}
+
+func DefaultSocket() string {
+	return filepath.Join(os.TempDir(), fmt.Sprintf("wuw-%d.sock", os.Getuid()))
+}
+
+// DaemonRequest asks the daemon to scan Dirs. With Schema, it answers with
+// the scan as Report, the JSON output in that version, for clients other
+// than wuw to use; without, with the packages themselves.
+type DaemonRequest struct {
+	Dirs   []string `json:"dirs"`
+	NoStd  bool     `json:"no_std"`
+	Schema string   `json:"schema,omitempty"`
+}
+
+type DaemonResponse struct {
+	Pkgs []Package `json:"pkgs"`
+	Errs []string  `json:"errs"`
+
+	Schema string          `json:"schema,omitempty"`
+	Report json.RawMessage `json:"report,omitempty"`
+	// Warning says if the schema asked for is deprecated, and Error why the
+	// request couldn't be answered.
+	Warning string `json:"warning,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Daemon answers the requests of its clients one at a time, holding mu,
+// since the caches classifying imports that scanning, filtering and
+// reporting fill aren't safe for concurrent use.
+type Daemon struct {
+	mu    sync.Mutex
+	cache map[string]*daemonEntry
+}
+
+type daemonEntry struct {
+	// stat is a cheap fingerprint of the names, sizes and mod times of the
+	// dir's files, and hash a hash of their contents, only worked out when
+	// stat changes.
+	stat string
+	hash string
+	pkgs []Package
+	errs []string
+}
+
+func (d *Daemon) Serve(conn net.Conn) {
+	defer conn.Close()
+
+	var req DaemonRequest
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+
+	d.mu.Lock()
+	var resp DaemonResponse
+	for _, dir := range req.Dirs {
+		e := d.lookup(dir)
+		for _, p := range e.pkgs {
+			p.Deps = FilterDependencies(p.Deps, req.NoStd)
+			resp.Pkgs = append(resp.Pkgs, p)
+		}
+		resp.Errs = append(resp.Errs, e.errs...)
+	}
+
+	if req.Schema != "" {
+		resp = d.report(resp, req.Schema)
+	}
+	d.mu.Unlock()
+
+	if err := json.NewEncoder(conn).Encode(resp); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+}
+
+// Report turns the scan in resp into the JSON output in schema, or says why
+// it can't.
+func (d *Daemon) Report(resp DaemonResponse, schema string) DaemonResponse {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.report(resp, schema)
+}
+
+func (d *Daemon) report(resp DaemonResponse, schema string) DaemonResponse {
+	deprecated, err := CheckJSONSchema(schema)
+	if err != nil {
+		return DaemonResponse{Error: err.Error()}
+	}
+
+	r := NewResult(resp.Pkgs, nil)
+	r.ScannedAt = time.Now()
+	for _, e := range resp.Errs {
+		r.Errs = append(r.Errs, errors.New(e))
+	}
+	var b bytes.Buffer
+	if err := (JSONReporter{Schema: schema}).Report(&b, r); err != nil {
+		return DaemonResponse{Error: err.Error()}
+	}
+	return DaemonResponse{Schema: schema, Report: b.Bytes(), Warning: deprecated}
+}
+
+// Lookup returns the scan of dir, rescanning it if its files changed since
+// the last time it was asked for.
+func (d *Daemon) Lookup(dir string) *daemonEntry {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.lookup(dir)
+}
+
+func (d *Daemon) lookup(dir string) *daemonEntry {
+	stat := StatFingerprint(dir)
+	e, ok := d.cache[dir]
+	if ok && e.stat == stat {
+		return e
+	}
+
+	hash := HashGoFiles(dir)
+	if ok && e.hash == hash {
+		e.stat = stat
+		return e
+	}
+
+	pkgs, errs := NewScanner(false).Scan([]string{dir})
+	e = &daemonEntry{stat: stat, hash: hash, pkgs: pkgs}
+	for _, err := range errs {
+		e.errs = append(e.errs, err.Error())
+	}
+	d.cache[dir] = e
+	return e
+}
+
+func StatFingerprint(dir string) string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+
+	h := sha256.New()
+	for _, e := range entries {
+		fi, err := e.Info()
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(h, "%s %d %d\n", e.Name(), fi.Size(), fi.ModTime().UnixNano())
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func HashGoFiles(dir string) string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+
+	h := sha256.New()
+	for _, g := range GetGoFiles(dir, entries) {
+		f, err := os.Open(g)
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(h, "%s\n", g)
+		io.Copy(h, f)
+		f.Close()
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ScanWithDaemon asks the daemon at socket to scan dirs, then rewrites the
+// absolute paths it answers with back to the dirs as given.
+func ScanWithDaemon(socket string, dirs []string, noStd bool) ([]Package, []error, error) {
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer conn.Close()
+
+	req := DaemonRequest{NoStd: noStd}
+	given := make(map[string]string)
+	for _, d := range dirs {
+		abs, err := filepath.Abs(d)
+		if err != nil {
+			return nil, nil, err
+		}
+		req.Dirs = append(req.Dirs, abs)
+		given[abs] = d
+	}
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, nil, err
+	}
+
+	var resp DaemonResponse
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return nil, nil, err
+	}
+
+	rel := func(abs, dir, p string) string {
+		if rest, ok := strings.CutPrefix(p, abs); ok {
+			return filepath.Join(dir, rest)
+		}
+		return p
+	}
+	for i := range resp.Pkgs {
+		p := &resp.Pkgs[i]
+		abs, dir := p.Path, given[p.Path]
+		p.Path = dir
+		for _, files := range [][]string{p.Files, p.Generated, p.Sources} {
+			for j := range files {
+				files[j] = rel(abs, dir, files[j])
+			}
+		}
+	}
+
+	var errs []error
+	for _, e := range resp.Errs {
+		errs = append(errs, errors.New(e))
+	}
+	return resp.Pkgs, errs, nil
+}
+
+// useDaemon reports whether scans of fsys should go through the daemon,
+// which only ever sees the real file system.
+func useDaemon(fsys fs.FS) bool {
+	return daemonSocket != "" && fsys == OS
+}
+
+// ConsumeDaemonFlag takes a leading -use-daemon flag off of args, so it can
+// come before any command.
+func ConsumeDaemonFlag(args []string) []string {
+	if len(args) < 2 {
+		return args
+	}
+
+	name, value, hasValue := strings.Cut(strings.TrimLeft(args[1], "-"), "=")
+	if !strings.HasPrefix(args[1], "-") || name != "use-daemon" {
+		return args
+	}
+	if hasValue {
+		daemonSocket = value
+		return append(args[:1:1], args[2:]...)
+	}
+	if len(args) > 2 {
+		daemonSocket = args[2]
+		return append(args[:1:1], args[3:]...)
+	}
+	return args
+}
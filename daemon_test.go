@@ -0,0 +1,63 @@
+package wuw
+
+import (
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// TestDaemonClients has several clients ask the daemon to scan at once, for
+// go test -race to catch the daemon filling the caches of import classes
+// from more than one of them.
+func TestDaemonClients(t *testing.T) {
+	noClassCache = true
+	t.Cleanup(func() { noClassCache, classCache = false, nil })
+
+	dirs := []string{}
+	for _, d := range fixtureDirs {
+		abs, err := filepath.Abs(filepath.Join("testdata", "fixture", d))
+		if err != nil {
+			t.Fatal(err)
+		}
+		dirs = append(dirs, abs)
+	}
+
+	d := &Daemon{cache: make(map[string]*daemonEntry)}
+	var wg sync.WaitGroup
+	for _, req := range []DaemonRequest{
+		{Dirs: dirs, NoStd: true},
+		{Dirs: dirs, Schema: JSONSchemaV2},
+		{Dirs: dirs},
+	} {
+		client, server := net.Pipe()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.Serve(server)
+		}()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer client.Close()
+			if err := json.NewEncoder(client).Encode(req); err != nil {
+				t.Error(err)
+				return
+			}
+			var resp DaemonResponse
+			if err := json.NewDecoder(client).Decode(&resp); err != nil {
+				t.Error(err)
+				return
+			}
+			if resp.Error != "" {
+				t.Error(resp.Error)
+			}
+			if req.Schema == "" && len(resp.Pkgs) != len(dirs) {
+				t.Errorf("got %d packages, want %d", len(resp.Pkgs), len(dirs))
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,94 @@
+package wuw
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+)
+
+// WriteDatalog writes the graph as Soufflé-style facts, declaring every
+// relation first so the output can be included straight into a program.
+// groups may be nil.
+func WriteDatalog(w io.Writer, g *Graph, groups *Groups) {
+	q := strconv.Quote
+
+	fmt.Fprintln(w, ".decl package(path: symbol, name: symbol, dir: symbol)")
+	fmt.Fprintln(w, ".decl category(path: symbol, category: symbol)")
+	fmt.Fprintln(w, ".decl imports(from: symbol, to: symbol)")
+	fmt.Fprintln(w, ".decl generated_from(path: symbol, source: symbol)")
+	fmt.Fprintln(w, ".decl qualified(from: symbol, to: symbol, qualifier: symbol)")
+	fmt.Fprintln(w, ".decl group(path: symbol, group: symbol)")
+	fmt.Fprintln(w, ".decl test_support(path: symbol)")
+	fmt.Fprintln(w, ".decl experimental(path: symbol)")
+	fmt.Fprintln(w, ".decl cgo(path: symbol)")
+	fmt.Fprintln(w)
+
+	var deps []string
+	for _, p := range g.Order {
+		pkg := g.Pkgs[p]
+		fmt.Fprintf(w, "package(%s, %s, %s).\n", q(p), q(pkg.Name), q(pkg.Path))
+		for _, d := range pkg.Deps {
+			if !slices.Contains(deps, d) {
+				deps = append(deps, d)
+			}
+		}
+	}
+	fmt.Fprintln(w)
+
+	for _, p := range g.Order {
+		fmt.Fprintf(w, "category(%s, %s).\n", q(p), q(CategoryInternal))
+	}
+	slices.Sort(deps)
+	for _, d := range deps {
+		if !g.IsInternal(d) {
+			fmt.Fprintf(w, "category(%s, %s).\n", q(d), q(g.Category(d)))
+		}
+	}
+	fmt.Fprintln(w)
+
+	for _, p := range g.Order {
+		for _, d := range g.Pkgs[p].Deps {
+			fmt.Fprintf(w, "imports(%s, %s).\n", q(p), q(d))
+		}
+	}
+
+	for _, p := range g.Order {
+		pkg := g.Pkgs[p]
+		for _, d := range pkg.Deps {
+			for _, qual := range pkg.Qualifiers[d] {
+				fmt.Fprintf(w, "qualified(%s, %s, %s).\n", q(p), q(d), q(qual))
+			}
+		}
+	}
+
+	for _, p := range g.Order {
+		for _, s := range g.Pkgs[p].Sources {
+			fmt.Fprintf(w, "generated_from(%s, %s).\n", q(p), q(s))
+		}
+	}
+
+	for _, p := range g.Order {
+		if name := groups.Of(p); name != "" {
+			fmt.Fprintf(w, "group(%s, %s).\n", q(p), q(name))
+		}
+	}
+
+	for _, p := range g.Order {
+		if g.Pkgs[p].TestSupport {
+			fmt.Fprintf(w, "test_support(%s).\n", q(p))
+		}
+	}
+
+	for _, p := range g.Order {
+		if g.Pkgs[p].Experimental {
+			fmt.Fprintf(w, "experimental(%s).\n", q(p))
+		}
+	}
+
+	for _, p := range g.Order {
+		if g.Pkgs[p].Cgo {
+			fmt.Fprintf(w, "cgo(%s).\n", q(p))
+		}
+	}
+}
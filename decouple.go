@@ -0,0 +1,517 @@
+package wuw
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+func RunDecouple(args []string) {
+	fs := flag.NewFlagSet("decouple", flag.ExitOnError)
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintln(w, "'wuw decouple' lists the functions and methods of pkgB that pkgA calls and writes a skeleton Go interface capturing that surface, as a starting point for inverting the dependency.")
+		fmt.Fprintf(w, "Usage: %s decouple [-opts] pkgA pkgB [dirs...]\nopts:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+
+	typedVar := fs.Bool("typed", false, "Type-check pkgA to find exactly what it uses of pkgB and where, e.g. as a struct field or only in function bodies (slower, needs pkgA to build)")
+	outVar := OutputFlag(fs)
+
+	ParseFlags(fs, args)
+
+	if fs.NArg() < 2 {
+		fmt.Fprintln(os.Stderr, "Need the importing and imported packages. Displaying usage...")
+		fs.Usage()
+		os.Exit(1)
+	}
+	from, to := fs.Arg(0), fs.Arg(1)
+
+	dirs := fs.Args()[2:]
+	if len(dirs) == 0 {
+		dirs = []string{from, to}
+	}
+	pkgs, errs := ScanDirs(dirs, true)
+
+	PrintErrors(errs)
+
+	g := NewGraph(pkgs)
+	a, ok := g.Lookup(from)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "error: package %s was not scanned\n", from)
+		os.Exit(1)
+	}
+	b, ok := g.Lookup(to)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "error: package %s was not scanned\n", to)
+		os.Exit(1)
+	}
+	if !slices.Contains(a.Deps, b.ImportPath) {
+		fmt.Fprintf(os.Stderr, "error: %s does not import %s\n", a.ImportPath, b.ImportPath)
+		os.Exit(1)
+	}
+
+	var surface *Surface
+	if *typedVar {
+		tp, err := LoadTyped(a.Path)
+		if err != nil {
+			Fatal(err)
+		}
+		for _, err := range tp.Errors {
+			fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+		}
+		uses := TypedUses(tp, b.ImportPath)
+		fmt.Fprintf(os.Stderr, "%s uses of %s:\n", a.ImportPath, b.ImportPath)
+		WriteTypedUses(os.Stderr, uses)
+		surface = TypedSurface(b, uses)
+	} else {
+		surface = CalledSurface(a, b)
+	}
+
+	if surface.Empty() {
+		fmt.Fprintf(os.Stderr, "error: %s calls no functions or methods of %s, so there is no interface to write\n", a.ImportPath, b.ImportPath)
+		os.Exit(1)
+	}
+
+	fmt.Fprintf(os.Stderr, "%s calls into %s:\n", a.ImportPath, b.ImportPath)
+	for _, f := range surface.Funcs {
+		fmt.Fprintf(os.Stderr, "\tfunc %s\n", f.Name.Name)
+	}
+	for _, t := range surface.Types() {
+		for _, m := range surface.Methods[t] {
+			fmt.Fprintf(os.Stderr, "\tmethod %s.%s\n", t, m.Name.Name)
+		}
+	}
+
+	src, err := surface.Interfaces(a.Name)
+	if err != nil {
+		Fatal(err)
+	}
+
+	w := OpenOutput(*outVar)
+	if _, err := w.Write(src); err != nil {
+		Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		Fatal(err)
+	}
+}
+
+type Surface struct {
+	Pkg     *Package
+	Funcs   []*ast.FuncDecl
+	Methods map[string][]*ast.FuncDecl
+
+	// types and imports declared by the files of Pkg, used to qualify the
+	// signatures when they are copied into another package
+	types   map[string]bool
+	imports map[string]string
+	fset    *token.FileSet
+}
+
+// Empty reports whether s has no functions or methods, as when only the vars,
+// consts and types of its package are used.
+func (s *Surface) Empty() bool {
+	return len(s.Funcs) == 0 && len(s.Methods) == 0
+}
+
+func (s *Surface) Types() []string {
+	var ret []string
+	for t := range s.Methods {
+		ret = append(ret, t)
+	}
+	slices.Sort(ret)
+	return ret
+}
+
+// CalledSurface finds the package-level functions of b that a calls, plus the
+// methods a calls on values it declares with one of b's types. Method calls
+// are matched by name, so values whose type is only inferred from something
+// other than a constructor or composite literal are missed.
+func CalledSurface(a, b *Package) *Surface {
+	s, funcs, methods := ParseSurface(b)
+
+	usedFuncs := make(map[string]bool)
+	usedMethods := make(map[string]map[string]bool)
+
+	fset := token.NewFileSet()
+	for _, name := range a.Files {
+		f, err := parser.ParseFile(fset, name, nil, 0)
+		if err != nil {
+			continue
+		}
+
+		var local string
+		for _, imp := range f.Imports {
+			if p, err := strconv.Unquote(imp.Path.Value); err == nil && p == b.ImportPath {
+				local = b.Name
+				if imp.Name != nil {
+					local = imp.Name.Name
+				}
+			}
+		}
+		if local == "" || local == "_" || local == "." {
+			continue
+		}
+
+		// typeOf returns the name of b's type that expr refers to, if any
+		typeOf := func(expr ast.Expr) string {
+			if star, ok := expr.(*ast.StarExpr); ok {
+				expr = star.X
+			}
+			if sel, ok := expr.(*ast.SelectorExpr); ok {
+				if x, ok := sel.X.(*ast.Ident); ok && x.Name == local && x.Obj == nil {
+					return sel.Sel.Name
+				}
+			}
+			return ""
+		}
+
+		typed := make(map[string]string)
+		ast.Inspect(f, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.Field:
+				if t := typeOf(n.Type); t != "" {
+					for _, name := range n.Names {
+						typed[name.Name] = t
+					}
+				}
+			case *ast.ValueSpec:
+				if t := typeOf(n.Type); t != "" {
+					for _, name := range n.Names {
+						typed[name.Name] = t
+					}
+				}
+			case *ast.AssignStmt:
+				if len(n.Lhs) != len(n.Rhs) {
+					break
+				}
+				for i, rhs := range n.Rhs {
+					id, ok := n.Lhs[i].(*ast.Ident)
+					if !ok {
+						continue
+					}
+					if u, ok := rhs.(*ast.UnaryExpr); ok && u.Op == token.AND {
+						rhs = u.X
+					}
+					switch rhs := rhs.(type) {
+					case *ast.CompositeLit:
+						if t := typeOf(rhs.Type); t != "" {
+							typed[id.Name] = t
+						}
+					case *ast.CallExpr:
+						if fn := typeOf(rhs.Fun); fn != "" {
+							if decl, ok := funcs[fn]; ok && decl.Type.Results != nil {
+								if t := ResultType(decl); t != "" {
+									typed[id.Name] = t
+								}
+							}
+						}
+					}
+				}
+			}
+			return true
+		})
+
+		ast.Inspect(f, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			sel, ok := call.Fun.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+
+			var recv string
+			switch x := sel.X.(type) {
+			case *ast.Ident:
+				if x.Name == local && x.Obj == nil {
+					if _, ok := funcs[sel.Sel.Name]; ok {
+						usedFuncs[sel.Sel.Name] = true
+					}
+					return true
+				}
+				recv = x.Name
+			case *ast.SelectorExpr:
+				recv = x.Sel.Name
+			}
+
+			t := typed[recv]
+			if _, ok := methods[t][sel.Sel.Name]; ok {
+				if usedMethods[t] == nil {
+					usedMethods[t] = make(map[string]bool)
+				}
+				usedMethods[t][sel.Sel.Name] = true
+			}
+			return true
+		})
+	}
+
+	s.Use(funcs, methods, usedFuncs, usedMethods)
+	return s
+}
+
+// ParseSurface reads the exported functions of b, by name, and methods, by
+// receiver type and name, for a Surface to be picked from.
+func ParseSurface(b *Package) (s *Surface, funcs map[string]*ast.FuncDecl, methods map[string]map[string]*ast.FuncDecl) {
+	s = &Surface{
+		Pkg:     b,
+		Methods: make(map[string][]*ast.FuncDecl),
+		types:   make(map[string]bool),
+		imports: make(map[string]string),
+		fset:    token.NewFileSet(),
+	}
+
+	funcs = make(map[string]*ast.FuncDecl)
+	methods = make(map[string]map[string]*ast.FuncDecl)
+	for _, name := range b.Files {
+		f, err := parser.ParseFile(s.fset, name, nil, parser.SkipObjectResolution)
+		if err != nil || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+
+		for _, imp := range f.Imports {
+			p, err := strconv.Unquote(imp.Path.Value)
+			if err != nil {
+				continue
+			}
+			local := ImportName(p)
+			if imp.Name != nil {
+				local = imp.Name.Name
+			}
+			s.imports[local] = p
+		}
+
+		for _, decl := range f.Decls {
+			switch decl := decl.(type) {
+			case *ast.FuncDecl:
+				if !decl.Name.IsExported() {
+					continue
+				}
+				if decl.Recv == nil {
+					funcs[decl.Name.Name] = decl
+					continue
+				}
+				t := ReceiverType(decl)
+				if methods[t] == nil {
+					methods[t] = make(map[string]*ast.FuncDecl)
+				}
+				methods[t][decl.Name.Name] = decl
+			case *ast.GenDecl:
+				for _, spec := range decl.Specs {
+					if spec, ok := spec.(*ast.TypeSpec); ok {
+						s.types[spec.Name.Name] = true
+					}
+				}
+			}
+		}
+	}
+	return s, funcs, methods
+}
+
+// Use adds the used functions and methods to s, taking their declarations
+// from funcs and methods.
+func (s *Surface) Use(funcs map[string]*ast.FuncDecl, methods map[string]map[string]*ast.FuncDecl, usedFuncs map[string]bool, usedMethods map[string]map[string]bool) {
+	for name := range usedFuncs {
+		s.Funcs = append(s.Funcs, funcs[name])
+	}
+	slices.SortFunc(s.Funcs, func(x, y *ast.FuncDecl) int { return strings.Compare(x.Name.Name, y.Name.Name) })
+
+	for t, ms := range usedMethods {
+		for name := range ms {
+			s.Methods[t] = append(s.Methods[t], methods[t][name])
+		}
+		slices.SortFunc(s.Methods[t], func(x, y *ast.FuncDecl) int { return strings.Compare(x.Name.Name, y.Name.Name) })
+	}
+}
+
+func ReceiverType(decl *ast.FuncDecl) string {
+	expr := decl.Recv.List[0].Type
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+	switch e := expr.(type) {
+	case *ast.IndexExpr:
+		expr = e.X
+	case *ast.IndexListExpr:
+		expr = e.X
+	}
+	if id, ok := expr.(*ast.Ident); ok {
+		return id.Name
+	}
+	return ""
+}
+
+// IsGeneric reports whether decl has type parameters, its own or, for a
+// method, those of its receiver type.
+func IsGeneric(decl *ast.FuncDecl) bool {
+	if decl.Type.TypeParams != nil {
+		return true
+	}
+	if decl.Recv == nil {
+		return false
+	}
+	expr := decl.Recv.List[0].Type
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+	switch expr.(type) {
+	case *ast.IndexExpr, *ast.IndexListExpr:
+		return true
+	}
+	return false
+}
+
+// ResultType returns the type name of the first result of a constructor-like
+// function such as func NewClient() *Client or func NewBox[T any]() *Box[T].
+func ResultType(decl *ast.FuncDecl) string {
+	expr := decl.Type.Results.List[0].Type
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+	switch e := expr.(type) {
+	case *ast.IndexExpr:
+		expr = e.X
+	case *ast.IndexListExpr:
+		expr = e.X
+	}
+	if id, ok := expr.(*ast.Ident); ok {
+		return id.Name
+	}
+	return ""
+}
+
+// Interfaces renders the surface as a Go source file in package pkgName, with
+// one interface for the package-level functions and one per receiver type.
+func (s *Surface) Interfaces(pkgName string) ([]byte, error) {
+	used := make(map[string]bool)
+
+	var body bytes.Buffer
+	prefix := Exported(s.Pkg.Name)
+
+	writeMethod := func(f *ast.FuncDecl) {
+		if IsGeneric(f) {
+			// interface methods can't have type parameters, so leave it
+			// to the user, without importing what only it needs
+			var sig bytes.Buffer
+			printer.Fprint(&sig, s.fset, s.qualify(f.Type, make(map[string]bool)))
+			fmt.Fprintf(&body, "\t// TODO: %s%s is generic, which interface methods can't be\n", f.Name.Name, strings.TrimPrefix(sig.String(), "func"))
+			return
+		}
+		typ := s.qualify(f.Type, used).(*ast.FuncType)
+		var sig bytes.Buffer
+		printer.Fprint(&sig, s.fset, typ)
+		fmt.Fprintf(&body, "\t%s%s\n", f.Name.Name, strings.TrimPrefix(sig.String(), "func"))
+	}
+
+	if len(s.Funcs) != 0 {
+		fmt.Fprintf(&body, "// %sAPI is the surface of the package-level functions of %s that are in use.\n", prefix, s.Pkg.ImportPath)
+		fmt.Fprintf(&body, "type %sAPI interface {\n", prefix)
+		for _, f := range s.Funcs {
+			writeMethod(f)
+		}
+		fmt.Fprint(&body, "}\n\n")
+	}
+
+	for _, t := range s.Types() {
+		fmt.Fprintf(&body, "// %s%s is the surface of %s.%s that is in use.\n", prefix, Exported(t), s.Pkg.Name, t)
+		fmt.Fprintf(&body, "type %s%s interface {\n", prefix, Exported(t))
+		for _, m := range s.Methods[t] {
+			writeMethod(m)
+		}
+		fmt.Fprint(&body, "}\n\n")
+	}
+
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "// Code generated by wuw decouple. Edit as needed.\n\npackage %s\n\n", pkgName)
+
+	var imports []string
+	for local := range used {
+		imports = append(imports, local)
+	}
+	slices.Sort(imports)
+	if len(imports) != 0 {
+		fmt.Fprintln(&src, "import (")
+		for _, local := range imports {
+			p := s.imports[local]
+			if local == s.Pkg.Name {
+				p = s.Pkg.ImportPath
+			}
+			if ImportName(p) == local {
+				fmt.Fprintf(&src, "\t%q\n", p)
+			} else {
+				fmt.Fprintf(&src, "\t%s %q\n", local, p)
+			}
+		}
+		fmt.Fprint(&src, ")\n\n")
+	}
+	src.Write(body.Bytes())
+
+	return format.Source(src.Bytes())
+}
+
+// qualify rewrites references to the types of the surface's package so they
+// can be used from another package, and records which imports the
+// expression needs.
+func (s *Surface) qualify(expr ast.Expr, used map[string]bool) ast.Expr {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		if s.types[e.Name] {
+			used[s.Pkg.Name] = true
+			return &ast.SelectorExpr{X: ast.NewIdent(s.Pkg.Name), Sel: ast.NewIdent(e.Name)}
+		}
+	case *ast.SelectorExpr:
+		if x, ok := e.X.(*ast.Ident); ok {
+			used[x.Name] = true
+		}
+	case *ast.StarExpr:
+		e.X = s.qualify(e.X, used)
+	case *ast.ArrayType:
+		e.Elt = s.qualify(e.Elt, used)
+	case *ast.MapType:
+		e.Key = s.qualify(e.Key, used)
+		e.Value = s.qualify(e.Value, used)
+	case *ast.ChanType:
+		e.Value = s.qualify(e.Value, used)
+	case *ast.Ellipsis:
+		e.Elt = s.qualify(e.Elt, used)
+	case *ast.IndexExpr:
+		e.X = s.qualify(e.X, used)
+		e.Index = s.qualify(e.Index, used)
+	case *ast.IndexListExpr:
+		e.X = s.qualify(e.X, used)
+		for i := range e.Indices {
+			e.Indices[i] = s.qualify(e.Indices[i], used)
+		}
+	case *ast.FuncType:
+		for _, fl := range []*ast.FieldList{e.Params, e.Results} {
+			if fl == nil {
+				continue
+			}
+			for _, f := range fl.List {
+				f.Type = s.qualify(f.Type, used)
+			}
+		}
+	}
+	return expr
+}
+
+func Exported(name string) string {
+	if name == "" {
+		return name
+	}
+	r := []rune(name)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
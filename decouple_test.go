@@ -0,0 +1,100 @@
+package wuw
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writePackage writes the files of a package named name to a dir of tmp,
+// returning it as scanned.
+func writePackage(t *testing.T, tmp, name string, files map[string]string) *Package {
+	t.Helper()
+
+	dir := filepath.Join(tmp, name)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	pkg := &Package{Name: name, Path: dir, ImportPath: "example.com/m/" + name}
+	for file, src := range files {
+		path := filepath.Join(dir, file)
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		pkg.Files = append(pkg.Files, path)
+	}
+	return pkg
+}
+
+func TestDecoupleGeneric(t *testing.T) {
+	tmp := t.TempDir()
+	svc := writePackage(t, tmp, "svc", map[string]string{"svc.go": `package svc
+
+import "io"
+
+type Box[T any] struct{ v T }
+
+func NewBox[T any](v T) *Box[T] { return &Box[T]{v} }
+
+func (b *Box[T]) Get() T { return b.v }
+
+func Map[T any](xs []T, f func(T) T) []T { return xs }
+
+func Copy(w io.Writer) error { return nil }
+`})
+	api := writePackage(t, tmp, "api", map[string]string{"api.go": `package api
+
+import (
+	"os"
+
+	"example.com/m/svc"
+)
+
+func Run() {
+	svc.Map([]int{1}, func(i int) int { return i })
+	svc.Copy(os.Stdout)
+	b := svc.NewBox(1)
+	b.Get()
+}
+`})
+
+	surface := CalledSurface(api, svc)
+	src, err := surface.Interfaces("api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(src)
+	for _, want := range []string{
+		"\tCopy(w io.Writer) error\n",
+		"\t// TODO: Map[T any](xs []T, f func(T) T) []T is generic, which interface methods can't be\n",
+		"\t// TODO: NewBox[T any](v T) *svc.Box[T] is generic, which interface methods can't be\n",
+		"type SvcBox interface {\n\t// TODO: Get() T is generic, which interface methods can't be\n}\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Interfaces() lacks %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestDecoupleNoCalls(t *testing.T) {
+	tmp := t.TempDir()
+	svc := writePackage(t, tmp, "svc", map[string]string{"svc.go": `package svc
+
+type Config struct{ Name string }
+
+var Default = Config{Name: "default"}
+
+func Load() Config { return Default }
+`})
+	api := writePackage(t, tmp, "api", map[string]string{"api.go": `package api
+
+import "example.com/m/svc"
+
+var c svc.Config = svc.Default
+`})
+
+	if surface := CalledSurface(api, svc); !surface.Empty() {
+		t.Errorf("CalledSurface() = %d funcs and methods of %d types, want none", len(surface.Funcs), len(surface.Methods))
+	}
+}
@@ -0,0 +1,35 @@
+package wuw
+
+import (
+	"io/fs"
+	"slices"
+)
+
+// DIWiring reports whether the Go file at name, importing imports, wires up
+// a dependency injection container rather than using what it imports: a
+// google/wire injector built with the wireinject tag, the code Wire generates
+// from it, or a file importing a DI framework such as uber/dig or fx to
+// register providers. Such files import every provider of the container, so
+// the imports only they have aren't dependencies in the usual sense.
+func DIWiring(fsys fs.FS, name string, imports []string) bool {
+	if slices.ContainsFunc(imports, func(i string) bool {
+		f, ok := FrameworkOf(i)
+		return ok && f.Kind == "di"
+	}) {
+		return true
+	}
+	if tool, _, ok := ReadGeneratedHeader(fsys, name); ok && tool == "Wire" {
+		return true
+	}
+	if x := ReadConstraint(fsys, name); x != nil {
+		// Eval calls ok for every tag of x, not stopping at the first
+		// that settles it
+		wireinject := false
+		x.Eval(func(tag string) bool {
+			wireinject = wireinject || tag == "wireinject"
+			return true
+		})
+		return wireinject
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package wuw
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// DiagramInfo is what a diagram says about itself, so that it still makes
+// sense once it has been pasted somewhere without the command that made it.
+type DiagramInfo struct {
+	ScannedAt time.Time
+	Commit    string
+	// Filters are the flags the diagram was made with.
+	Filters []string
+	Legend  []LegendEntry
+	// Notes are what the diagram leaves out, such as the packages past
+	// -max-nodes.
+	Notes []string
+}
+
+// LegendEntry explains what nodes of a Shape, and Color if set, stand for.
+type LegendEntry struct {
+	Shape string
+	Label string
+	Color string
+}
+
+// LegendFlag registers the -legend flag on fs.
+func LegendFlag(fs *flag.FlagSet) *bool {
+	return fs.Bool("legend", true, "Include a legend, the scan time, commit and flags used in diagrams")
+}
+
+// NewDiagramInfo describes a diagram scanned at scannedAt with the flags set
+// on fs, leaving out those that only pick where the output goes.
+func NewDiagramInfo(fs *flag.FlagSet, scannedAt time.Time, legend []LegendEntry) *DiagramInfo {
+	info := &DiagramInfo{ScannedAt: scannedAt, Legend: legend}
+	if v, err := OpenVCS("."); err == nil {
+		if commit, err := v.Head(); err == nil {
+			info.Commit = commit
+		}
+	}
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "o", "legend", "format", "dot", "json", "yaml", "plantuml", "html", "svg", "tree", "matrix", "csv", "markdown", "per-main", "schema", "category", "rule-status", "coverage", "max-output-lines":
+			return
+		}
+		if IsBoolFlag(f) && f.Value.String() == "true" {
+			info.Filters = append(info.Filters, "-"+f.Name)
+		} else {
+			info.Filters = append(info.Filters, fmt.Sprintf("-%s=%s", f.Name, f.Value))
+		}
+	})
+	return info
+}
+
+// Lines returns the metadata of the diagram as short lines of text.
+func (d *DiagramInfo) Lines() []string {
+	var ret []string
+	if !d.ScannedAt.IsZero() {
+		ret = append(ret, "scanned "+d.ScannedAt.Format(time.RFC3339))
+	}
+	if d.Commit != "" {
+		ret = append(ret, "commit "+d.Commit)
+	}
+	if len(d.Filters) != 0 {
+		ret = append(ret, "flags "+strings.Join(d.Filters, " "))
+	}
+	return append(ret, d.Notes...)
+}
+
+// WriteDotLegend writes the legend of d as a cluster of a Graphviz graph,
+// with the metadata as its label.
+func WriteDotLegend(w io.Writer, d *DiagramInfo) {
+	q := DotQuote
+
+	fmt.Fprintln(w, "\tsubgraph cluster_legend {")
+	fmt.Fprintf(w, "\t\tlabel=%s;\n", q(strings.Join(append([]string{"legend"}, d.Lines()...), "\n")))
+	fmt.Fprintln(w, "\t\tstyle=dashed;")
+	for i, e := range d.Legend {
+		if e.Color != "" {
+			fmt.Fprintf(w, "\t\t%s [shape=%s, label=%s, color=%s];\n", q(fmt.Sprintf("legend:%d", i)), e.Shape, q(e.Label), q(e.Color))
+		} else {
+			fmt.Fprintf(w, "\t\t%s [shape=%s, label=%s];\n", q(fmt.Sprintf("legend:%d", i)), e.Shape, q(e.Label))
+		}
+	}
+	fmt.Fprintln(w, "\t}")
+}
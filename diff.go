@@ -0,0 +1,326 @@
+package wuw
+
+import (
+	"flag"
+	"fmt"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ChangeKind weighs a change to the imports between two versions of the
+// tree by how much it says about the architecture.
+type ChangeKind int
+
+const (
+	// ChangeStructural is a change to the shape of the internal graph: a
+	// package or internal edge added or removed, a cycle created or a
+	// layering rule newly broken.
+	ChangeStructural ChangeKind = iota
+	// ChangeDependency is an external import added or removed.
+	ChangeDependency
+	// ChangeCosmetic is a change to how imports are written that leaves
+	// what is imported alone, like a renamed alias or a reordering.
+	ChangeCosmetic
+)
+
+var changeHeadings = []string{
+	ChangeStructural: "structural changes",
+	ChangeDependency: "dependency changes",
+	ChangeCosmetic:   "cosmetic changes",
+}
+
+type Change struct {
+	Kind ChangeKind
+	Desc string
+}
+
+func RunDiff(args []string) {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintln(w, "'wuw diff' compares the imports of the scanned dirs with those at a git ref, weighing structural changes above cosmetic ones.")
+		fmt.Fprintf(w, "Usage: %s diff [-opts] [dirs...]\nopts:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+
+	baseVar := fs.String("base", "HEAD", "Git `ref` to compare against")
+	configVar := fs.String("config", DefaultConfig, "Config file to read layering rules from")
+	structuralVar := fs.Bool("structural-only", false, "Only report structural changes, printing nothing if there are none")
+	noStdVar := fs.Bool("no-std", false, "Exclude stdlib packages (including golang.org/x/)")
+	testsVar := fs.Bool("tests", true, "Compare the imports of _test.go files too; -tests=false leaves them out on both sides")
+	TagsFlag(fs)
+	outVar := OutputFlag(fs)
+
+	ParseFlags(fs, args)
+
+	// without a config, there are just no layering rules to break
+	c, err := LoadConfig(*configVar)
+	if os.IsNotExist(err) {
+		c = &Config{}
+	} else if err != nil {
+		Fatal(err)
+	}
+
+	raw := ReadRawArgs(fs.Args(), fs.Usage)
+	s := NewScanner(*noStdVar)
+	s.NoTests = !*testsVar
+	pkgs, errs := s.Scan(ExpandDirs(raw))
+	PrintErrors(errs)
+	if err := MarkExperimental(pkgs); err != nil {
+		Fatal(err)
+	}
+
+	v, err := OpenVCS(".")
+	if err != nil {
+		Fatal(err)
+	}
+	basePkgs, baseFS, errs, err := ScanBase(v, s, *baseVar, raw)
+	if err != nil {
+		Fatal(err)
+	}
+	PrintErrors(errs)
+	if err := MarkExperimental(basePkgs); err != nil {
+		Fatal(err)
+	}
+
+	changes := DiffImports(c, pkgs, basePkgs, baseFS)
+
+	w := OpenOutput(*outVar)
+	defer w.Close()
+
+	for kind, heading := range changeHeadings {
+		if *structuralVar && ChangeKind(kind) != ChangeStructural {
+			break
+		}
+
+		var descs []string
+		for _, ch := range changes {
+			if ch.Kind == ChangeKind(kind) {
+				descs = append(descs, ch.Desc)
+			}
+		}
+		if *structuralVar && len(descs) == 0 {
+			break
+		}
+
+		fmt.Fprintf(w, "%s (%d):\n", heading, len(descs))
+		for _, d := range descs {
+			fmt.Fprintf(w, "\t%s\n", d)
+		}
+	}
+}
+
+// ScanBase scans the dirs args name in the tree of v at ref, with the
+// settings of s, expanding dir/... against that tree so the packages removed
+// since are scanned too. The dirs, and the paths of the packages, are
+// relative to the root of the repo, whose file system at ref is returned
+// along with them.
+func ScanBase(v VCS, s *Scanner, ref string, args []string) ([]Package, fs.FS, []error, error) {
+	root, err := v.Root()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	// opened at the root for the go.mod files above the working dir
+	if v, err = OpenVCS(root); err != nil {
+		return nil, nil, nil, err
+	}
+	rel := func(dir string) (string, error) {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return "", err
+		}
+		rel, err := filepath.Rel(root, abs)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return "", fmt.Errorf("%s is not in the repo at %s", dir, root)
+		}
+		return rel, nil
+	}
+
+	var dirs []string
+	var files []string
+	for _, a := range args {
+		dir, pattern := strings.CutSuffix(a, "...")
+		if pattern && dir != "" && !strings.HasSuffix(dir, "/") {
+			dir, pattern = a, false
+		}
+		// the dir of a bare ... is "", which cleans to "."
+		dir, err := rel(filepath.Clean(dir))
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if !pattern {
+			if !slices.Contains(dirs, dir) {
+				dirs = append(dirs, dir)
+			}
+			continue
+		}
+
+		if files == nil {
+			if files, err = v.Files(ref); err != nil {
+				return nil, nil, nil, err
+			}
+		}
+		for _, f := range files {
+			d := filepath.Dir(f)
+			below, err := filepath.Rel(dir, d)
+			if filepath.Ext(f) != ".go" || err != nil || strings.HasPrefix(below, "..") {
+				continue
+			}
+			if _, rule := skipPath(dir, below); rule == "" && !slices.Contains(dirs, d) {
+				dirs = append(dirs, d)
+			}
+		}
+	}
+	slices.Sort(dirs)
+
+	fsys, err := RefFS(v, ref, dirs)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	base := *s
+	base.FS = fsys
+	base.Coverage = nil
+	pkgs, errs := base.Scan(dirs)
+	return pkgs, fsys, errs, nil
+}
+
+// DiffImports compares pkgs with basePkgs, the packages at base whose files
+// are in baseFS, checking the layering rules of c on both sides.
+func DiffImports(c *Config, pkgs, basePkgs []Package, baseFS fs.FS) []Change {
+	before, after := NewGraph(basePkgs), NewGraph(pkgs)
+
+	var changes []Change
+	add := func(kind ChangeKind, format string, args ...any) {
+		changes = append(changes, Change{Kind: kind, Desc: fmt.Sprintf(format, args...)})
+	}
+
+	for _, p := range after.Order {
+		if _, ok := before.Pkgs[p]; !ok {
+			add(ChangeStructural, "+ package %s", p)
+		}
+	}
+	for _, p := range before.Order {
+		if _, ok := after.Pkgs[p]; !ok {
+			add(ChangeStructural, "- package %s", p)
+		}
+	}
+
+	for _, p := range after.Order {
+		old, ok := before.Pkgs[p]
+		if !ok {
+			continue
+		}
+		for _, d := range after.Pkgs[p].Deps {
+			if slices.Contains(old.Deps, d) {
+				continue
+			}
+			if after.IsInternal(d) {
+				add(ChangeStructural, "+ %s -> %s", p, d)
+			} else {
+				add(ChangeDependency, "+ %s -> %s", p, d)
+			}
+		}
+		for _, d := range old.Deps {
+			if slices.Contains(after.Pkgs[p].Deps, d) {
+				continue
+			}
+			if before.IsInternal(d) {
+				add(ChangeStructural, "- %s -> %s", p, d)
+			} else {
+				add(ChangeDependency, "- %s -> %s", p, d)
+			}
+		}
+	}
+
+	for _, cycle := range after.Cycles() {
+		if !slices.ContainsFunc(before.Cycles(), func(o []string) bool { return SameMembers(o, cycle) }) {
+			add(ChangeStructural, "+ cycle %s", strings.Join(cycle, ", "))
+		}
+	}
+
+	existing := make(map[Edge]bool)
+	for _, v := range before.Violations() {
+		existing[v] = true
+	}
+	for _, v := range after.Violations() {
+		if !existing[v] {
+			add(ChangeStructural, "+ internal visibility violation %s -> %s", v.From, v.To)
+		}
+	}
+
+	broken := make(map[Violation]bool)
+	for _, v := range c.Check(before) {
+		broken[v] = true
+	}
+	for _, v := range c.Check(after) {
+		if !broken[v] {
+			add(ChangeStructural, "+ %s -> %s breaks rule %s", v.From, v.To, v.Rule.Name)
+		}
+	}
+
+	for _, p := range after.Order {
+		old, ok := before.Pkgs[p]
+		if !ok {
+			continue
+		}
+		for _, name := range after.Pkgs[p].Files {
+			i := slices.IndexFunc(old.Files, func(f string) bool { return filepath.Base(f) == filepath.Base(name) })
+			if i < 0 {
+				continue
+			}
+			src, err := fs.ReadFile(baseFS, filepath.ToSlash(old.Files[i]))
+			if err != nil {
+				continue
+			}
+			was := FileImportSites(token.NewFileSet(), old.Files[i], src)
+			for _, desc := range CosmeticChanges(was, FileImportSites(token.NewFileSet(), name, nil)) {
+				add(ChangeCosmetic, "%s: %s", name, desc)
+			}
+		}
+	}
+
+	return changes
+}
+
+// CosmeticChanges describes how the imports of a file were rewritten from
+// was to now without changing what they import. The imports added or removed
+// are left for the edges to report.
+func CosmeticChanges(was, now []ImportSite) []string {
+	var ret []string
+	var wasOrder, nowOrder []string
+	for _, n := range now {
+		i := slices.IndexFunc(was, func(s ImportSite) bool { return s.Path == n.Path })
+		if i < 0 {
+			continue
+		}
+		if was[i].Alias != n.Alias {
+			ret = append(ret, fmt.Sprintf("alias of %s %s -> %s", n.Path, aliasName(was[i].Alias), aliasName(n.Alias)))
+		}
+		nowOrder = append(nowOrder, n.Path)
+	}
+	for _, s := range was {
+		if slices.ContainsFunc(now, func(n ImportSite) bool { return n.Path == s.Path }) {
+			wasOrder = append(wasOrder, s.Path)
+		}
+	}
+	if !slices.Equal(wasOrder, nowOrder) {
+		ret = append(ret, "imports reordered")
+	}
+	return ret
+}
+
+func aliasName(alias string) string {
+	if alias == "" {
+		return "(none)"
+	}
+	return alias
+}
+
+// SameMembers reports whether a and b hold the same strings in any order.
+func SameMembers(a, b []string) bool {
+	return len(a) == len(b) && !slices.ContainsFunc(a, func(s string) bool { return !slices.Contains(b, s) })
+}
@@ -0,0 +1,87 @@
+package wuw
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// TestScanBase checks that the dirs given to wuw diff, as plain dirs or as
+// dir/... patterns and from the root of the repo or below it, name the same
+// dirs at the base.
+func TestScanBase(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("no git binary")
+	}
+
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	run := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=wuw", "-c", "user.email=wuw@example.com"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+	write := func(name, src string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	run("init", "-q")
+	write("go.mod", "module example.com/m\n")
+	write("a/a.go", "package a\n\nimport _ \"example.com/m/b\"\n")
+	write("a/inner/inner.go", "package inner\n")
+	write("b/b.go", "package b\n")
+	run("add", "-A")
+	run("commit", "-q", "-m", "init")
+
+	tests := []struct {
+		name string
+		wd   string
+		args []string
+		want []string
+	}{
+		{"dirs", ".", []string{"a", "b"}, []string{"a", "b"}},
+		{"dot slash", ".", []string{"./a"}, []string{"a"}},
+		{"trailing slash", ".", []string{"b/"}, []string{"b"}},
+		{"dot below the root", "a", []string{"."}, []string{"a"}},
+		{"parent below the root", "a/inner", []string{"../../b"}, []string{"b"}},
+		{"pattern", ".", []string{"./..."}, []string{"a", "a/inner", "b"}},
+		{"pattern below the root", "a", []string{"./..."}, []string{"a", "a/inner"}},
+		{"dir pattern", ".", []string{"a/..."}, []string{"a", "a/inner"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Chdir(filepath.Join(dir, tt.wd))
+			v, err := OpenVCS(".")
+			if err != nil {
+				t.Fatal(err)
+			}
+			pkgs, _, errs, err := ScanBase(v, NewScanner(false), "HEAD", tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, err := range errs {
+				t.Error(err)
+			}
+			var got []string
+			for _, p := range pkgs {
+				got = append(got, p.Path)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ScanBase(%q) scanned %q, want %q", tt.args, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,17 @@
+// Package wuw scans the packages of Go projects for what they import, and
+// draws and checks the import graph they make.
+//
+// A Scanner reads the packages of a list of dirs, calling its Hooks as it
+// goes, and NewGraph makes their Graph to query:
+//
+//	s := wuw.NewScanner(true)
+//	s.Hooks.OnPackage = func(p *wuw.Package) bool {
+//		fmt.Println(p.ImportPath)
+//		return true
+//	}
+//	pkgs, errs := s.Scan([]string{"./cmd/app", "./internal/store"})
+//	g := wuw.NewGraph(pkgs)
+//	fmt.Println(g.Importers("example.com/app/internal/store"), g.Cycles())
+//
+// The wuw command, in cmd/wuw, is Main.
+package wuw
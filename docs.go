@@ -0,0 +1,274 @@
+package wuw
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"strings"
+)
+
+// docsHeader marks a document as generated, for readers not to edit it.
+const docsHeader = "<!-- generated by 'wuw docs', edit .wuw.yaml and regenerate instead -->"
+
+// Area is a part of the tree the architecture docs have a section for.
+type Area struct {
+	Name     string
+	Packages []string
+}
+
+// Areas splits the packages of g by group, in the order of the groups, or
+// failing that by the dir right below their module, in the order they were
+// first come across.
+func Areas(g *Graph, groups *Groups) []*Area {
+	var ret []*Area
+	byName := make(map[string]*Area)
+	for _, p := range g.Order {
+		name := AreaOf(g, groups, p)
+		a, ok := byName[name]
+		if !ok {
+			a = &Area{Name: name}
+			byName[name] = a
+			ret = append(ret, a)
+		}
+		a.Packages = append(a.Packages, p)
+	}
+	if groups != nil {
+		order := append(groups.Names(), "ungrouped")
+		slices.SortStableFunc(ret, func(a, b *Area) int {
+			return slices.Index(order, a.Name) - slices.Index(order, b.Name)
+		})
+	}
+	return ret
+}
+
+// AreaOf returns the name of the area the package p falls in.
+func AreaOf(g *Graph, groups *Groups, p string) string {
+	if groups != nil {
+		if name := groups.Of(p); name != "" {
+			return name
+		}
+		return "ungrouped"
+	}
+	if m := FindModule(g.Pkgs[p].Path); m != nil && p != m.Path {
+		if rest, ok := strings.CutPrefix(p, m.Path+"/"); ok {
+			first, _, _ := strings.Cut(rest, "/")
+			return first
+		}
+	}
+	return path.Base(p)
+}
+
+// WriteDocs writes a Markdown architecture document of g: an overview of
+// its areas and how they depend on each other, a section per area with a
+// table and diagram of its packages, and the rules of c with how often they
+// are broken. Descriptions come from the config. Nothing in it depends on
+// when or at which commit it was generated, so it only changes along with
+// the code.
+func WriteDocs(w io.Writer, g *Graph, groups *Groups, c *Config) {
+	areas := Areas(g, groups)
+	areaOf := make(map[string]string)
+	for _, a := range areas {
+		for _, p := range a.Packages {
+			areaOf[p] = a.Name
+		}
+	}
+
+	kind := "Areas"
+	if groups != nil {
+		kind = "Layers"
+	}
+
+	m := g.Metrics()
+	fmt.Fprintln(w, "# Architecture")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, docsHeader)
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "%d packages in %d %s, with %d internal imports and %d external dependencies.\n", m.Packages, len(areas), strings.ToLower(kind), m.InternalEdges, m.ExternalDeps)
+	fmt.Fprintln(w)
+
+	fmt.Fprintf(w, "## %s\n\n", kind)
+	fmt.Fprintln(w, "| name | packages | depends on | description |")
+	fmt.Fprintln(w, "| --- | --- | --- | --- |")
+
+	var areaEdges []MermaidEdge
+	for _, a := range areas {
+		uses := make(map[string]int)
+		var names []string
+		for _, p := range a.Packages {
+			for _, d := range g.InternalDeps(p) {
+				if to := areaOf[d]; to != a.Name {
+					if uses[to] == 0 {
+						names = append(names, to)
+					}
+					uses[to]++
+				}
+			}
+		}
+		slices.Sort(names)
+		for _, to := range names {
+			areaEdges = append(areaEdges, MermaidEdge{Edge: Edge{From: "area:" + a.Name, To: "area:" + to}, Label: fmt.Sprint(uses[to])})
+		}
+		fmt.Fprintf(w, "| %s | %d | %s | %s |\n", markdownCell(a.Name), len(a.Packages), markdownCell(orNone(strings.Join(names, ", "))), markdownCell(c.Descriptions[a.Name]))
+	}
+	fmt.Fprintln(w)
+
+	var areaNodes []string
+	for _, a := range areas {
+		areaNodes = append(areaNodes, "area:"+a.Name)
+	}
+	fmt.Fprintln(w, "```mermaid")
+	WriteMermaid(w, areaNodes, func(n string) string { return strings.TrimPrefix(n, "area:") }, areaEdges)
+	fmt.Fprintln(w, "```")
+
+	for _, a := range areas {
+		fmt.Fprintf(w, "\n## %s\n\n", a.Name)
+		if d := c.Descriptions[a.Name]; d != "" {
+			fmt.Fprintf(w, "%s\n\n", d)
+		}
+
+		fmt.Fprintln(w, "| package | imports | imported by | external deps | description |")
+		fmt.Fprintln(w, "| --- | --- | --- | --- | --- |")
+
+		var edges []MermaidEdge
+		var nodes []string
+		for _, p := range a.Packages {
+			var external int
+			for _, d := range g.Pkgs[p].Deps {
+				if !g.IsInternal(d) {
+					external++
+				}
+			}
+			deps := g.InternalDeps(p)
+			fmt.Fprintf(w, "| `%s` | %s | %d | %d | %s |\n", p, markdownCell(orNone(strings.Join(deps, ", "))), len(g.Importers(p)), external, markdownCell(c.Descriptions[p]))
+
+			if !slices.Contains(nodes, p) {
+				nodes = append(nodes, p)
+			}
+			for _, d := range deps {
+				to := d
+				if areaOf[d] != a.Name {
+					to = "area:" + areaOf[d]
+				}
+				if !slices.Contains(nodes, to) {
+					nodes = append(nodes, to)
+				}
+				e := MermaidEdge{Edge: Edge{From: p, To: to}}
+				if !slices.Contains(edges, e) {
+					edges = append(edges, e)
+				}
+			}
+		}
+		fmt.Fprintln(w)
+
+		fmt.Fprintln(w, "```mermaid")
+		WriteMermaid(w, nodes, func(n string) string {
+			if name, ok := strings.CutPrefix(n, "area:"); ok {
+				return strings.ToLower(kind[:len(kind)-1]) + " " + name
+			}
+			return n
+		}, edges)
+		fmt.Fprintln(w, "```")
+	}
+
+	if len(c.Rules) == 0 {
+		return
+	}
+
+	broken := make(map[*Rule]int)
+	for _, v := range c.Check(g) {
+		broken[v.Rule]++
+	}
+
+	fmt.Fprintln(w, "\n## Rules")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "| rule | from | deny | allow | reason | violations |")
+	fmt.Fprintln(w, "| --- | --- | --- | --- | --- | --- |")
+	for _, r := range c.Rules {
+		fmt.Fprintf(w, "| %s | `%s` | %s | %s | %s | %d |\n", markdownCell(r.Name), markdownCell(r.From), markdownCode(r.Deny), markdownCode(r.Allow), markdownCell(r.Reason), broken[r])
+	}
+}
+
+// markdownCell escapes s for a cell of a Markdown table.
+func markdownCell(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+}
+
+func markdownCode(patterns []string) string {
+	var ret []string
+	for _, p := range patterns {
+		ret = append(ret, "`"+markdownCell(p)+"`")
+	}
+	return strings.Join(ret, ", ")
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func RunDocs(args []string) {
+	fs := flag.NewFlagSet("docs", flag.ExitOnError)
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintln(w, "'wuw docs' generates a Markdown architecture document with an overview of the layers or areas of the tree, a table and Mermaid diagram of each, and the import rules, for CI to keep up to date.")
+		fmt.Fprintf(w, "Usage: %s docs [-opts] [dirs...]\nopts:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+
+	configVar := fs.String("config", DefaultConfig, "Config file to read rules and descriptions from")
+	groupsVar := fs.String("groups", "", "Use the groups of this YAML `file` as the layers, instead of the dirs below the module")
+	checkVar := fs.Bool("check", false, "Instead of writing the document, exit with 1 if the one at -o is out of date")
+	outVar := OutputFlag(fs)
+
+	ParseFlags(fs, args)
+
+	c, err := LoadConfig(*configVar)
+	if os.IsNotExist(err) {
+		c = &Config{}
+	} else if err != nil {
+		Fatal(err)
+	}
+
+	var groups *Groups
+	if *groupsVar != "" {
+		if groups, err = LoadGroups(*groupsVar); err != nil {
+			Fatal(err)
+		}
+	}
+
+	dirs := ReadArgs(fs.Args(), fs.Usage)
+	pkgs, errs := ScanDirs(dirs, true)
+
+	PrintErrors(errs)
+
+	g := NewGraph(pkgs)
+
+	if *checkVar {
+		if *outVar == "" || *outVar == "-" {
+			fmt.Fprintln(os.Stderr, "error: -check needs -o")
+			os.Exit(1)
+		}
+		var b bytes.Buffer
+		WriteDocs(&b, g, groups, c)
+		old, err := os.ReadFile(*outVar)
+		if err != nil && !os.IsNotExist(err) {
+			Fatal(err)
+		}
+		if !bytes.Equal(old, b.Bytes()) {
+			fmt.Fprintf(os.Stderr, "%s is out of date, regenerate it with 'wuw docs -o %s'\n", *outVar, *outVar)
+			os.Exit(1)
+		}
+		return
+	}
+
+	w := OpenOutput(*outVar)
+	defer w.Close()
+
+	WriteDocs(w, g, groups, c)
+}
@@ -0,0 +1,87 @@
+package wuw
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+var dotLegend = []LegendEntry{
+	{Shape: "box", Label: "package"},
+	{Shape: "note", Label: "generated package"},
+}
+
+// WriteDot writes the internal packages of g and the imports between them as
+// a Graphviz graph, clustered by groups if there are any. Packages only there
+// to support tests are dashed, and experimental ones greyed out. Imports only
+// there to wire up dependency injection are dashed and labeled "di", so the
+// fan-out of a container package stands apart from what it really uses. With
+// status, packages and imports are colored by how they fare against the rules.
+func WriteDot(w io.Writer, g *Graph, groups *Groups, status *RuleStatus, info *DiagramInfo) {
+	q := DotQuote
+
+	fmt.Fprintln(w, "digraph wuw {")
+	fmt.Fprintln(w, "\tnode [shape=box];")
+
+	node := func(indent, p string) {
+		pkg := g.Pkgs[p]
+		var attrs []string
+		if len(pkg.Generated) != 0 && len(pkg.Generated) == len(pkg.Files) {
+			attrs = append(attrs, "shape=note")
+		}
+		if pkg.TestSupport {
+			attrs = append(attrs, "style=dashed")
+		}
+		if pkg.Experimental {
+			attrs = append(attrs, "color=gray", "fontcolor=gray")
+		}
+		if s := status.Of(p); s != "" {
+			attrs = append(attrs, "color="+q(statusColors[s]))
+		}
+		if len(attrs) != 0 {
+			fmt.Fprintf(w, "%s%s [%s];\n", indent, q(p), strings.Join(attrs, ", "))
+		} else {
+			fmt.Fprintf(w, "%s%s;\n", indent, q(p))
+		}
+	}
+
+	if groups != nil {
+		for i, name := range groups.Names() {
+			fmt.Fprintf(w, "\tsubgraph %s {\n", q(fmt.Sprintf("cluster_%d", i)))
+			fmt.Fprintf(w, "\t\tlabel=%s;\n", q(name))
+			for _, p := range g.Order {
+				if groups.Of(p) == name {
+					node("\t\t", p)
+				}
+			}
+			fmt.Fprintln(w, "\t}")
+		}
+	}
+	for _, p := range g.Order {
+		if groups.Of(p) == "" {
+			node("\t", p)
+		}
+	}
+
+	for _, p := range g.Order {
+		for _, d := range g.InternalDeps(p) {
+			var attrs []string
+			if slices.Contains(g.Pkgs[p].Qualifiers[d], QualifierDI) {
+				attrs = append(attrs, "style=dashed", "label="+q("di"))
+			}
+			if s := status.Edge(Edge{From: p, To: d}); s != "" {
+				attrs = append(attrs, "color="+q(statusColors[s]))
+			}
+			if len(attrs) != 0 {
+				fmt.Fprintf(w, "\t%s -> %s [%s];\n", q(p), q(d), strings.Join(attrs, ", "))
+			} else {
+				fmt.Fprintf(w, "\t%s -> %s;\n", q(p), q(d))
+			}
+		}
+	}
+	if info != nil {
+		WriteDotLegend(w, info)
+	}
+	fmt.Fprintln(w, "}")
+}
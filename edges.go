@@ -0,0 +1,157 @@
+package wuw
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+type RedundantEdge struct {
+	EdgeSymbols
+	Via []string
+}
+
+// RedundantEdges finds imports A -> C where A also reaches C through another
+// of its imports, and A uses at most maxSymbols distinct symbols of C, so the
+// direct import may not be pulling its weight.
+func RedundantEdges(g *Graph, maxSymbols int) []RedundantEdge {
+	var ret []RedundantEdge
+	for _, a := range g.Order {
+		deps := g.InternalDeps(a)
+		for _, c := range deps {
+			var via []string
+			for _, b := range deps {
+				if b == c {
+					continue
+				}
+				if p := g.Path(b, c); p != nil && (via == nil || len(p)+1 < len(via)) {
+					via = append([]string{a}, p...)
+				}
+			}
+			if via == nil {
+				continue
+			}
+
+			es := ClassifyEdge(g, a, c)
+			if len(es.Uses) > maxSymbols {
+				continue
+			}
+			ret = append(ret, RedundantEdge{EdgeSymbols: es, Via: via})
+		}
+	}
+	return ret
+}
+
+type SiblingCoupling struct {
+	EdgeSymbols
+	// Weight is how many times From refers to symbols of To.
+	Weight int
+}
+
+// SiblingCouplings finds pairs of packages in the same parent dir where one
+// imports the other, referring to it at least minUses times, and the other
+// doesn't import it back. Either the importer may belong inside the package
+// it leans on, or their parent is split up the wrong way. Heaviest first.
+func SiblingCouplings(g *Graph, minUses int) []SiblingCoupling {
+	var ret []SiblingCoupling
+	for _, a := range g.Order {
+		for _, b := range g.InternalDeps(a) {
+			if filepath.Dir(g.Pkgs[a].Path) != filepath.Dir(g.Pkgs[b].Path) || slices.Contains(g.Pkgs[b].Deps, a) {
+				continue
+			}
+
+			es := ClassifyEdge(g, a, b)
+			var weight int
+			for _, n := range es.Uses {
+				weight += n
+			}
+			if weight >= minUses {
+				ret = append(ret, SiblingCoupling{EdgeSymbols: es, Weight: weight})
+			}
+		}
+	}
+	slices.SortStableFunc(ret, func(x, y SiblingCoupling) int { return y.Weight - x.Weight })
+	return ret
+}
+
+func RunEdges(args []string) {
+	fs := flag.NewFlagSet("edges", flag.ExitOnError)
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintln(w, "'wuw edges' classifies every internal import by whether it only uses types, only constants, functions, or a mix, to find edges that can be broken with a small shared types package.")
+		fmt.Fprintf(w, "Usage: %s edges [-opts] [dirs...]\nopts:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+
+	redundantVar := fs.Bool("redundant", false, "Instead report direct imports that are also reached through another import and use few symbols")
+	maxSymbolsVar := fs.Int("max-symbols", 2, "With -redundant, the most distinct symbols a direct import can use and still be reported")
+	siblingsVar := fs.Bool("siblings", false, "Instead report packages leaning on a sibling in the same dir that doesn't import them back, heaviest first")
+	minUsesVar := fs.Int("min-uses", 5, "With -siblings, the fewest references to the sibling's symbols to be reported")
+	outVar := OutputFlag(fs)
+
+	ParseFlags(fs, args)
+
+	dirs := ReadArgs(fs.Args(), fs.Usage)
+	pkgs, errs := ScanDirs(dirs, true)
+
+	PrintErrors(errs)
+
+	g := NewGraph(pkgs)
+
+	w := OpenOutput(*outVar)
+	defer w.Close()
+
+	if *redundantVar {
+		for _, r := range RedundantEdges(g, *maxSymbolsVar) {
+			fmt.Fprintf(w, "%s -> %s\tuses %d symbols\n", r.From, r.To, len(r.Uses))
+			fmt.Fprintf(w, "\talso reached via %s\n", strings.Join(r.Via, " -> "))
+			if len(r.Uses) != 0 {
+				fmt.Fprintf(w, "\t%s\n", r.EdgeSymbols)
+			}
+		}
+		return
+	}
+
+	if *siblingsVar {
+		for _, c := range SiblingCouplings(g, *minUsesVar) {
+			fmt.Fprintf(w, "%s -> %s\t%d uses\n", c.From, c.To, c.Weight)
+			fmt.Fprintf(w, "\t%s\n", c.EdgeSymbols)
+		}
+		return
+	}
+
+	cycle := make(map[string]int)
+	for i, c := range g.Cycles() {
+		for _, p := range c {
+			cycle[p] = i + 1
+		}
+	}
+
+	var typesOnly []EdgeSymbols
+	for _, p := range g.Order {
+		for _, d := range g.InternalDeps(p) {
+			es := ClassifyEdge(g, p, d)
+			fmt.Fprintf(w, "%s -> %s\t%s\n", p, d, es.Kind)
+			if len(es.Uses) != 0 {
+				fmt.Fprintf(w, "\t%s\n", es)
+			}
+			if es.Kind == EdgeTypes {
+				typesOnly = append(typesOnly, es)
+			}
+		}
+	}
+
+	if len(typesOnly) != 0 {
+		fmt.Fprintf(w, "\ntypes-only edges (%d), candidates for a shared types package:\n", len(typesOnly))
+		for _, es := range typesOnly {
+			if c := cycle[es.From]; c != 0 && c == cycle[es.To] {
+				fmt.Fprintf(w, "\t%s -> %s (in a cycle)\n", es.From, es.To)
+			} else {
+				fmt.Fprintf(w, "\t%s -> %s\n", es.From, es.To)
+			}
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package wuw
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// embeddingHeader names the columns of the node features written for graph
+// ML tooling.
+var embeddingHeader = []string{"id", "path", "std", "internal", "external", "files", "imports", "importers", "generated", "test_support"}
+
+// EmbeddingNodes returns every package of g and every package they import,
+// numbered by their index, the scanned ones first.
+func EmbeddingNodes(g *Graph) []string {
+	nodes := append([]string{}, g.Order...)
+	seen := make(map[string]bool)
+	for _, p := range g.Order {
+		seen[p] = true
+	}
+	for _, p := range g.Order {
+		for _, d := range g.Pkgs[p].Deps {
+			if !seen[d] {
+				seen[d] = true
+				nodes = append(nodes, d)
+			}
+		}
+	}
+	return nodes
+}
+
+// WriteEmbeddingExport writes the graph to dir in the form graph ML
+// libraries load for node2vec and the like: nodes.csv numbering every
+// package with its features, and edges.csv listing the imports as pairs of
+// those numbers, with no header so that it reads as a plain edge list.
+func WriteEmbeddingExport(dir string, g *Graph) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	nodes := EmbeddingNodes(g)
+	ids := make(map[string]int, len(nodes))
+	for i, n := range nodes {
+		ids[n] = i
+	}
+	importers := make(map[string]int)
+	for _, p := range g.Order {
+		for _, d := range g.Pkgs[p].Deps {
+			importers[d]++
+		}
+	}
+
+	flag := func(b bool) string {
+		if b {
+			return "1"
+		}
+		return "0"
+	}
+	err := writeCSV(filepath.Join(dir, "nodes.csv"), func(w *csv.Writer) {
+		w.Write(embeddingHeader)
+		for i, n := range nodes {
+			category := g.Category(n)
+			row := []string{strconv.Itoa(i), n, flag(category == CategoryStd), flag(category == CategoryInternal), flag(category == CategoryExternal)}
+			var files, imports int
+			var generated, testSupport bool
+			if pkg, ok := g.Pkgs[n]; ok {
+				files, imports = len(pkg.Files), len(pkg.Deps)
+				generated = len(pkg.Generated) != 0 && len(pkg.Generated) == len(pkg.Files)
+				testSupport = pkg.TestSupport
+			}
+			row = append(row, strconv.Itoa(files), strconv.Itoa(imports), strconv.Itoa(importers[n]), flag(generated), flag(testSupport))
+			w.Write(row)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	return writeCSV(filepath.Join(dir, "edges.csv"), func(w *csv.Writer) {
+		for _, p := range g.Order {
+			for _, d := range g.Pkgs[p].Deps {
+				w.Write([]string{strconv.Itoa(ids[p]), strconv.Itoa(ids[d])})
+			}
+		}
+	})
+}
+
+func writeCSV(name string, write func(w *csv.Writer)) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	write(w)
+	w.Flush()
+	if err := w.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,46 @@
+package wuw
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DotQuote quotes s as a Graphviz ID. Graphviz only knows the \" escape in
+// IDs, and \n and friends in labels, so unlike strconv.Quote it leaves any
+// other character alone, non-ASCII included, replacing only invalid UTF-8.
+func DotQuote(s string) string {
+	s = strings.ToValidUTF8(s, "�")
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", "")
+	return `"` + r.Replace(s) + `"`
+}
+
+// MermaidID turns s into a Mermaid node ID, which may only hold ASCII
+// letters, digits and underscores. Every other character, underscores
+// included, is spelled out as _<hex>_ so distinct paths keep distinct IDs.
+func MermaidID(s string) string {
+	var b strings.Builder
+	b.WriteString("n_")
+	for _, r := range strings.ToValidUTF8(s, "�") {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		default:
+			fmt.Fprintf(&b, "_%x_", r)
+		}
+	}
+	return b.String()
+}
+
+// MermaidLabel quotes s as the text of a Mermaid node, using its entity
+// codes for the characters that would end or break up the label.
+func MermaidLabel(s string) string {
+	r := strings.NewReplacer(`"`, "#quot;", "#", "#35;", "<", "#lt;", ">", "#gt;", "\n", "<br>")
+	return `"` + r.Replace(strings.ToValidUTF8(s, "�")) + `"`
+}
+
+// D2Quote quotes s as a D2 string, in which backslashes and double quotes
+// are escaped and a newline is spelled \n.
+func D2Quote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", "")
+	return `"` + r.Replace(strings.ToValidUTF8(s, "�")) + `"`
+}
@@ -0,0 +1,111 @@
+package wuw
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDotQuote(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"example.com/app", `"example.com/app"`},
+		{"example.com/café x", `"example.com/café x"`},
+		{"example.com/日本", `"example.com/日本"`},
+		{`say "hi"`, `"say \"hi\""`},
+		{`C:\dir`, `"C:\\dir"`},
+		{"two\nlines\r", `"two\nlines"`},
+		{"bad\xffutf8", `"bad�utf8"`},
+	}
+	for _, tt := range tests {
+		if got := DotQuote(tt.in); got != tt.want {
+			t.Errorf("DotQuote(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMermaidID(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"app", "n_app"},
+		{"example.com/a_b", "n_example_2e_com_2f_a_5f_b"},
+		{"café x", "n_caf_e9__20_x"},
+		{"日本", "n__65e5__672c_"},
+	}
+	for _, tt := range tests {
+		if got := MermaidID(tt.in); got != tt.want {
+			t.Errorf("MermaidID(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+
+	// spelling out underscores keeps paths that only differ by them apart
+	if MermaidID("a_b") == MermaidID("a/b") {
+		t.Errorf("MermaidID(%q) and MermaidID(%q) are both %s", "a_b", "a/b", MermaidID("a_b"))
+	}
+}
+
+func TestMermaidLabel(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"example.com/café x", `"example.com/café x"`},
+		{`say "hi"`, `"say #quot;hi#quot;"`},
+		{"#1 <main>", `"#35;1 #lt;main#gt;"`},
+		{"two\nlines", `"two<br>lines"`},
+	}
+	for _, tt := range tests {
+		if got := MermaidLabel(tt.in); got != tt.want {
+			t.Errorf("MermaidLabel(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestD2Quote(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"example.com/日本/データ", `"example.com/日本/データ"`},
+		{"café x", `"café x"`},
+		{`say "hi"`, `"say \"hi\""`},
+		{`a\b`, `"a\\b"`},
+		{"two\nlines", `"two\nlines"`},
+	}
+	for _, tt := range tests {
+		if got := D2Quote(tt.in); got != tt.want {
+			t.Errorf("D2Quote(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+// TestGoldenUnicode renders testdata/unicode, whose dirs are named "café x"
+// and "日本/データ", in the formats quoting paths.
+func TestGoldenUnicode(t *testing.T) {
+	tests := []struct {
+		golden string
+		format string
+	}{
+		{"unicode-text.golden", "text"},
+		{"unicode-json.golden", "json"},
+		{"unicode-dot.golden", "dot"},
+		{"unicode-mermaid.golden", "mermaid"},
+		{"unicode-d2.golden", "d2"},
+		{"unicode-graphml.golden", "graphml"},
+		{"unicode-plantuml.golden", "plantuml"},
+	}
+
+	res := scanFixture(t, "unicode", []string{"café x", "日本/データ"}, nil)
+	for _, tt := range tests {
+		t.Run(tt.golden, func(t *testing.T) {
+			r, err := NewReporter(tt.format, ReporterOptions{Schema: JSONSchemaV2})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			if err := r.Report(&buf, res); err != nil {
+				t.Fatal(err)
+			}
+			checkGolden(t, tt.golden, buf.Bytes())
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package wuw
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// experimentalMarker, in the comments above the package clause of one of
+// its files, marks a package as experimental. Experimental packages are left
+// out of rule checks and aggregate metrics, and greyed out in graphs.
+const experimentalMarker = "//wuw:experimental"
+
+// HasExperimentalMarker reports whether the Go file at name carries the
+// experimental marker before its package clause.
+func HasExperimentalMarker(fsys fs.FS, name string) bool {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "package ") {
+			break
+		}
+		if line == experimentalMarker || strings.HasPrefix(line, experimentalMarker+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// MarkExperimental marks the packages of pkgs matching one of the
+// experimental regexes of the config, if there is one, on top of those
+// marked in their source.
+func MarkExperimental(pkgs []Package) error {
+	patterns, err := ExperimentalPatterns()
+	if err != nil {
+		return err
+	}
+	for i := range pkgs {
+		if IsExperimental(patterns, pkgs[i].ImportPath) {
+			pkgs[i].Experimental = true
+		}
+	}
+	return nil
+}
+
+// ExperimentalPatterns compiles the experimental regexes of the config, or
+// returns none if there is no config.
+func ExperimentalPatterns() ([]*regexp.Regexp, error) {
+	c, err := LoadConfig(DefaultConfig)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var patterns []*regexp.Regexp
+	for _, p := range c.Experimental {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("%s: experimental: %w", DefaultConfig, err)
+		}
+		patterns = append(patterns, re)
+	}
+	return patterns, nil
+}
+
+func IsExperimental(patterns []*regexp.Regexp, importPath string) bool {
+	return slices.ContainsFunc(patterns, func(re *regexp.Regexp) bool { return re.MatchString(importPath) })
+}
+
+// WithoutExperimental returns g without its experimental packages and the
+// imports of them, or g itself if it has none.
+func (g *Graph) WithoutExperimental() *Graph {
+	if !slices.ContainsFunc(g.Order, func(p string) bool { return g.Pkgs[p].Experimental }) {
+		return g
+	}
+	return g.Subgraph(func(p *Package) bool { return !p.Experimental })
+}
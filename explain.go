@@ -0,0 +1,227 @@
+package wuw
+
+import (
+	"bytes"
+	"cmp"
+	"flag"
+	"fmt"
+	"go/doc"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// explainTopImporters is how many importers 'wuw explain' lists.
+const explainTopImporters = 5
+
+// Brief is what there is to know about a package on first landing in it.
+type Brief struct {
+	Pkg      *Package
+	Synopsis string
+	// Lines and TestLines count the lines of its non-test and test files.
+	Files, TestFiles int
+	Lines, TestLines int
+	// Deps are its imports by category.
+	Deps map[string][]string
+	// Importers are the packages importing it, those with the most
+	// dependents of their own first.
+	Importers []string
+	// Cycle holds the other packages in an import cycle with it.
+	Cycle []string
+	// Rules are those applying to its imports, and Guards those denying
+	// imports of it.
+	Rules, Guards []*Rule
+	Violations    []Violation
+}
+
+// NewBrief sums up the package p of g, reading its files for the doc comment
+// and line counts, with the rules of c.
+func NewBrief(g *Graph, p string, c *Config) *Brief {
+	pkg := g.Pkgs[p]
+	b := &Brief{Pkg: pkg, Deps: make(map[string][]string)}
+
+	fset := token.NewFileSet()
+	for _, f := range pkg.Files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			continue
+		}
+		lines := bytes.Count(data, []byte("\n"))
+		if strings.HasSuffix(f, "_test.go") {
+			b.TestFiles++
+			b.TestLines += lines
+			continue
+		}
+		b.Files++
+		b.Lines += lines
+
+		if b.Synopsis == "" {
+			if af, err := parser.ParseFile(fset, f, data, parser.PackageClauseOnly|parser.ParseComments); err == nil && af.Doc != nil {
+				b.Synopsis = new(doc.Package).Synopsis(af.Doc.Text())
+			}
+		}
+	}
+
+	for _, d := range pkg.Deps {
+		if d != p {
+			cat := g.Category(d)
+			b.Deps[cat] = append(b.Deps[cat], d)
+		}
+	}
+
+	dependents := make(map[string]int)
+	for _, i := range g.Importers(p) {
+		if i != p {
+			b.Importers = append(b.Importers, i)
+			dependents[i] = len(g.Dependents(i))
+		}
+	}
+	slices.SortStableFunc(b.Importers, func(x, y string) int { return cmp.Compare(dependents[y], dependents[x]) })
+
+	for _, cycle := range g.Cycles() {
+		if slices.Contains(cycle, p) {
+			for _, q := range cycle {
+				if q != p {
+					b.Cycle = append(b.Cycle, q)
+				}
+			}
+			slices.Sort(b.Cycle)
+		}
+	}
+
+	for _, r := range c.Rules {
+		if r.Applies(p) {
+			b.Rules = append(b.Rules, r)
+		}
+		if slices.ContainsFunc(r.deny, func(re *regexp.Regexp) bool { return re.MatchString(p) }) {
+			b.Guards = append(b.Guards, r)
+		}
+	}
+	for _, v := range c.Check(g) {
+		if v.From == p || v.To == p {
+			b.Violations = append(b.Violations, v)
+		}
+	}
+	return b
+}
+
+// Write prints the brief in one screen or so.
+func (b *Brief) Write(w io.Writer, g *Graph) {
+	pkg := b.Pkg
+	fmt.Fprintf(w, "%s (package %s)\n", pkg.ImportPath, pkg.Name)
+	if b.Synopsis != "" {
+		fmt.Fprintf(w, "\t%s\n", b.Synopsis)
+	}
+
+	var marks []string
+	if len(pkg.Generated) != 0 {
+		marks = append(marks, fmt.Sprintf("%d generated", len(pkg.Generated)))
+	}
+	if pkg.TestSupport {
+		marks = append(marks, "test support")
+	}
+	if pkg.Experimental {
+		marks = append(marks, "experimental")
+	}
+	if pkg.Cgo {
+		marks = append(marks, "cgo")
+	}
+	fmt.Fprintf(w, "dir %s: %d files, %d lines; %d test files, %d lines", pkg.Path, b.Files, b.Lines, b.TestFiles, b.TestLines)
+	if len(marks) != 0 {
+		fmt.Fprintf(w, " (%s)", strings.Join(marks, ", "))
+	}
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, "imports:")
+	for _, cat := range []string{CategoryInternal, CategoryExternal, CategoryStd} {
+		deps := b.Deps[cat]
+		if len(deps) == 0 {
+			continue
+		}
+		var list []string
+		for _, d := range deps {
+			if qs := pkg.Qualifiers[d]; len(qs) != 0 {
+				d += " (" + strings.Join(qs, ", ") + ")"
+			}
+			list = append(list, d)
+		}
+		fmt.Fprintf(w, "\t%s (%d): %s\n", cat, len(deps), strings.Join(list, ", "))
+	}
+
+	fmt.Fprintf(w, "imported by %d, %d transitively:\n", len(b.Importers), len(g.Dependents(pkg.ImportPath)))
+	for _, i := range b.Importers[:min(len(b.Importers), explainTopImporters)] {
+		fmt.Fprintf(w, "\t%s (%d dependents)\n", i, len(g.Dependents(i)))
+	}
+	if n := len(b.Importers) - explainTopImporters; n > 0 {
+		fmt.Fprintf(w, "\tand %d more\n", n)
+	}
+
+	if len(b.Cycle) != 0 {
+		fmt.Fprintf(w, "in an import cycle with: %s\n", strings.Join(b.Cycle, ", "))
+	}
+
+	names := func(rules []*Rule) string {
+		var ret []string
+		for _, r := range rules {
+			ret = append(ret, r.Name)
+		}
+		return orNone(strings.Join(ret, ", "))
+	}
+	fmt.Fprintf(w, "rules on its imports: %s\n", names(b.Rules))
+	fmt.Fprintf(w, "rules on importing it: %s\n", names(b.Guards))
+	for _, v := range b.Violations {
+		fmt.Fprintf(w, "\tviolation: %s -> %s: %s\n", v.From, v.To, v.Rule.Name)
+	}
+}
+
+func RunExplain(args []string) {
+	fs := flag.NewFlagSet("explain", flag.ExitOnError)
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintln(w, "'wuw explain' sums up a package on one screen: its name and doc, files and lines, imports by kind, top importers, cycles and the rules that bear on it. The dirs, ./... by default, are scanned for its importers.")
+		fmt.Fprintf(w, "Usage: %s explain [-opts] pkg [dirs...]\nopts:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+
+	configVar := fs.String("config", DefaultConfig, "Config file to read rules from")
+	outVar := OutputFlag(fs)
+
+	ParseFlags(fs, args)
+
+	if fs.NArg() < 1 {
+		fmt.Fprintln(os.Stderr, "Need the package to explain. Displaying usage...")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	c, err := LoadConfig(*configVar)
+	if os.IsNotExist(err) {
+		c = &Config{}
+	} else if err != nil {
+		Fatal(err)
+	}
+
+	dirs := fs.Args()[1:]
+	if len(dirs) == 0 {
+		dirs = []string{"./..."}
+	}
+	pkgs, errs := ScanDirs(ExpandDirs(dirs), false)
+
+	PrintErrors(errs)
+
+	g := NewGraph(pkgs)
+	pkg, ok := g.Lookup(fs.Arg(0))
+	if !ok {
+		fmt.Fprintf(os.Stderr, "error: package %s was not scanned\n", fs.Arg(0))
+		os.Exit(1)
+	}
+
+	w := OpenOutput(*outVar)
+	defer w.Close()
+
+	NewBrief(g, pkg.ImportPath, c).Write(w, g)
+}
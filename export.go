@@ -0,0 +1,115 @@
+package wuw
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+func RunExport(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintln(w, "'wuw export' writes the scanned packages and edges to files meant for loading into other tools.")
+		fmt.Fprintf(w, "Usage: %s export [-opts] [dirs...]\nopts:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+
+	parquetVar := fs.String("parquet", "", "Write one row per import edge to this Parquet `file`")
+	packagesVar := fs.String("packages", "", "Write one row per scanned package to this Parquet `file`")
+	embeddingVar := fs.String("embedding", "", "Write nodes.csv with the features of each package and edges.csv with the imports between them as a node2vec-ready edge list of node ids to this `dir`")
+	sqliteVar := fs.String("sqlite", "", "Write packages, files and edges tables to this SQLite `file`, replacing it, for querying the graph with SQL")
+	noStdVar := fs.Bool("no-std", false, "Exclude stdlib packages (including golang.org/x/)")
+
+	ParseFlags(fs, args)
+
+	if *parquetVar == "" && *packagesVar == "" && *embeddingVar == "" && *sqliteVar == "" {
+		fmt.Fprintln(os.Stderr, "No export target provided. Displaying usage...")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	dirs := ReadArgs(fs.Args(), fs.Usage)
+	pkgs, errs := ScanDirs(dirs, *noStdVar)
+
+	PrintErrors(errs)
+
+	g := NewGraph(pkgs)
+
+	if *parquetVar != "" {
+		if err := parquet.WriteFile(*parquetVar, EdgeRows(g)); err != nil {
+			Fatal(err)
+		}
+	}
+	if *packagesVar != "" {
+		if err := parquet.WriteFile(*packagesVar, PackageRows(g)); err != nil {
+			Fatal(err)
+		}
+	}
+	if *embeddingVar != "" {
+		if err := WriteEmbeddingExport(*embeddingVar, g); err != nil {
+			Fatal(err)
+		}
+	}
+	if *sqliteVar != "" {
+		if err := WriteSQLiteExport(*sqliteVar, g); err != nil {
+			Fatal(err)
+		}
+	}
+}
+
+type EdgeRow struct {
+	Module   string `parquet:"module"`
+	From     string `parquet:"from"`
+	To       string `parquet:"to"`
+	Category string `parquet:"category"`
+}
+
+type PackageRow struct {
+	Module    string `parquet:"module"`
+	Path      string `parquet:"path"`
+	Name      string `parquet:"name"`
+	Dir       string `parquet:"dir"`
+	Files     int32  `parquet:"files"`
+	Imports   int32  `parquet:"imports"`
+	Importers int32  `parquet:"importers"`
+}
+
+// ModulePath returns the path of the module pkg belongs to, so rows from
+// scans of many repos can be told apart once loaded together.
+func ModulePath(pkg *Package) string {
+	if m := FindModule(pkg.Path); m != nil {
+		return m.Path
+	}
+	return ""
+}
+
+func EdgeRows(g *Graph) []EdgeRow {
+	var rows []EdgeRow
+	for _, p := range g.Order {
+		pkg := g.Pkgs[p]
+		for _, d := range pkg.Deps {
+			rows = append(rows, EdgeRow{Module: ModulePath(pkg), From: p, To: d, Category: g.Category(d)})
+		}
+	}
+	return rows
+}
+
+func PackageRows(g *Graph) []PackageRow {
+	var rows []PackageRow
+	for _, p := range g.Order {
+		pkg := g.Pkgs[p]
+		rows = append(rows, PackageRow{
+			Module:    ModulePath(pkg),
+			Path:      p,
+			Name:      pkg.Name,
+			Dir:       pkg.Path,
+			Files:     int32(len(pkg.Files)),
+			Imports:   int32(len(pkg.Deps)),
+			Importers: int32(len(g.Importers(p))),
+		})
+	}
+	return rows
+}
@@ -0,0 +1,202 @@
+package wuw
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// ExposurePolicy only lets the groups named by Layers import the external
+// modules whose path matches one of the Modules regexes, such as cloud SDKs
+// only being imported by an adapters layer.
+type ExposurePolicy struct {
+	Name    string   `yaml:"name"`
+	Modules []string `yaml:"modules"`
+	Layers  []string `yaml:"layers"`
+
+	modules []*regexp.Regexp
+}
+
+// Compile compiles the module patterns of e, checking that the layers it
+// names are among names.
+func (e *ExposurePolicy) Compile(names []string) error {
+	e.modules = nil
+	for _, m := range e.Modules {
+		re, err := regexp.Compile(m)
+		if err != nil {
+			return err
+		}
+		e.modules = append(e.modules, re)
+	}
+	for _, l := range e.Layers {
+		if !slices.Contains(names, l) {
+			return fmt.Errorf("no group %s", l)
+		}
+	}
+	return nil
+}
+
+func (e *ExposurePolicy) Covers(module string) bool {
+	return slices.ContainsFunc(e.modules, func(re *regexp.Regexp) bool { return re.MatchString(module) })
+}
+
+// LayerExposure is an external module a layer imports directly.
+type LayerExposure struct {
+	Module string
+	// Packages are those of the layer importing it.
+	Packages []string
+}
+
+type ExposureViolation struct {
+	Edge
+	Layer  string
+	Module string
+	Policy *ExposurePolicy
+	Site   *ImportSite
+}
+
+func (v ExposureViolation) String() string {
+	layer := v.Layer
+	if layer == "" {
+		layer = "no layer"
+	}
+	return fmt.Sprintf("%s (%s) -> %s: %s only lets %s import %s", v.From, layer, v.To, v.Policy.Name, strings.Join(v.Policy.Layers, ", "), v.Module)
+}
+
+// Exposure maps every layer of gs, and "" for the packages in none, to the
+// external modules its packages import, and lists the imports going against
+// the exposure policies of gs. Experimental packages are left out.
+func Exposure(g *Graph, gs *Groups) (map[string][]*LayerExposure, []ExposureViolation) {
+	byLayer := make(map[string]map[string]*LayerExposure)
+	var violations []ExposureViolation
+	for _, p := range g.Order {
+		pkg := g.Pkgs[p]
+		if pkg.Experimental {
+			continue
+		}
+
+		mv := &ModuleVersions{}
+		if m := FindModule(pkg.Path); m != nil {
+			mv = LoadModuleVersions(m.Root)
+		}
+
+		layer := gs.Of(p)
+		var sites []ImportSite
+		for _, d := range pkg.Deps {
+			if g.Category(d) != CategoryExternal {
+				continue
+			}
+
+			m := mv.ModuleOf(d)
+			if byLayer[layer] == nil {
+				byLayer[layer] = make(map[string]*LayerExposure)
+			}
+			e, ok := byLayer[layer][m]
+			if !ok {
+				e = &LayerExposure{Module: m}
+				byLayer[layer][m] = e
+			}
+			if !slices.Contains(e.Packages, p) {
+				e.Packages = append(e.Packages, p)
+			}
+
+			for _, policy := range gs.Exposure {
+				if !policy.Covers(m) || slices.Contains(policy.Layers, layer) {
+					continue
+				}
+				if sites == nil {
+					sites = ImportSites(pkg)
+				}
+				v := ExposureViolation{Edge: Edge{From: p, To: d}, Layer: layer, Module: m, Policy: policy}
+				if i := slices.IndexFunc(sites, func(s ImportSite) bool { return s.Path == d }); i != -1 {
+					v.Site = &sites[i]
+				}
+				violations = append(violations, v)
+			}
+		}
+	}
+
+	ret := make(map[string][]*LayerExposure)
+	for layer, mods := range byLayer {
+		ret[layer] = slices.SortedFunc(maps.Values(mods), func(a, b *LayerExposure) int { return strings.Compare(a.Module, b.Module) })
+	}
+	return ret, violations
+}
+
+// WriteExposure writes the external modules of each layer, in the order of
+// the groups file, followed by the packages in no layer.
+func WriteExposure(w io.Writer, gs *Groups, exposure map[string][]*LayerExposure) {
+	for _, layer := range append(gs.Names(), "") {
+		mods := exposure[layer]
+		if layer == "" {
+			if len(mods) == 0 {
+				continue
+			}
+			layer = "(no layer)"
+		}
+		fmt.Fprintln(w, layer)
+		if len(mods) == 0 {
+			fmt.Fprintln(w, "\tno external modules")
+		}
+		for _, e := range mods {
+			fmt.Fprintf(w, "\t%s\t%d packages\n", e.Module, len(e.Packages))
+		}
+	}
+}
+
+func RunExposure(args []string) {
+	fs := flag.NewFlagSet("exposure", flag.ExitOnError)
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintln(w, "'wuw exposure' reports which external modules each layer, as defined by the groups of a groups file, imports directly, and checks the exposure policies of the file, such as only letting an adapters layer import cloud SDKs. It exits with 1 listing every import going against them.")
+		fmt.Fprintf(w, "Usage: %s exposure [-opts] [dirs...]\nopts:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+
+	groupsVar := fs.String("groups", "", "YAML `file` defining the layers as groups, and the exposure policies")
+	outVar := OutputFlag(fs)
+
+	ParseFlags(fs, args)
+
+	if *groupsVar == "" {
+		fmt.Fprintln(os.Stderr, "Need the -groups file defining the layers. Displaying usage...")
+		fs.Usage()
+		os.Exit(1)
+	}
+	gs, err := LoadGroups(*groupsVar)
+	if err != nil {
+		Fatal(err)
+	}
+
+	dirs := ReadArgs(fs.Args(), fs.Usage)
+	pkgs, errs := ScanDirs(dirs, true)
+
+	PrintErrors(errs)
+
+	g := NewGraph(pkgs)
+
+	w := OpenOutput(*outVar)
+	defer w.Close()
+
+	exposure, violations := Exposure(g, gs)
+	WriteExposure(w, gs, exposure)
+
+	if len(violations) == 0 {
+		return
+	}
+	fmt.Fprintln(w)
+	for _, v := range violations {
+		if v.Site != nil {
+			fmt.Fprintf(w, "%s:%d:%d: ", v.Site.File, v.Site.Line, v.Site.Col)
+		}
+		fmt.Fprintln(w, v)
+	}
+	fmt.Fprintf(w, "%d violations\n", len(violations))
+	w.Close()
+	os.Exit(1)
+}
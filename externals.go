@@ -0,0 +1,182 @@
+package wuw
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+)
+
+func RunExternals(args []string) {
+	fs := flag.NewFlagSet("externals", flag.ExitOnError)
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintln(w, "'wuw externals' lists the external modules the scanned packages import, and which packages import them.")
+		fmt.Fprintf(w, "Usage: %s externals [-opts] [dirs...]\nopts:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+
+	githubVar := fs.Bool("github", false, "Look up stars, archival status and last push of GitHub hosted modules (uses $GITHUB_TOKEN if set)")
+	outVar := OutputFlag(fs)
+	staleVar := fs.Duration("stale", 2*365*24*time.Hour, "With -github, flag modules with no push for this long as abandoned")
+
+	ParseFlags(fs, args)
+
+	dirs := ReadArgs(fs.Args(), fs.Usage)
+	pkgs, errs := ScanDirs(dirs, true)
+
+	PrintErrors(errs)
+
+	g := NewGraph(pkgs)
+
+	w := OpenOutput(*outVar)
+	defer w.Close()
+	mods := ExternalModules(g)
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	var flagged []string
+
+	for _, m := range mods {
+		fmt.Fprint(w, m.Path)
+		if m.Version != "" {
+			fmt.Fprintf(w, " %s", m.Version)
+		}
+
+		if *githubVar {
+			if owner, repo, ok := GitHubRepo(m.Path); ok {
+				info, err := FetchRepoInfo(client, owner, repo)
+				if err != nil {
+					fmt.Fprintf(w, "\t(%v)", err)
+				} else {
+					fmt.Fprintf(w, "\t%d stars\tlast push %s", info.Stars, info.PushedAt.Format(time.DateOnly))
+					if info.Archived {
+						fmt.Fprint(w, "\tARCHIVED")
+						flagged = append(flagged, m.Path)
+					} else if time.Since(info.PushedAt) > *staleVar {
+						fmt.Fprint(w, "\tABANDONED")
+						flagged = append(flagged, m.Path)
+					}
+				}
+			}
+		}
+		fmt.Fprintln(w)
+
+		for _, p := range m.Importers {
+			fmt.Fprintf(w, "\t%s\n", p)
+		}
+	}
+
+	if len(flagged) != 0 {
+		fmt.Fprintf(w, "\n%d archived or abandoned modules in use:\n", len(flagged))
+		for _, f := range flagged {
+			fmt.Fprintf(w, "\t%s\n", f)
+		}
+	}
+}
+
+type ExternalModule struct {
+	Path      string
+	Version   string
+	Packages  []string
+	Importers []string
+}
+
+// ExternalModules groups the external imports of g by the module they belong
+// to, as recorded by the importing module's vendor/modules.txt or go.mod.
+func ExternalModules(g *Graph) []*ExternalModule {
+	byPath := make(map[string]*ExternalModule)
+	for _, p := range g.Order {
+		mv := &ModuleVersions{}
+		if m := FindModule(g.Pkgs[p].Path); m != nil {
+			mv = LoadModuleVersions(m.Root)
+		}
+
+		for _, d := range g.Pkgs[p].Deps {
+			if g.Category(d) != CategoryExternal {
+				continue
+			}
+
+			mp := mv.ModuleOf(d)
+			m, ok := byPath[mp]
+			if !ok {
+				m = &ExternalModule{Path: mp, Version: mv.Versions[mp]}
+				byPath[mp] = m
+			}
+			if !slices.Contains(m.Packages, d) {
+				m.Packages = append(m.Packages, d)
+			}
+			if !slices.Contains(m.Importers, p) {
+				m.Importers = append(m.Importers, p)
+			}
+		}
+	}
+
+	var ret []*ExternalModule
+	for _, m := range byPath {
+		slices.Sort(m.Packages)
+		ret = append(ret, m)
+	}
+	slices.SortFunc(ret, func(a, b *ExternalModule) int { return strings.Compare(a.Path, b.Path) })
+	return ret
+}
+
+// GuessModule returns the module path an external import path most likely
+// belongs to, going by the layout of well known hosts.
+func GuessModule(path string) string {
+	elems := strings.Split(path, "/")
+
+	n := 3
+	switch elems[0] {
+	case "gopkg.in":
+		// gopkg.in/yaml.v3, but gopkg.in/user/pkg.v1
+		n = 2
+		if len(elems) > 2 && !strings.Contains(elems[1], ".v") {
+			n = 3
+		}
+	case "google.golang.org", "go.uber.org", "k8s.io":
+		n = 2
+	}
+
+	if len(elems) > n && IsMajorVersion(elems[n]) {
+		n++
+	}
+	if len(elems) < n {
+		return path
+	}
+	return strings.Join(elems[:n], "/")
+}
+
+// ImportName guesses the name a package declares from its import path, as
+// goimports does: the last element, or the one before a /vN major version,
+// without a go- prefix and cut at the first char a name can't have, such as
+// the . of gopkg.in/yaml.v3.
+func ImportName(path string) string {
+	elems := strings.Split(path, "/")
+	name := elems[len(elems)-1]
+	if len(elems) > 1 && IsMajorVersion(name) {
+		name = elems[len(elems)-2]
+	}
+	name = strings.TrimPrefix(name, "go-")
+	if i := strings.IndexFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	}); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+func IsMajorVersion(elem string) bool {
+	if len(elem) < 2 || elem[0] != 'v' {
+		return false
+	}
+	for _, r := range elem[1:] {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
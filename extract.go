@@ -0,0 +1,150 @@
+package wuw
+
+import (
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"path"
+	"slices"
+	"strings"
+)
+
+// ExtractCandidate is a subtree of a module that could be split out into a
+// module of its own, because the rest of the module uses little or none of it.
+type ExtractCandidate struct {
+	Root     string
+	Packages []string
+	// Surface holds, for each package of the subtree imported from outside
+	// of it, the symbols used and by whom.
+	Surface map[string]*EdgeSymbols
+	// Importers are the packages outside the subtree that import into it.
+	Importers []string
+	// Needs are the internal packages outside the subtree that it imports,
+	// which the new module would depend on.
+	Needs []string
+}
+
+// SurfaceSize counts the distinct symbols used of the subtree from outside.
+func (c *ExtractCandidate) SurfaceSize() int {
+	var n int
+	for _, es := range c.Surface {
+		n += len(es.Uses)
+	}
+	return n
+}
+
+// ExtractCandidates finds the subtrees of g's modules with at least
+// minPackages packages whose surface, the symbols used from outside of them,
+// is at most maxSurface. Subtrees are returned biggest first.
+func ExtractCandidates(g *Graph, minPackages, maxSurface int) []*ExtractCandidate {
+	var roots []string
+	for _, p := range g.Order {
+		m := FindModule(g.Pkgs[p].Path)
+		if m == nil || !MatchesPath(p, m.Path) {
+			continue
+		}
+		for r := p; r != m.Path && MatchesPath(r, m.Path); r = path.Dir(r) {
+			if !slices.Contains(roots, r) {
+				roots = append(roots, r)
+			}
+		}
+	}
+
+	var ret []*ExtractCandidate
+	for _, root := range roots {
+		c := &ExtractCandidate{Root: root, Surface: make(map[string]*EdgeSymbols)}
+		for _, p := range g.Order {
+			if MatchesPath(p, root) {
+				c.Packages = append(c.Packages, p)
+			}
+		}
+		if len(c.Packages) < minPackages {
+			continue
+		}
+
+		for _, p := range g.Order {
+			inside := MatchesPath(p, root)
+			for _, d := range g.InternalDeps(p) {
+				switch {
+				case inside && !MatchesPath(d, root):
+					if !slices.Contains(c.Needs, d) {
+						c.Needs = append(c.Needs, d)
+					}
+				case !inside && MatchesPath(d, root):
+					if !slices.Contains(c.Importers, p) {
+						c.Importers = append(c.Importers, p)
+					}
+					es := ClassifyEdge(g, p, d)
+					if prev, ok := c.Surface[d]; ok {
+						for s, n := range es.Uses {
+							if prev.Uses[s] == 0 {
+								k := PackageSymbols(g.Pkgs[d])[s]
+								prev.Symbols[k] = append(prev.Symbols[k], s)
+								slices.Sort(prev.Symbols[k])
+							}
+							prev.Uses[s] += n
+						}
+					} else {
+						c.Surface[d] = &es
+					}
+				}
+			}
+		}
+
+		if c.SurfaceSize() <= maxSurface {
+			slices.Sort(c.Needs)
+			slices.Sort(c.Importers)
+			ret = append(ret, c)
+		}
+	}
+
+	slices.SortStableFunc(ret, func(a, b *ExtractCandidate) int {
+		if n := len(b.Packages) - len(a.Packages); n != 0 {
+			return n
+		}
+		return strings.Compare(a.Root, b.Root)
+	})
+	return ret
+}
+
+func RunExtractCandidates(args []string) {
+	fs := flag.NewFlagSet("extract-candidates", flag.ExitOnError)
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintln(w, "'wuw extract-candidates' suggests subtrees that the rest of their module doesn't import, or only through a few symbols, as candidates to split into separate modules or repos.")
+		fmt.Fprintf(w, "Usage: %s extract-candidates [-opts] [dirs...]\nopts:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+
+	minPackagesVar := fs.Int("min-packages", 2, "Only suggest subtrees of at least this many packages")
+	maxSurfaceVar := fs.Int("max-surface", 5, "The most distinct symbols the rest of the module can use of a subtree for it to be suggested")
+	outVar := OutputFlag(fs)
+
+	ParseFlags(fs, args)
+
+	dirs := ReadArgs(fs.Args(), fs.Usage)
+	pkgs, errs := ScanDirs(dirs, true)
+
+	PrintErrors(errs)
+
+	g := NewGraph(pkgs)
+
+	w := OpenOutput(*outVar)
+	defer w.Close()
+
+	for _, c := range ExtractCandidates(g, *minPackagesVar, *maxSurfaceVar) {
+		fmt.Fprintf(w, "%s (%d packages)\n", c.Root, len(c.Packages))
+		if len(c.Importers) == 0 {
+			fmt.Fprintln(w, "\tnot imported from outside")
+		} else {
+			fmt.Fprintf(w, "\tsurface of %d symbols, imported by %s\n", c.SurfaceSize(), strings.Join(c.Importers, ", "))
+			for _, d := range slices.Sorted(maps.Keys(c.Surface)) {
+				fmt.Fprintf(w, "\t\t%s: %s\n", d, c.Surface[d])
+			}
+		}
+		for _, n := range c.Needs {
+			fmt.Fprintf(w, "\tneeds %s\n", n)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package wuw
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+)
+
+// profile is the config profile picked with -profile. LoadConfig adds its
+// rules to those of the config.
+var profile string
+
+// ParseFlags parses args into fs, along with the flags every command takes,
+// then sets the flags they left unset from, in order, WUW_* environment
+// variables, the -flagfile and the chosen -profile. Flags of a flagfile or
+// profile that fs doesn't have are meant for other commands and skipped.
+func ParseFlags(fs *flag.FlagSet, args []string) {
+	fs.StringVar(&profile, "profile", profile, "Use the flags and rules of this `profile` from the config file")
+	flagfileVar := fs.String("flagfile", "", "Read flags from this `file`, one per line")
+	fs.BoolVar(&noClassCache, "no-class-cache", noClassCache, "Don't read or write the cache of which module and version each import belongs to, and whether it is in the standard library")
+	fs.Var(SkipDirsFlag{}, "skip-dirs", "Comma separated `names` of dirs to leave out of dir/..., a trailing * matching any rest of the name (default "+SkipDirsFlag{}.String()+")")
+	fs.Parse(args)
+
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	fs.VisitAll(func(f *flag.Flag) {
+		if set[f.Name] {
+			return
+		}
+		if v, ok := os.LookupEnv(EnvVar(f.Name)); ok {
+			if err := fs.Set(f.Name, v); err != nil {
+				Fatal(fmt.Errorf("%s: %w", EnvVar(f.Name), err))
+			}
+			set[f.Name] = true
+		}
+	})
+
+	if *flagfileVar != "" {
+		flags, err := ReadFlagfile(fs, *flagfileVar)
+		if err != nil {
+			Fatal(err)
+		}
+		setFlags(fs, set, flags, *flagfileVar)
+	}
+
+	if profile == "" {
+		return
+	}
+
+	path := DefaultConfig
+	if f := fs.Lookup("config"); f != nil {
+		path = f.Value.String()
+	}
+	c, err := LoadConfig(path)
+	if err != nil {
+		Fatal(err)
+	}
+	setFlags(fs, set, c.Profiles[profile].Flags, path+": profile "+profile)
+}
+
+// setFlags sets the flags of fs that aren't in set yet, and marks them set.
+func setFlags(fs *flag.FlagSet, set map[string]bool, flags map[string]string, from string) {
+	for _, name := range slices.Sorted(maps.Keys(flags)) {
+		if set[name] || fs.Lookup(name) == nil {
+			continue
+		}
+		if err := fs.Set(name, flags[name]); err != nil {
+			Fatal(fmt.Errorf("%s: -%s: %w", from, name, err))
+		}
+		set[name] = true
+	}
+}
+
+// EnvVar returns the environment variable that sets the flag name, so
+// -no-std is set by WUW_NO_STD.
+func EnvVar(name string) string {
+	return "WUW_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// ReadFlagfile reads the flags in the file at path, written one per line as
+// -name=value, -name value, or just -name for booleans. Blank lines and lines
+// starting with # are skipped.
+func ReadFlagfile(fs *flag.FlagSet, path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	flags := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !strings.HasPrefix(line, "-") {
+			return nil, fmt.Errorf("%s:%d: expected a flag, got %q", path, n, line)
+		}
+
+		name, value, hasValue := strings.Cut(strings.TrimLeft(line, "-"), "=")
+		if !hasValue {
+			name, value, hasValue = strings.Cut(name, " ")
+			value = strings.TrimSpace(value)
+		}
+		if !hasValue {
+			// flags fs doesn't have are taken as booleans of other commands
+			if f := fs.Lookup(name); f != nil && !IsBoolFlag(f) {
+				return nil, fmt.Errorf("%s:%d: flag -%s needs a value", path, n, name)
+			}
+			value = "true"
+		}
+		flags[name] = value
+	}
+	return flags, scanner.Err()
+}
+
+func IsBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
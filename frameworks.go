@@ -0,0 +1,154 @@
+package wuw
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+)
+
+// Framework is a well known library, recognized by the import paths of its
+// packages.
+type Framework struct {
+	Name  string
+	Kind  string
+	Paths []string
+}
+
+// frameworks are the libraries 'wuw stack' knows about, in the order their
+// kinds are listed.
+var frameworks = []Framework{
+	{"gin", "web", []string{"github.com/gin-gonic/gin"}},
+	{"echo", "web", []string{"github.com/labstack/echo"}},
+	{"chi", "web", []string{"github.com/go-chi/chi"}},
+	{"gorilla/mux", "web", []string{"github.com/gorilla/mux"}},
+	{"fiber", "web", []string{"github.com/gofiber/fiber"}},
+	{"net/http", "web", []string{"net/http"}},
+
+	{"grpc", "rpc", []string{"google.golang.org/grpc"}},
+	{"connect", "rpc", []string{"connectrpc.com/connect", "github.com/bufbuild/connect-go"}},
+	{"twirp", "rpc", []string{"github.com/twitchtv/twirp"}},
+	{"gqlgen", "rpc", []string{"github.com/99designs/gqlgen"}},
+
+	{"gorm", "database", []string{"gorm.io/gorm", "github.com/jinzhu/gorm"}},
+	{"sqlx", "database", []string{"github.com/jmoiron/sqlx"}},
+	{"ent", "database", []string{"entgo.io/ent"}},
+	{"pgx", "database", []string{"github.com/jackc/pgx"}},
+	{"lib/pq", "database", []string{"github.com/lib/pq"}},
+	{"go-sql-driver/mysql", "database", []string{"github.com/go-sql-driver/mysql"}},
+	{"sqlite", "database", []string{"github.com/mattn/go-sqlite3", "modernc.org/sqlite"}},
+	{"mongo", "database", []string{"go.mongodb.org/mongo-driver"}},
+	{"redis", "database", []string{"github.com/redis/go-redis", "github.com/go-redis/redis", "github.com/gomodule/redigo"}},
+	{"database/sql", "database", []string{"database/sql"}},
+
+	{"kafka", "messaging", []string{"github.com/segmentio/kafka-go", "github.com/confluentinc/confluent-kafka-go", "github.com/Shopify/sarama", "github.com/IBM/sarama", "github.com/twmb/franz-go"}},
+	{"nats", "messaging", []string{"github.com/nats-io/nats.go"}},
+	{"rabbitmq", "messaging", []string{"github.com/rabbitmq/amqp091-go", "github.com/streadway/amqp"}},
+
+	{"aws", "cloud", []string{"github.com/aws/aws-sdk-go", "github.com/aws/aws-sdk-go-v2"}},
+	{"gcp", "cloud", []string{"cloud.google.com/go"}},
+	{"azure", "cloud", []string{"github.com/Azure/azure-sdk-for-go"}},
+	{"kubernetes", "cloud", []string{"k8s.io/client-go", "sigs.k8s.io/controller-runtime"}},
+
+	{"cobra", "cli", []string{"github.com/spf13/cobra"}},
+	{"urfave/cli", "cli", []string{"github.com/urfave/cli"}},
+	{"kong", "cli", []string{"github.com/alecthomas/kong"}},
+	{"viper", "config", []string{"github.com/spf13/viper"}},
+
+	{"zap", "logging", []string{"go.uber.org/zap"}},
+	{"logrus", "logging", []string{"github.com/sirupsen/logrus"}},
+	{"zerolog", "logging", []string{"github.com/rs/zerolog"}},
+	{"opentelemetry", "observability", []string{"go.opentelemetry.io/otel"}},
+	{"prometheus", "observability", []string{"github.com/prometheus/client_golang"}},
+
+	{"wire", "di", []string{"github.com/google/wire"}},
+	{"fx", "di", []string{"go.uber.org/fx", "go.uber.org/dig"}},
+
+	{"testify", "testing", []string{"github.com/stretchr/testify"}},
+	{"gomock", "testing", []string{"github.com/golang/mock", "go.uber.org/mock"}},
+	{"ginkgo", "testing", []string{"github.com/onsi/ginkgo", "github.com/onsi/gomega"}},
+}
+
+// FrameworkOf returns the framework the import path belongs to.
+func FrameworkOf(path string) (*Framework, bool) {
+	for i := range frameworks {
+		for _, p := range frameworks[i].Paths {
+			if MatchesPath(path, p) {
+				return &frameworks[i], true
+			}
+		}
+	}
+	return nil, false
+}
+
+// FrameworkUse is a framework and the scanned packages that import it.
+type FrameworkUse struct {
+	*Framework
+	Importers []string
+}
+
+// DetectFrameworks returns the frameworks imported by the packages of g, in
+// the order of the frameworks table.
+func DetectFrameworks(g *Graph) []*FrameworkUse {
+	uses := make(map[string]*FrameworkUse)
+	for _, p := range g.Order {
+		for _, d := range g.Pkgs[p].Deps {
+			f, ok := FrameworkOf(d)
+			if !ok {
+				continue
+			}
+			u, ok := uses[f.Name]
+			if !ok {
+				u = &FrameworkUse{Framework: f}
+				uses[f.Name] = u
+			}
+			if !slices.Contains(u.Importers, p) {
+				u.Importers = append(u.Importers, p)
+			}
+		}
+	}
+
+	var ret []*FrameworkUse
+	for _, f := range frameworks {
+		if u, ok := uses[f.Name]; ok {
+			ret = append(ret, u)
+		}
+	}
+	return ret
+}
+
+func RunStack(args []string) {
+	fs := flag.NewFlagSet("stack", flag.ExitOnError)
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintln(w, "'wuw stack' recognizes common frameworks and libraries from the imports, and lists which packages use each of them.")
+		fmt.Fprintf(w, "Usage: %s stack [-opts] [dirs...]\nopts:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+
+	outVar := OutputFlag(fs)
+
+	ParseFlags(fs, args)
+
+	dirs := ReadArgs(fs.Args(), fs.Usage)
+	pkgs, errs := ScanDirs(dirs, false)
+
+	PrintErrors(errs)
+
+	g := NewGraph(pkgs)
+
+	w := OpenOutput(*outVar)
+	defer w.Close()
+
+	var kind string
+	for _, u := range DetectFrameworks(g) {
+		if u.Kind != kind {
+			kind = u.Kind
+			fmt.Fprintf(w, "%s:\n", kind)
+		}
+		fmt.Fprintf(w, "\t%s\n", u.Name)
+		for _, p := range u.Importers {
+			fmt.Fprintf(w, "\t\t%s\n", p)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package wuw
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+func RunGate(args []string) {
+	fs := flag.NewFlagSet("gate", flag.ExitOnError)
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintln(w, "'wuw gate' checks aggregate metrics of the whole import graph, and metrics of each package with -max-metric, against thresholds, exiting with 1 if any is exceeded. Negative thresholds are not checked.")
+		fmt.Fprintf(w, "Usage: %s gate [-opts] [dirs...]\nopts:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+
+	noStdVar := fs.Bool("no-std", false, "Exclude stdlib packages (including golang.org/x/)")
+	testSupportVar := TestSupportFlag(fs)
+	avgFanOutVar := fs.Float64("max-avg-fanout", -1, "Maximum average number of internal imports per package")
+	fanInVar := fs.Int("max-fan-in", -1, "Maximum number of internal importers of any one package")
+	depthVar := fs.Int("max-depth", -1, "Maximum length of the longest internal import chain")
+	cyclesVar := fs.Int("max-cycles", -1, "Maximum number of import cycles")
+	edgesVar := fs.Int("max-internal-edges", -1, "Maximum number of internal imports")
+	externalVar := fs.Int("max-external-deps", -1, "Maximum number of distinct external packages imported, those neither scanned nor in the standard library")
+	metricVar := fs.String("max-metric", "", "Comma-separated `metric=limit` pairs, the metrics being built-in ones of packages or derived ones of .wuw.yaml, that no single package may exceed")
+	outVar := OutputFlag(fs)
+
+	ParseFlags(fs, args)
+
+	c, err := LoadConfig(DefaultConfig)
+	if err != nil && !os.IsNotExist(err) {
+		Fatal(err)
+	}
+	limits, err := ParseMetricLimits(*metricVar, MetricNames(c.DerivedMetrics()))
+	if err != nil {
+		Fatal(err)
+	}
+
+	dirs := ReadArgs(fs.Args(), fs.Usage)
+	pkgs, errs := ScanDirs(dirs, *noStdVar)
+
+	PrintErrors(errs)
+
+	pkgs, err = ApplyTestSupport(pkgs, *testSupportVar)
+	if err != nil {
+		Fatal(err)
+	}
+
+	g := NewGraph(pkgs)
+	m := g.Metrics()
+
+	w := OpenOutput(*outVar)
+
+	var failed int
+	check := func(name string, value, limit float64, format string) {
+		if limit < 0 || value <= limit {
+			return
+		}
+		failed++
+		fmt.Fprintf(w, "%s: "+format+" exceeds "+format+"\n", name, value, limit)
+	}
+	check("avg fan-out", m.AvgFanOut, *avgFanOutVar, "%.2f")
+	check("max fan-in", float64(m.MaxFanIn), float64(*fanInVar), "%.0f")
+	check("max depth", float64(m.MaxDepth), float64(*depthVar), "%.0f")
+	check("cycles", float64(m.Cycles), float64(*cyclesVar), "%.0f")
+	check("internal edges", float64(m.InternalEdges), float64(*edgesVar), "%.0f")
+	check("external deps", float64(m.ExternalDeps), float64(*externalVar), "%.0f")
+	if len(limits) != 0 {
+		// like the aggregate metrics, experimental packages aren't checked
+		values := ComputeMetrics(g, c.DerivedMetrics())
+		for _, l := range limits {
+			for _, p := range g.WithoutExperimental().Order {
+				if v := values[p][l.Metric]; v > l.Limit {
+					failed++
+					fmt.Fprintf(w, "%s of %s: %s exceeds %s\n", l.Metric, p, FormatMetric(v), FormatMetric(l.Limit))
+				}
+			}
+		}
+	}
+
+	if failed != 0 {
+		fmt.Fprintf(w, "%d thresholds exceeded\n", failed)
+		w.Close()
+		os.Exit(1)
+	}
+	w.Close()
+}
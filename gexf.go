@@ -0,0 +1,146 @@
+package wuw
+
+import (
+	"encoding/xml"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+type gexf struct {
+	XMLName xml.Name  `xml:"gexf"`
+	NS      string    `xml:"xmlns,attr"`
+	Version string    `xml:"version,attr"`
+	Graph   gexfGraph `xml:"graph"`
+}
+
+type gexfGraph struct {
+	DefaultEdgeType string          `xml:"defaultedgetype,attr"`
+	Attributes      []gexfAttribute `xml:"attributes"`
+	Nodes           []gexfNode      `xml:"nodes>node"`
+	Edges           []gexfEdge      `xml:"edges>edge"`
+}
+
+type gexfAttribute struct {
+	Class string         `xml:"class,attr"`
+	Attrs []gexfAttrDecl `xml:"attribute"`
+}
+
+type gexfAttrDecl struct {
+	ID    string `xml:"id,attr"`
+	Title string `xml:"title,attr"`
+	Type  string `xml:"type,attr"`
+}
+
+type gexfNode struct {
+	ID     string         `xml:"id,attr"`
+	Label  string         `xml:"label,attr"`
+	Values []gexfAttValue `xml:"attvalues>attvalue"`
+}
+
+type gexfEdge struct {
+	ID     string `xml:"id,attr"`
+	Source string `xml:"source,attr"`
+	Target string `xml:"target,attr"`
+	// Values is nil for edges without any, as an empty attvalues element
+	// is invalid
+	Values *gexfAttValues `xml:"attvalues"`
+}
+
+type gexfAttValues struct {
+	Values []gexfAttValue `xml:"attvalue"`
+}
+
+type gexfAttValue struct {
+	For   string `xml:"for,attr"`
+	Value string `xml:"value,attr"`
+}
+
+// WriteGEXF writes g as GEXF, for exploring large graphs with the layouts
+// and community detection of Gephi. Every package is a node, deps included,
+// keyed by import path and carrying its category, dir, group, whether it is
+// experimental and how many packages it imports and is imported by as
+// attributes. Edges carry the qualifiers of the import, if any.
+func WriteGEXF(w io.Writer, g *Graph, groups *Groups) error {
+	doc := gexf{
+		NS:      "http://gexf.net/1.3",
+		Version: "1.3",
+		Graph: gexfGraph{
+			DefaultEdgeType: "directed",
+			Attributes: []gexfAttribute{
+				{Class: "node", Attrs: []gexfAttrDecl{
+					{ID: "category", Title: "category", Type: "string"},
+					{ID: "path", Title: "path", Type: "string"},
+					{ID: "group", Title: "group", Type: "string"},
+					{ID: "experimental", Title: "experimental", Type: "boolean"},
+					{ID: "imports", Title: "imports", Type: "integer"},
+					{ID: "importers", Title: "importers", Type: "integer"},
+				}},
+				{Class: "edge", Attrs: []gexfAttrDecl{
+					{ID: "qualifiers", Title: "qualifiers", Type: "string"},
+				}},
+			},
+		},
+	}
+
+	importers := make(map[string]int)
+	for _, p := range g.Order {
+		for _, d := range g.Pkgs[p].Deps {
+			importers[d]++
+		}
+	}
+
+	seen := make(map[string]bool)
+	addNode := func(p string) {
+		if seen[p] {
+			return
+		}
+		seen[p] = true
+		n := gexfNode{ID: p, Label: path.Base(p)}
+		n.Values = append(n.Values, gexfAttValue{For: "category", Value: g.Category(p)})
+		var imports int
+		if pkg, ok := g.Pkgs[p]; ok {
+			n.Label = LayoutLabel(g, p)
+			imports = len(pkg.Deps)
+			n.Values = append(n.Values, gexfAttValue{For: "path", Value: RelPath(pkg.Path)})
+			if pkg.Experimental {
+				n.Values = append(n.Values, gexfAttValue{For: "experimental", Value: "true"})
+			}
+		}
+		if group := groups.Of(p); group != "" {
+			n.Values = append(n.Values, gexfAttValue{For: "group", Value: group})
+		}
+		n.Values = append(n.Values,
+			gexfAttValue{For: "imports", Value: strconv.Itoa(imports)},
+			gexfAttValue{For: "importers", Value: strconv.Itoa(importers[p])},
+		)
+		doc.Graph.Nodes = append(doc.Graph.Nodes, n)
+	}
+
+	for _, p := range g.Order {
+		addNode(p)
+	}
+	for _, p := range g.Order {
+		pkg := g.Pkgs[p]
+		for _, d := range pkg.Deps {
+			addNode(d)
+			e := gexfEdge{ID: strconv.Itoa(len(doc.Graph.Edges)), Source: p, Target: d}
+			if q := pkg.Qualifiers[d]; len(q) != 0 {
+				e.Values = &gexfAttValues{Values: []gexfAttValue{{For: "qualifiers", Value: strings.Join(q, ",")}}}
+			}
+			doc.Graph.Edges = append(doc.Graph.Edges, e)
+		}
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
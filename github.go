@@ -0,0 +1,59 @@
+package wuw
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+type RepoInfo struct {
+	Stars    int       `json:"stargazers_count"`
+	Archived bool      `json:"archived"`
+	PushedAt time.Time `json:"pushed_at"`
+}
+
+func GitHubRepo(module string) (owner, repo string, ok bool) {
+	elems := strings.Split(module, "/")
+	if len(elems) < 3 || elems[0] != "github.com" {
+		return "", "", false
+	}
+	return elems[1], elems[2], true
+}
+
+var repoInfoCache = make(map[string]*RepoInfo)
+
+func FetchRepoInfo(client *http.Client, owner, repo string) (*RepoInfo, error) {
+	key := owner + "/" + repo
+	if info, ok := repoInfoCache[key]; ok {
+		return info, nil
+	}
+
+	req, err := http.NewRequest("GET", "https://api.github.com/repos/"+key, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("github: %s", resp.Status)
+	}
+
+	var info RepoInfo
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, err
+	}
+	repoInfoCache[key] = &info
+	return &info, nil
+}
@@ -0,0 +1,136 @@
+package wuw
+
+import (
+	"bytes"
+	"flag"
+	"go/build"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "Rewrite the golden files of testdata/golden with the output of the tests")
+
+// fixtureDirs are the packages of testdata/fixture: a cycle between store and
+// web, an external test of store, a linux only file of web, a cgo package
+// and a package of generated and hand-written files.
+var fixtureDirs = []string{"app", "cgo", "gen", "store", "web"}
+
+// scanFixture scans the packages of dirs in testdata/fixture at a fixed time,
+// for the build configuration ctx if it isn't nil.
+func scanFixture(t *testing.T, fixture string, dirs []string, ctx *build.Context) *Result {
+	t.Helper()
+
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	s := &Scanner{
+		FS:      os.DirFS(filepath.Join("testdata", fixture)),
+		Now:     func() time.Time { return now },
+		MaxOpen: DefaultMaxOpen,
+		Build:   ctx,
+	}
+	pkgs, errs := s.Scan(dirs)
+	for _, err := range errs {
+		t.Errorf("scanning %s: %v", fixture, err)
+	}
+
+	res := NewResult(pkgs, errs)
+	res.ScannedAt = s.Now()
+	return res
+}
+
+// checkGolden compares got with testdata/golden/name, or rewrites it with
+// -update.
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+
+	path := filepath.Join("testdata", "golden", name)
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run go test -update to write it)", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output differs from %s (run go test -update to accept it):\n%s", path, got)
+	}
+}
+
+func TestGoldenFormats(t *testing.T) {
+	tests := []struct {
+		golden string
+		format string
+		opts   ReporterOptions
+	}{
+		{"text.golden", "text", ReporterOptions{}},
+		{"text-verbose.golden", "text", ReporterOptions{Verbose: true}},
+		{"datalog.golden", "datalog", ReporterOptions{}},
+		{"json-v1.golden", "json", ReporterOptions{Schema: JSONSchemaV1}},
+		{"json-v2.golden", "json", ReporterOptions{Schema: JSONSchemaV2}},
+		{"yaml.golden", "yaml", ReporterOptions{Schema: JSONSchemaV2}},
+		{"dot.golden", "dot", ReporterOptions{}},
+		{"mermaid.golden", "mermaid", ReporterOptions{}},
+		{"graphml.golden", "graphml", ReporterOptions{}},
+		{"cytoscape.golden", "cytoscape", ReporterOptions{}},
+		{"gexf.golden", "gexf", ReporterOptions{}},
+		{"markdown.golden", "markdown", ReporterOptions{}},
+		{"plantuml.golden", "plantuml", ReporterOptions{}},
+		{"d2.golden", "d2", ReporterOptions{}},
+		{"structurizr.golden", "structurizr", ReporterOptions{}},
+		{"csv.golden", "csv", ReporterOptions{Category: true}},
+		{"tsv.golden", "tsv", ReporterOptions{}},
+		{"html.golden", "html", ReporterOptions{}},
+		{"svg.golden", "svg", ReporterOptions{}},
+		{"tree.golden", "tree", ReporterOptions{}},
+		{"matrix.golden", "matrix", ReporterOptions{}},
+		{"dsm.golden", "matrix", ReporterOptions{DSM: true}},
+		{"template.golden", "{{.ImportPath}}:{{range .Deps}} {{.Path}}{{end}}\n", ReporterOptions{}},
+	}
+
+	res := scanFixture(t, "fixture", fixtureDirs, nil)
+	for _, tt := range tests {
+		t.Run(tt.golden, func(t *testing.T) {
+			r, err := NewReporter(tt.format, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			if err := r.Report(&buf, res); err != nil {
+				t.Fatal(err)
+			}
+			checkGolden(t, tt.golden, buf.Bytes())
+		})
+	}
+}
+
+func TestGoldenBuild(t *testing.T) {
+	tests := []struct {
+		golden string
+		goos   string
+	}{
+		{"build-linux.golden", "linux"},
+		{"build-windows.golden", "windows"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.golden, func(t *testing.T) {
+			ctx := build.Default
+			ctx.GOOS = tt.goos
+			res := scanFixture(t, "fixture", fixtureDirs, &ctx)
+
+			var buf bytes.Buffer
+			if err := (TextReporter{}).Report(&buf, res); err != nil {
+				t.Fatal(err)
+			}
+			checkGolden(t, tt.golden, buf.Bytes())
+		})
+	}
+}
@@ -0,0 +1,265 @@
+package wuw
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"go/build"
+	"go/version"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/mod/module"
+)
+
+// GoRequirement is the go directive of an external module, as required by
+// a scanned module with a directive of its own.
+type GoRequirement struct {
+	Module  string
+	Version string
+	// Go is "" when the module's go.mod couldn't be found.
+	Go        string
+	Project   string
+	ProjectGo string
+	Importers []string
+}
+
+// Newer reports whether the module needs a newer Go than the project is on.
+func (r *GoRequirement) Newer() bool {
+	return r.Go != "" && r.ProjectGo != "" && version.Compare("go"+r.Go, "go"+r.ProjectGo) > 0
+}
+
+// ReadGoDirective returns the version of the go directive of a go.mod. One
+// without a directive is taken to be go 1.16, as the go command does.
+func ReadGoDirective(data []byte) string {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "//")
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "go" {
+			return fields[1]
+		}
+	}
+	return "1.16"
+}
+
+// GoModCache returns the dir of the module cache, as the go command works it
+// out.
+func GoModCache() string {
+	if dir := os.Getenv("GOMODCACHE"); dir != "" {
+		return dir
+	}
+	gopath := filepath.SplitList(build.Default.GOPATH)
+	if len(gopath) == 0 {
+		return ""
+	}
+	return filepath.Join(gopath[0], "pkg", "mod")
+}
+
+// GoProxy returns the first proxy of $GOPROXY to fetch from, or "" if there
+// is none, such as when it is direct or off.
+func GoProxy() string {
+	env := os.Getenv("GOPROXY")
+	if env == "" {
+		env = "https://proxy.golang.org"
+	}
+	first, _, _ := strings.Cut(env, ",")
+	first, _, _ = strings.Cut(first, "|")
+	if !strings.HasPrefix(first, "https://") && !strings.HasPrefix(first, "http://") {
+		return ""
+	}
+	return strings.TrimSuffix(first, "/")
+}
+
+// GoModFetcher reads the go.mod of module versions from the module cache,
+// and from the proxy if Client is set and the cache doesn't have it.
+type GoModFetcher struct {
+	Cache  string
+	Proxy  string
+	Client *http.Client
+
+	fetched map[string][]byte
+}
+
+func (f *GoModFetcher) Fetch(mod, ver string) ([]byte, error) {
+	key := mod + "@" + ver
+	if data, ok := f.fetched[key]; ok {
+		return data, nil
+	}
+
+	escPath, err := module.EscapePath(mod)
+	if err != nil {
+		return nil, err
+	}
+	escVer, err := module.EscapeVersion(ver)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := f.fetch(escPath, escVer)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", key, err)
+	}
+	if f.fetched == nil {
+		f.fetched = make(map[string][]byte)
+	}
+	f.fetched[key] = data
+	return data, nil
+}
+
+func (f *GoModFetcher) fetch(escPath, escVer string) ([]byte, error) {
+	if f.Cache != "" {
+		// only the .mod is downloaded for modules that are in the module
+		// graph without any of their packages being built
+		for _, path := range []string{
+			filepath.Join(f.Cache, "cache", "download", escPath, "@v", escVer+".mod"),
+			filepath.Join(f.Cache, escPath+"@"+escVer, "go.mod"),
+		} {
+			if data, err := os.ReadFile(path); err == nil {
+				return data, nil
+			}
+		}
+	}
+
+	if f.Client == nil || f.Proxy == "" {
+		return nil, fmt.Errorf("not in the module cache")
+	}
+	resp, err := f.Client.Get(f.Proxy + "/" + escPath + "/@v/" + escVer + ".mod")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s from %s", resp.Status, f.Proxy)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// GoRequirements returns the go directive of every external module imported
+// by g, for each scanned module importing it, along with the errors of those
+// whose go.mod couldn't be read. Modules replaced by a dir are read from it.
+func GoRequirements(g *Graph, f *GoModFetcher) ([]*GoRequirement, []error) {
+	var ret []*GoRequirement
+	var errs []error
+	projectGo := make(map[string]string)
+
+	for _, m := range ExternalModules(g) {
+		byRoot := make(map[string]*GoRequirement)
+		var roots []string
+		for _, p := range m.Importers {
+			pm := FindModule(g.Pkgs[p].Path)
+			if pm == nil {
+				continue
+			}
+			r, ok := byRoot[pm.Root]
+			if !ok {
+				if _, ok := projectGo[pm.Root]; !ok {
+					var goVer string
+					if data, err := os.ReadFile(filepath.Join(pm.Root, "go.mod")); err == nil {
+						goVer = ReadGoDirective(data)
+					}
+					projectGo[pm.Root] = goVer
+				}
+				r = &GoRequirement{Module: m.Path, Version: LoadModuleVersions(pm.Root).Versions[m.Path], Project: pm.Path, ProjectGo: projectGo[pm.Root]}
+				byRoot[pm.Root] = r
+				roots = append(roots, pm.Root)
+			}
+			r.Importers = append(r.Importers, p)
+		}
+
+		for _, root := range roots {
+			r := byRoot[root]
+			var data []byte
+			var err error
+			if base, dir, ok := strings.Cut(r.Version, " => "); ok {
+				if !filepath.IsAbs(dir) {
+					dir = filepath.Join(root, dir)
+				}
+				r.Version = base
+				data, err = os.ReadFile(filepath.Join(dir, "go.mod"))
+			} else if r.Version == "" {
+				err = fmt.Errorf("%s: no version required by %s", r.Module, r.Project)
+			} else {
+				data, err = f.Fetch(r.Module, r.Version)
+			}
+			if err != nil {
+				errs = append(errs, err)
+			} else {
+				r.Go = ReadGoDirective(data)
+			}
+			ret = append(ret, r)
+		}
+	}
+	return ret, errs
+}
+
+func RunGoVersions(args []string) {
+	fs := flag.NewFlagSet("go-versions", flag.ExitOnError)
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintln(w, "'wuw go-versions' reads the go directive of each external module the scanned packages import, from the module cache, and lists those requiring a newer Go than the module importing them is on, with the packages importing them, to plan toolchain upgrades.")
+		fmt.Fprintf(w, "Usage: %s go-versions [-opts] [dirs...]\nopts:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+
+	allVar := fs.Bool("all", false, "List every module, not only those requiring a newer Go")
+	outVar := OutputFlag(fs)
+	proxyVar := fs.Bool("proxy", false, "Fetch the go.mod of modules missing from the module cache from the first proxy of $GOPROXY")
+
+	ParseFlags(fs, args)
+
+	dirs := ReadArgs(fs.Args(), fs.Usage)
+	pkgs, errs := ScanDirs(dirs, true)
+
+	PrintErrors(errs)
+
+	g := NewGraph(pkgs)
+
+	f := &GoModFetcher{Cache: GoModCache()}
+	if *proxyVar {
+		f.Proxy = GoProxy()
+		f.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	reqs, errs := GoRequirements(g, f)
+	PrintErrors(errs)
+
+	w := OpenOutput(*outVar)
+	defer w.Close()
+
+	var newer []*GoRequirement
+	for _, r := range reqs {
+		if r.Newer() {
+			newer = append(newer, r)
+		}
+		if !*allVar && !r.Newer() {
+			continue
+		}
+
+		fmt.Fprintf(w, "%s %s", r.Module, r.Version)
+		switch {
+		case r.Go == "":
+			fmt.Fprint(w, "\tgo version unknown")
+		case r.Newer():
+			fmt.Fprintf(w, "\trequires go %s, %s is on go %s", r.Go, r.Project, r.ProjectGo)
+		default:
+			fmt.Fprintf(w, "\tgo %s", r.Go)
+		}
+		fmt.Fprintln(w)
+		for _, p := range r.Importers {
+			fmt.Fprintf(w, "\t%s\n", p)
+		}
+	}
+
+	if len(newer) == 0 {
+		fmt.Fprintln(w, "no module requires a newer Go than its importers are on")
+		return
+	}
+	needed := slices.MaxFunc(newer, func(a, b *GoRequirement) int { return version.Compare("go"+a.Go, "go"+b.Go) })
+	fmt.Fprintf(w, "\n%d modules require a newer Go, up to go %s for %s\n", len(newer), needed.Go, needed.Module)
+}
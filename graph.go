@@ -0,0 +1,492 @@
+package wuw
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Graph is the import graph of a set of scanned packages, keyed by import
+// path. Order holds the import paths sorted, for walks over it to be
+// deterministic.
+type Graph struct {
+	Pkgs  map[string]*Package
+	Order []string
+}
+
+// Metrics are the aggregate measures of a Graph. ExternalDeps counts the
+// distinct imports of neither the scanned packages nor the standard library.
+type Metrics struct {
+	Packages      int
+	InternalEdges int
+	ExternalDeps  int
+	AvgFanOut     float64
+	MaxFanIn      int
+	MaxDepth      int
+	Cycles        int
+}
+
+// NewGraph returns the graph of pkgs, keeping the first of those sharing an
+// import path. It points into pkgs rather than copying them.
+func NewGraph(pkgs []Package) *Graph {
+	g := &Graph{Pkgs: make(map[string]*Package)}
+	for i := range pkgs {
+		p := &pkgs[i]
+		if _, ok := g.Pkgs[p.ImportPath]; ok {
+			continue
+		}
+		g.Pkgs[p.ImportPath] = p
+		g.Order = append(g.Order, p.ImportPath)
+	}
+	slices.Sort(g.Order)
+	return g
+}
+
+// Clone returns a copy of g whose packages and dep slices can be modified
+// without affecting the original.
+func (g *Graph) Clone() *Graph {
+	var pkgs []Package
+	for _, path := range g.Order {
+		p := *g.Pkgs[path]
+		p.Deps = slices.Clone(p.Deps)
+		pkgs = append(pkgs, p)
+	}
+	return NewGraph(pkgs)
+}
+
+// Remove drops the package at path from g. Imports of it are left in place,
+// becoming those of a package that wasn't scanned.
+func (g *Graph) Remove(path string) {
+	delete(g.Pkgs, path)
+	g.Order = slices.DeleteFunc(g.Order, func(s string) bool { return s == path })
+}
+
+// IsInternal reports whether path is one of the scanned packages.
+func (g *Graph) IsInternal(path string) bool {
+	_, ok := g.Pkgs[path]
+	return ok
+}
+
+const (
+	CategoryInternal = "internal"
+	CategoryStd      = "std"
+	CategoryExternal = "external"
+)
+
+// Category returns whether path is one of the scanned packages, part of the
+// standard library, or an external dependency.
+func (g *Graph) Category(path string) string {
+	switch {
+	case g.IsInternal(path):
+		return CategoryInternal
+	case IsStdlib(path):
+		return CategoryStd
+	}
+	return CategoryExternal
+}
+
+// Imports returns everything the package at path imports, internal or not,
+// or nil if it wasn't scanned.
+func (g *Graph) Imports(path string) []string {
+	p, ok := g.Pkgs[path]
+	if !ok {
+		return nil
+	}
+	return slices.Clone(p.Deps)
+}
+
+// InternalDeps returns the scanned packages the package at path imports,
+// other than itself. The package at path must have been scanned.
+func (g *Graph) InternalDeps(path string) []string {
+	var ret []string
+	for _, d := range g.Pkgs[path].Deps {
+		if g.IsInternal(d) && d != path {
+			ret = append(ret, d)
+		}
+	}
+	return ret
+}
+
+// Importers returns the scanned packages importing path, sorted.
+func (g *Graph) Importers(path string) []string {
+	var ret []string
+	for _, p := range g.Order {
+		if slices.Contains(g.Pkgs[p].Deps, path) {
+			ret = append(ret, p)
+		}
+	}
+	return ret
+}
+
+// SCCs returns the strongly connected components of the internal graph using
+// Tarjan's algorithm. Components are returned in reverse topological order.
+func (g *Graph) SCCs() [][]string {
+	var (
+		index   = make(map[string]int)
+		low     = make(map[string]int)
+		onStack = make(map[string]bool)
+		stack   []string
+		comps   [][]string
+		next    int
+	)
+
+	var connect func(v string)
+	connect = func(v string) {
+		index[v] = next
+		low[v] = next
+		next++
+		stack = append(stack, v)
+		onStack[v] = true
+
+		for _, w := range g.InternalDeps(v) {
+			if _, seen := index[w]; !seen {
+				connect(w)
+				low[v] = min(low[v], low[w])
+			} else if onStack[w] {
+				low[v] = min(low[v], index[w])
+			}
+		}
+
+		if low[v] == index[v] {
+			var comp []string
+			for {
+				w := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[w] = false
+				comp = append(comp, w)
+				if w == v {
+					break
+				}
+			}
+			slices.Sort(comp)
+			comps = append(comps, comp)
+		}
+	}
+
+	for _, p := range g.Order {
+		if _, seen := index[p]; !seen {
+			connect(p)
+		}
+	}
+	return comps
+}
+
+// Cycles returns the import cycles among the scanned packages, as the
+// strongly connected components of more than one package.
+func (g *Graph) Cycles() [][]string {
+	var ret [][]string
+	for _, c := range g.SCCs() {
+		if len(c) > 1 {
+			ret = append(ret, c)
+		}
+	}
+	return ret
+}
+
+// Depths returns the length of the longest chain of internal imports starting
+// at each package. Packages in the same cycle share a depth.
+func (g *Graph) Depths() map[string]int {
+	comp := make(map[string]int)
+	sccs := g.SCCs()
+	for i, c := range sccs {
+		for _, p := range c {
+			comp[p] = i
+		}
+	}
+
+	// reverse topological order means every dependency's component has
+	// already been visited by the time we get to its importers
+	depth := make([]int, len(sccs))
+	for i, c := range sccs {
+		for _, p := range c {
+			for _, d := range g.InternalDeps(p) {
+				if comp[d] != i {
+					depth[i] = max(depth[i], depth[comp[d]]+1)
+				}
+			}
+		}
+	}
+
+	ret := make(map[string]int)
+	for p, i := range comp {
+		ret[p] = depth[i]
+	}
+	return ret
+}
+
+// Metrics leaves experimental packages out, so that sandboxes don't count
+// against the health of the rest.
+func (g *Graph) Metrics() Metrics {
+	g = g.WithoutExperimental()
+	m := Metrics{Packages: len(g.Order)}
+
+	external := make(map[string]struct{})
+	fanIn := make(map[string]int)
+	for _, p := range g.Order {
+		for _, d := range g.Pkgs[p].Deps {
+			if g.IsInternal(d) {
+				if d != p {
+					m.InternalEdges++
+					fanIn[d]++
+				}
+			} else if g.Category(d) == CategoryExternal {
+				external[d] = struct{}{}
+			}
+		}
+	}
+	m.ExternalDeps = len(external)
+
+	for _, n := range fanIn {
+		m.MaxFanIn = max(m.MaxFanIn, n)
+	}
+	if m.Packages != 0 {
+		m.AvgFanOut = float64(m.InternalEdges) / float64(m.Packages)
+	}
+	for _, d := range g.Depths() {
+		m.MaxDepth = max(m.MaxDepth, d)
+	}
+	m.Cycles = len(g.Cycles())
+
+	return m
+}
+
+// MatchesPath reports whether path is pattern or a package below it.
+func MatchesPath(path, pattern string) bool {
+	return path == pattern || strings.HasPrefix(path, pattern+"/")
+}
+
+// Resolve expands a module-relative path such as internal/util into the
+// import path of the scanned packages it refers to. Paths that don't refer to
+// a scanned package are returned unchanged.
+func (g *Graph) Resolve(path string) string {
+	for _, p := range g.Order {
+		if MatchesPath(p, path) {
+			return path
+		}
+	}
+
+	path = strings.TrimPrefix(path, "./")
+	for _, p := range g.Order {
+		m := FindModule(g.Pkgs[p].Path)
+		if m == nil {
+			continue
+		}
+		full := m.Path + "/" + path
+		if MatchesPath(p, full) {
+			return full
+		}
+	}
+	return path
+}
+
+// CanImport reports whether Go's internal package rule allows importer to
+// import imported.
+func CanImport(importer, imported string) bool {
+	elems := strings.Split(imported, "/")
+	for i := len(elems) - 1; i >= 0; i-- {
+		if elems[i] == "internal" {
+			parent := strings.Join(elems[:i], "/")
+			return parent == "" || MatchesPath(importer, parent)
+		}
+	}
+	return true
+}
+
+type Edge struct {
+	From string
+	To   string
+}
+
+// Violations returns every internal edge that breaks Go's internal package
+// rule.
+func (g *Graph) Violations() []Edge {
+	var ret []Edge
+	for _, p := range g.Order {
+		for _, d := range g.InternalDeps(p) {
+			if !CanImport(p, d) {
+				ret = append(ret, Edge{From: p, To: d})
+			}
+		}
+	}
+	return ret
+}
+
+// Lookup finds a scanned package by import path, module-relative path or
+// directory.
+func (g *Graph) Lookup(arg string) (*Package, bool) {
+	if p, ok := g.Pkgs[g.Resolve(arg)]; ok {
+		return p, true
+	}
+	for _, p := range g.Order {
+		if filepath.Clean(g.Pkgs[p].Path) == filepath.Clean(arg) {
+			return g.Pkgs[p], true
+		}
+	}
+	return nil, false
+}
+
+// Path returns the shortest chain of internal imports leading from one
+// package to another, including both ends, or nil if there is none or from
+// wasn't scanned.
+func (g *Graph) Path(from, to string) []string {
+	if !g.IsInternal(from) {
+		return nil
+	}
+	prev := map[string]string{from: ""}
+	queue := []string{from}
+	for len(queue) != 0 {
+		p := queue[0]
+		queue = queue[1:]
+		if p == to {
+			var path []string
+			for ; p != ""; p = prev[p] {
+				path = append(path, p)
+			}
+			slices.Reverse(path)
+			return path
+		}
+		for _, d := range g.InternalDeps(p) {
+			if _, seen := prev[d]; !seen {
+				prev[d] = p
+				queue = append(queue, d)
+			}
+		}
+	}
+	return nil
+}
+
+// Reachable returns the internal packages from imports, directly or not,
+// including from itself, sorted, or nil if from wasn't scanned.
+func (g *Graph) Reachable(from string) []string {
+	if !g.IsInternal(from) {
+		return nil
+	}
+	seen := map[string]bool{from: true}
+	queue := []string{from}
+	for len(queue) != 0 {
+		p := queue[0]
+		queue = queue[1:]
+		for _, d := range g.InternalDeps(p) {
+			if !seen[d] {
+				seen[d] = true
+				queue = append(queue, d)
+			}
+		}
+	}
+
+	var ret []string
+	for p := range seen {
+		ret = append(ret, p)
+	}
+	slices.Sort(ret)
+	return ret
+}
+
+// Dependents returns the internal packages importing to, directly or not,
+// sorted.
+func (g *Graph) Dependents(to string) []string {
+	seen := map[string]bool{to: true}
+	queue := []string{to}
+	var ret []string
+	for len(queue) != 0 {
+		p := queue[0]
+		queue = queue[1:]
+		for _, i := range g.Importers(p) {
+			if !seen[i] {
+				seen[i] = true
+				queue = append(queue, i)
+				ret = append(ret, i)
+			}
+		}
+	}
+	slices.Sort(ret)
+	return ret
+}
+
+// Subgraph returns a copy of g with only the packages keep returns true for.
+// Imports of the packages left out are dropped along with them, so that they
+// aren't taken for external deps.
+func (g *Graph) Subgraph(keep func(*Package) bool) *Graph {
+	ret := g.Clone()
+	var dropped []string
+	for _, p := range g.Order {
+		if !keep(ret.Pkgs[p]) {
+			dropped = append(dropped, p)
+			ret.Remove(p)
+		}
+	}
+	for _, p := range ret.Order {
+		pkg := ret.Pkgs[p]
+		pkg.Deps = slices.DeleteFunc(pkg.Deps, func(d string) bool { return slices.Contains(dropped, d) })
+	}
+	return ret
+}
+
+// MarshalJSON writes g in the form of the packages of JSON output, at the
+// current schema, without the files of each package.
+func (g *Graph) MarshalJSON() ([]byte, error) {
+	var pkgs []Package
+	for _, p := range g.Order {
+		pkgs = append(pkgs, *g.Pkgs[p])
+	}
+	report := newJSONReportV2(NewResult(pkgs, nil), false)
+	return json.Marshal(struct {
+		Schema   string          `json:"schema"`
+		Packages []jsonPackageV2 `json:"packages"`
+	}{report.Schema, report.Packages})
+}
+
+// UnmarshalJSON reads g from what MarshalJSON writes, or from the JSON
+// output of a scan, whose other fields are ignored.
+func (g *Graph) UnmarshalJSON(data []byte) error {
+	var report struct {
+		Schema   string          `json:"schema"`
+		Packages []jsonPackageV2 `json:"packages"`
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		return err
+	}
+	if report.Schema != JSONSchemaV2 {
+		return fmt.Errorf("graph: unsupported schema %q, want %s", report.Schema, JSONSchemaV2)
+	}
+
+	var pkgs []Package
+	for _, jp := range report.Packages {
+		p := Package{
+			Name:         jp.Name,
+			Path:         jp.Path,
+			ImportPath:   jp.ImportPath,
+			Generated:    jp.Generated,
+			Sources:      jp.Sources,
+			TestSupport:  jp.TestSupport,
+			Experimental: jp.Experimental,
+			Cgo:          jp.Cgo,
+		}
+		for _, i := range jp.Imports {
+			p.Deps = append(p.Deps, i.Path)
+			if len(i.Qualifiers) != 0 {
+				if p.Qualifiers == nil {
+					p.Qualifiers = make(map[string][]string)
+				}
+				p.Qualifiers[i.Path] = i.Qualifiers
+			}
+			if len(i.Aliases) != 0 {
+				if p.Aliases == nil {
+					p.Aliases = make(map[string][]string)
+				}
+				p.Aliases[i.Path] = i.Aliases
+			}
+			if len(i.Platforms) != 0 {
+				if p.Platforms == nil {
+					p.Platforms = make(map[string][]string)
+				}
+				p.Platforms[i.Path] = i.Platforms
+			}
+		}
+		pkgs = append(pkgs, p)
+	}
+	*g = *NewGraph(pkgs)
+	return nil
+}
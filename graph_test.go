@@ -0,0 +1,36 @@
+package wuw
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestGraphQueries(t *testing.T) {
+	g := NewGraph([]Package{
+		{Name: "a", ImportPath: "m/a", Deps: []string{"m/b", "fmt"}},
+		{Name: "b", ImportPath: "m/b", Deps: []string{"m/c"}},
+		{Name: "c", ImportPath: "m/c"},
+	})
+
+	tests := []struct {
+		name string
+		got  []string
+		want []string
+	}{
+		{"Path(a, c)", g.Path("m/a", "m/c"), []string{"m/a", "m/b", "m/c"}},
+		{"Path(c, a)", g.Path("m/c", "m/a"), nil},
+		{"Path(a, fmt)", g.Path("m/a", "fmt"), nil},
+		{"Path(unscanned, a)", g.Path("m/x", "m/a"), nil},
+		{"Path(unscanned, unscanned)", g.Path("m/x", "m/x"), nil},
+		{"Reachable(a)", g.Reachable("m/a"), []string{"m/a", "m/b", "m/c"}},
+		{"Reachable(c)", g.Reachable("m/c"), []string{"m/c"}},
+		{"Reachable(std)", g.Reachable("fmt"), nil},
+		{"Reachable(unscanned)", g.Reachable("m/x"), nil},
+		{"Imports(unscanned)", g.Imports("m/x"), nil},
+	}
+	for _, tt := range tests {
+		if !slices.Equal(tt.got, tt.want) {
+			t.Errorf("%s = %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package wuw
+
+import (
+	"encoding/xml"
+	"io"
+	"path"
+	"strings"
+)
+
+type graphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	NS      string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// WriteGraphML writes g as GraphML, for yEd, Gephi and other graph editors.
+// Every package is a node, deps included, keyed by import path and carrying
+// its name, dir, category, group and whether it is experimental as
+// attributes. Edges carry the
+// qualifiers of the import, if any.
+func WriteGraphML(w io.Writer, g *Graph, groups *Groups) error {
+	doc := graphML{
+		NS: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphMLKey{
+			{ID: "name", For: "node", Name: "name", Type: "string"},
+			{ID: "path", For: "node", Name: "path", Type: "string"},
+			{ID: "category", For: "node", Name: "category", Type: "string"},
+			{ID: "group", For: "node", Name: "group", Type: "string"},
+			{ID: "experimental", For: "node", Name: "experimental", Type: "boolean"},
+			{ID: "qualifiers", For: "edge", Name: "qualifiers", Type: "string"},
+		},
+		Graph: graphMLGraph{ID: "wuw", EdgeDefault: "directed"},
+	}
+
+	seen := make(map[string]bool)
+	addNode := func(p string) {
+		if seen[p] {
+			return
+		}
+		seen[p] = true
+		n := graphMLNode{ID: p}
+		if pkg, ok := g.Pkgs[p]; ok {
+			n.Data = append(n.Data, graphMLData{Key: "name", Value: pkg.Name}, graphMLData{Key: "path", Value: RelPath(pkg.Path)})
+			if pkg.Experimental {
+				n.Data = append(n.Data, graphMLData{Key: "experimental", Value: "true"})
+			}
+		} else {
+			n.Data = append(n.Data, graphMLData{Key: "name", Value: path.Base(p)})
+		}
+		n.Data = append(n.Data, graphMLData{Key: "category", Value: g.Category(p)})
+		if group := groups.Of(p); group != "" {
+			n.Data = append(n.Data, graphMLData{Key: "group", Value: group})
+		}
+		doc.Graph.Nodes = append(doc.Graph.Nodes, n)
+	}
+
+	for _, p := range g.Order {
+		addNode(p)
+	}
+	for _, p := range g.Order {
+		pkg := g.Pkgs[p]
+		for _, d := range pkg.Deps {
+			addNode(d)
+			e := graphMLEdge{Source: p, Target: d}
+			if q := pkg.Qualifiers[d]; len(q) != 0 {
+				e.Data = append(e.Data, graphMLData{Key: "qualifiers", Value: strings.Join(q, ",")})
+			}
+			doc.Graph.Edges = append(doc.Graph.Edges, e)
+		}
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
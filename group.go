@@ -0,0 +1,94 @@
+package wuw
+
+import (
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// GroupKey returns the import path pkg is aggregated under when grouping at
+// depth, counting path segments from its module root.
+func GroupKey(pkg *Package, depth int) string {
+	prefix := ""
+	rel := pkg.ImportPath
+	if m := FindModule(pkg.Path); m != nil && MatchesPath(pkg.ImportPath, m.Path) {
+		prefix = m.Path
+		rel = strings.TrimPrefix(strings.TrimPrefix(pkg.ImportPath, m.Path), "/")
+	}
+	if rel == "" {
+		return pkg.ImportPath
+	}
+
+	elems := strings.Split(rel, "/")
+	if len(elems) > depth {
+		elems = elems[:depth]
+	}
+	return path.Join(prefix, strings.Join(elems, "/"))
+}
+
+// GroupPackages aggregates pkgs at the given path depth, so that with a depth
+// of 2 internal/payments/api and internal/payments/db become a single
+// internal/payments package that imports everything either of them did.
+func GroupPackages(pkgs []Package, depth int) []Package {
+	keys := make(map[string]string)
+	for i := range pkgs {
+		keys[pkgs[i].ImportPath] = GroupKey(&pkgs[i], depth)
+	}
+
+	var order []string
+	groups := make(map[string]*Package)
+	for _, p := range pkgs {
+		key := keys[p.ImportPath]
+		grp, ok := groups[key]
+		if !ok {
+			// the group's dir is its members' dir with the segments below
+			// the key trimmed off
+			dir := p.Path
+			for range strings.Count(p.ImportPath, "/") - strings.Count(key, "/") {
+				dir = filepath.Dir(dir)
+			}
+			grp = &Package{Name: path.Base(key), ImportPath: key, Path: dir}
+			groups[key] = grp
+			order = append(order, key)
+		}
+
+		if p.ImportPath == key {
+			grp.Name = p.Name
+		}
+		grp.Files = append(grp.Files, p.Files...)
+		grp.Generated = append(grp.Generated, p.Generated...)
+		grp.Sources = append(grp.Sources, p.Sources...)
+
+		for _, dep := range p.Deps {
+			d := dep
+			if k, ok := keys[d]; ok {
+				d = k
+			}
+			if d == key {
+				continue
+			}
+			// an edge of the group only keeps the qualifiers every member's
+			// edge has
+			if !slices.Contains(grp.Deps, d) {
+				grp.Deps = append(grp.Deps, d)
+				if q := p.Qualifiers[dep]; len(q) != 0 {
+					if grp.Qualifiers == nil {
+						grp.Qualifiers = make(map[string][]string)
+					}
+					grp.Qualifiers[d] = q
+				}
+			} else if q := MergeQualifiers(grp.Qualifiers[d], p.Qualifiers[dep]); len(q) != 0 {
+				grp.Qualifiers[d] = q
+			} else {
+				delete(grp.Qualifiers, d)
+			}
+		}
+	}
+
+	var ret []Package
+	for _, key := range order {
+		ret = append(ret, *groups[key])
+	}
+	return ret
+}
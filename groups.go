@@ -0,0 +1,84 @@
+package wuw
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Groups assigns packages to named groups, such as the bounded context or
+// team they belong to, for the graph output to be organized by instead of by
+// directory.
+type Groups struct {
+	Groups []*Group `yaml:"groups"`
+	// Exposure limits which groups, taken as layers, may import external
+	// modules, for 'wuw exposure' to enforce.
+	Exposure []*ExposurePolicy `yaml:"exposure"`
+}
+
+// Group holds the packages whose import path matches one of its Packages
+// regexes. A package belongs to the first group that matches it.
+type Group struct {
+	Name     string   `yaml:"name"`
+	Packages []string `yaml:"packages"`
+
+	packages []*regexp.Regexp
+}
+
+func LoadGroups(path string) (*Groups, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var gs Groups
+	if err := yaml.Unmarshal(data, &gs); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	for _, g := range gs.Groups {
+		for _, p := range g.Packages {
+			re, err := regexp.Compile(p)
+			if err != nil {
+				return nil, fmt.Errorf("%s: group %s: %w", path, g.Name, err)
+			}
+			g.packages = append(g.packages, re)
+		}
+	}
+	for _, e := range gs.Exposure {
+		if err := e.Compile(gs.Names()); err != nil {
+			return nil, fmt.Errorf("%s: exposure %s: %w", path, e.Name, err)
+		}
+	}
+	return &gs, nil
+}
+
+// Of returns the name of the group the package at path belongs to, or "" if
+// it belongs to none.
+func (gs *Groups) Of(path string) string {
+	if gs == nil {
+		return ""
+	}
+	for _, g := range gs.Groups {
+		for _, re := range g.packages {
+			if re.MatchString(path) {
+				return g.Name
+			}
+		}
+	}
+	return ""
+}
+
+// Names returns the names of the groups, in the order of the file.
+func (gs *Groups) Names() []string {
+	if gs == nil {
+		return nil
+	}
+	var ret []string
+	for _, g := range gs.Groups {
+		ret = append(ret, g.Name)
+	}
+	return ret
+}
@@ -0,0 +1,216 @@
+package wuw
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"golang.org/x/mod/semver"
+)
+
+// DefaultHeavyModules are modules known for how much they bring in with them.
+// The heavy list of the config file adds to them.
+var DefaultHeavyModules = []string{
+	"k8s.io/client-go",
+	"k8s.io/kubernetes",
+	"sigs.k8s.io/controller-runtime",
+	"github.com/aws/aws-sdk-go",
+	"github.com/docker/docker",
+	"github.com/moby/moby",
+	"cloud.google.com/go",
+	"github.com/Azure/azure-sdk-for-go",
+	"github.com/hashicorp/terraform",
+	"github.com/ethereum/go-ethereum",
+	"istio.io/istio",
+	"github.com/cockroachdb/cockroach",
+}
+
+// smallPackageFiles is how many Go files a package can have and still be
+// small enough that a heavy import probably isn't worth it.
+const smallPackageFiles = 5
+
+type HeavyImport struct {
+	Edge
+	Module string
+	// Modules counts the modules Module needs, itself included, or is 0 if
+	// that couldn't be worked out.
+	Modules int
+}
+
+func (h HeavyImport) String() string {
+	s := fmt.Sprintf("%s imports heavyweight module %s", h.From, h.Module)
+	if h.Modules != 0 {
+		s += fmt.Sprintf(" (%d modules)", h.Modules)
+	}
+	return s
+}
+
+func (c *Config) HeavyModules() []string {
+	return append(slices.Clone(DefaultHeavyModules), c.Heavy...)
+}
+
+// HeavyImports finds the small packages of g that import one of the heavy
+// modules. The imports base lists for a package's dir are left out, so that
+// only new ones are reported.
+func HeavyImports(g *Graph, heavy []string, base map[string][]string) []HeavyImport {
+	var ret []HeavyImport
+	for _, p := range g.Order {
+		pkg := g.Pkgs[p]
+		if len(pkg.Files) > smallPackageFiles || pkg.Experimental {
+			continue
+		}
+
+		var seen []string
+		for _, d := range pkg.Deps {
+			i := slices.IndexFunc(heavy, func(h string) bool { return MatchesPath(d, h) })
+			if i == -1 || slices.Contains(base[pkg.Path], d) {
+				continue
+			}
+
+			h := HeavyImport{Edge: Edge{From: p, To: d}, Module: heavy[i]}
+			if m := FindModule(pkg.Path); m != nil {
+				mv := LoadModuleVersions(m.Root)
+				h.Module = mv.ModuleOf(d)
+				if v := mv.Versions[h.Module]; v != "" {
+					h.Modules = len(ModuleClosure(m.Root, h.Module+"@"+v))
+				}
+			}
+			if !slices.Contains(seen, h.Module) {
+				seen = append(seen, h.Module)
+				ret = append(ret, h)
+			}
+		}
+	}
+	return ret
+}
+
+// BaseImports returns what the Go files of each of dirs imported at the git
+// ref of v, keyed by dir. Dirs that didn't exist at ref are left out.
+func BaseImports(v VCS, ref string, dirs []string) (map[string][]string, error) {
+	ret := make(map[string][]string)
+	for _, d := range dirs {
+		files, err := v.ListFiles(ref, d)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, f := range files {
+			if filepath.Ext(f) != ".go" || filepath.Dir(f) != filepath.Clean(d) {
+				continue
+			}
+			src, err := v.ReadFile(ref, f)
+			if err != nil {
+				return nil, err
+			}
+			imports, _, err := ParseFileForImports(f, []byte(src))
+			if err != nil {
+				return nil, err
+			}
+			ret[d] = append(ret[d], imports...)
+		}
+	}
+	return ret, nil
+}
+
+// modGraphs caches the output of 'go mod graph' for each module root.
+var modGraphs = make(map[string]map[string][]string)
+
+// ModuleGraph returns the requirements of each module@version in the build
+// of the module at root, as reported by 'go mod graph', or nil if it fails.
+func ModuleGraph(root string) map[string][]string {
+	if g, ok := modGraphs[root]; ok {
+		return g
+	}
+	classes := Classes().Module(root)
+	if classes.Graph != nil {
+		modGraphs[root] = classes.Graph
+		return classes.Graph
+	}
+
+	cmd := exec.Command("go", "mod", "graph")
+	cmd.Dir = root
+	out, err := cmd.Output()
+	if err != nil {
+		modGraphs[root] = nil
+		return nil
+	}
+
+	g := make(map[string][]string)
+	for _, line := range strings.Split(string(out), "\n") {
+		from, to, ok := strings.Cut(line, " ")
+		if ok {
+			g[from] = append(g[from], to)
+		}
+	}
+	modGraphs[root] = g
+	// saved right away, as commands needing it such as check often exit
+	// with 1 before the end of the run gets to
+	Classes().SetGraph(classes, g)
+	SaveClassCache()
+	return g
+}
+
+// ModuleClosure returns the module@versions needed by mod in the build of the
+// module at root, mod included, or nil if the module graph can't be read.
+// Each module is listed once, at the version minimal version selection picks
+// for the build, however many versions of it are required along the way. The
+// go and toolchain versions required aren't modules and are left out.
+func ModuleClosure(root, mod string) []string {
+	g := ModuleGraph(root)
+	if g == nil {
+		return nil
+	}
+	selected := SelectedVersions(g)
+	pick := func(m string) string {
+		if path, _, ok := strings.Cut(m, "@"); ok && selected[path] != "" {
+			return path + "@" + selected[path]
+		}
+		return m
+	}
+
+	mod = pick(mod)
+	seen := map[string]bool{mod: true}
+	queue := []string{mod}
+	for len(queue) != 0 {
+		m := queue[0]
+		queue = queue[1:]
+		for _, r := range g[m] {
+			if strings.HasPrefix(r, "go@") || strings.HasPrefix(r, "toolchain@") {
+				continue
+			}
+			if r = pick(r); !seen[r] {
+				seen[r] = true
+				queue = append(queue, r)
+			}
+		}
+	}
+
+	var ret []string
+	for m := range seen {
+		ret = append(ret, m)
+	}
+	slices.Sort(ret)
+	return ret
+}
+
+// SelectedVersions returns the version of each module minimal version
+// selection picks in g, a module graph as ModuleGraph returns: the highest
+// one required.
+func SelectedVersions(g map[string][]string) map[string]string {
+	ret := make(map[string]string)
+	add := func(m string) {
+		path, v, ok := strings.Cut(m, "@")
+		if ok && semver.Compare(v, ret[path]) > 0 {
+			ret[path] = v
+		}
+	}
+	for from, reqs := range g {
+		add(from)
+		for _, r := range reqs {
+			add(r)
+		}
+	}
+	return ret
+}
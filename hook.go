@@ -0,0 +1,166 @@
+package wuw
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+const hookScript = `#!/bin/sh
+# installed by 'wuw hook install'
+exec wuw hook pre-commit "$@"
+`
+
+func RunHook(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, "Usage: %s hook pre-commit|install [-opts]\n", os.Args[0])
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "pre-commit":
+		RunPreCommit(args[1:])
+	case "install":
+		RunHookInstall(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "error: unknown hook %q\n", args[0])
+		os.Exit(1)
+	}
+}
+
+func RunPreCommit(args []string) {
+	fs := flag.NewFlagSet("hook pre-commit", flag.ExitOnError)
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintln(w, "'wuw hook pre-commit' checks the packages with staged Go files against the rules in the config file, exiting with 1 if any are broken.")
+		fmt.Fprintf(w, "Usage: %s hook pre-commit [-opts]\nopts:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+
+	configVar := fs.String("config", DefaultConfig, "Config file to read rules from, relative to the repo root")
+
+	ParseFlags(fs, args)
+
+	v, err := OpenVCS(".")
+	if err != nil {
+		Fatal(err)
+	}
+	root, err := v.Root()
+	if err != nil {
+		Fatal(err)
+	}
+	if err := os.Chdir(root); err != nil {
+		Fatal(err)
+	}
+	// reopened for paths to be relative to the root
+	if v, err = OpenVCS("."); err != nil {
+		Fatal(err)
+	}
+
+	c, err := LoadConfig(*configVar)
+	if os.IsNotExist(err) {
+		return
+	} else if err != nil {
+		Fatal(err)
+	}
+
+	staged, err := v.Staged()
+	if err != nil {
+		Fatal(err)
+	}
+
+	dirs := StagedDirs(staged)
+	if len(dirs) == 0 {
+		return
+	}
+
+	// what is about to be committed, not what is on disk
+	fsys, err := RefFS(v, Index, dirs)
+	if err != nil {
+		Fatal(err)
+	}
+	s := NewScanner(false)
+	s.FS = fsys
+	pkgs, errs := s.Scan(dirs)
+	PrintErrors(errs)
+	if err := MarkExperimental(pkgs); err != nil {
+		Fatal(err)
+	}
+
+	g := NewGraph(pkgs)
+
+	// a repo without commits yet has no base, and everything is new
+	base, _ := BaseImports(v, "HEAD", dirs)
+	for _, h := range HeavyImports(g, c.HeavyModules(), base) {
+		fmt.Fprintf(os.Stderr, "wuw: warning: %s\n", h)
+	}
+
+	violations := c.Check(g)
+	for _, v := range violations {
+		fmt.Fprintf(os.Stderr, "%s -> %s: %s", v.From, v.To, v.Rule.Name)
+		if v.Rule.Reason != "" {
+			fmt.Fprintf(os.Stderr, " (%s)", v.Rule.Reason)
+		}
+		fmt.Fprintln(os.Stderr)
+	}
+
+	if len(violations) != 0 {
+		fmt.Fprintf(os.Stderr, "wuw: %d violations in staged packages\n", len(violations))
+		os.Exit(1)
+	}
+}
+
+// StagedDirs returns the dirs containing the Go files among files.
+func StagedDirs(files []string) []string {
+	var dirs []string
+	for _, f := range files {
+		if filepath.Ext(f) != ".go" {
+			continue
+		}
+		d := filepath.Dir(f)
+		if !slices.Contains(dirs, d) {
+			dirs = append(dirs, d)
+		}
+	}
+	slices.Sort(dirs)
+	return dirs
+}
+
+func RunHookInstall(args []string) {
+	fs := flag.NewFlagSet("hook install", flag.ExitOnError)
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintln(w, "'wuw hook install' writes a git pre-commit hook running 'wuw hook pre-commit'.")
+		fmt.Fprintf(w, "Usage: %s hook install [-opts]\nopts:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+
+	forceVar := fs.Bool("force", false, "Overwrite an existing pre-commit hook")
+
+	ParseFlags(fs, args)
+
+	v, err := OpenVCS(".")
+	if err != nil {
+		Fatal(err)
+	}
+	hooks, err := v.HooksDir()
+	if err != nil {
+		Fatal(err)
+	}
+	path := filepath.Join(hooks, "pre-commit")
+
+	if _, err := os.Stat(path); err == nil && !*forceVar {
+		fmt.Fprintf(os.Stderr, "error: %s already exists, use -force to overwrite it\n", path)
+		os.Exit(1)
+	}
+
+	if err := os.MkdirAll(hooks, 0o755); err != nil {
+		Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(hookScript), 0o755); err != nil {
+		Fatal(err)
+	}
+	fmt.Printf("installed %s\n", path)
+}
@@ -0,0 +1,91 @@
+package wuw
+
+import (
+	_ "embed"
+	"html/template"
+	"io"
+)
+
+//go:embed report.html
+var reportHTML string
+
+var reportTemplate = template.Must(template.New("report").Parse(reportHTML))
+
+type htmlGraph struct {
+	Width  int         `json:"width"`
+	Height int         `json:"height"`
+	Nodes  []*htmlNode `json:"nodes"`
+}
+
+type htmlNode struct {
+	ID           string   `json:"id"`
+	Label        string   `json:"label"`
+	Group        string   `json:"group,omitempty"`
+	Deps         []string `json:"deps"`
+	X            int      `json:"x"`
+	Y            int      `json:"y"`
+	Generated    bool     `json:"generated,omitempty"`
+	TestSupport  bool     `json:"test_support,omitempty"`
+	Experimental bool     `json:"experimental,omitempty"`
+	// Status and DepStatus are how the package and its imports fare
+	// against the rules, if they were checked.
+	Status    string            `json:"status,omitempty"`
+	DepStatus map[string]string `json:"dep_status,omitempty"`
+	// Metrics are the formatted metrics of the package, if there are
+	// derived metrics to show.
+	Metrics map[string]string `json:"metrics,omitempty"`
+}
+
+// WriteHTML writes a single HTML page drawing the LayeredLayout of g, that
+// can be zoomed and panned and shows what a package imports and what imports
+// it when it is clicked. Everything it needs is inlined, so it can be passed
+// around as one file. With status, packages and imports are colored by how
+// they fare against the rules. The metadata of info goes at the bottom of the
+// side panel, and the metrics of a package, if any, under its name.
+func WriteHTML(w io.Writer, g *Graph, groups *Groups, status *RuleStatus, info *DiagramInfo, metrics MetricValues) error {
+	l := LayeredLayout(g, groups)
+	data := htmlGraph{Width: l.NodeWidth, Height: l.NodeHeight}
+	for _, p := range g.Order {
+		pkg, at := g.Pkgs[p], l.ByPkg[p]
+		var deps map[string]string
+		for _, d := range pkg.Deps {
+			if s := status.Edge(Edge{From: p, To: d}); s != "" {
+				if deps == nil {
+					deps = make(map[string]string)
+				}
+				deps[d] = s
+			}
+		}
+		var values map[string]string
+		for name, v := range metrics[p] {
+			if values == nil {
+				values = make(map[string]string)
+			}
+			values[name] = FormatMetric(v)
+		}
+		data.Nodes = append(data.Nodes, &htmlNode{
+			ID:           p,
+			Label:        at.Label,
+			Group:        at.Group,
+			Deps:         append([]string{}, pkg.Deps...),
+			X:            at.X,
+			Y:            at.Y,
+			Generated:    len(pkg.Generated) != 0 && len(pkg.Generated) == len(pkg.Files),
+			TestSupport:  pkg.TestSupport,
+			Experimental: pkg.Experimental,
+			Status:       status.Of(p),
+			DepStatus:    deps,
+			Metrics:      values,
+		})
+	}
+
+	var lines []string
+	if info != nil {
+		lines = info.Lines()
+	}
+	return reportTemplate.Execute(w, struct {
+		Title string
+		Lines []string
+		Data  htmlGraph
+	}{"wuw dependency graph", lines, data})
+}
@@ -0,0 +1,48 @@
+package wuw
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// WriteImporterTree writes the packages importing path as a tree, with the
+// importers of each importer below it, up to depth levels deep, or all the
+// way if depth is 0. Packages already shown higher up are marked rather than
+// expanded again. It returns how many distinct packages import path, directly
+// or not, up to depth.
+func WriteImporterTree(w io.Writer, g *Graph, path string, depth int) int {
+	fmt.Fprintln(w, path)
+
+	affected := make(map[string]bool)
+	expanded := map[string]bool{path: true}
+
+	var walk func(p string, level int)
+	walk = func(p string, level int) {
+		if depth != 0 && level > depth {
+			return
+		}
+		importers := g.Importers(p)
+		slices.Sort(importers)
+		for _, imp := range importers {
+			if imp == p {
+				continue
+			}
+			affected[imp] = true
+
+			indent := strings.Repeat("\t", level)
+			if expanded[imp] {
+				fmt.Fprintf(w, "%s%s (see above)\n", indent, imp)
+				continue
+			}
+			expanded[imp] = true
+			fmt.Fprintf(w, "%s%s\n", indent, imp)
+			walk(imp, level+1)
+		}
+	}
+	walk(path, 1)
+
+	delete(affected, path)
+	return len(affected)
+}
@@ -0,0 +1,195 @@
+package wuw
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+func RunInitOrder(args []string) {
+	fs := flag.NewFlagSet("init-order", flag.ExitOnError)
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintln(w, "'wuw init-order' lists the packages with init() functions or blank-import registration, and the order the internal packages of each main package are initialized in.")
+		fmt.Fprintf(w, "Usage: %s init-order [-opts] [dirs...]\nopts:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+
+	mainVar := fs.String("main", "", "Only report the init order of these comma separated main `packages`")
+	outVar := OutputFlag(fs)
+
+	ParseFlags(fs, args)
+
+	dirs := ReadArgs(fs.Args(), fs.Usage)
+	pkgs, errs := ScanDirs(dirs, false)
+
+	PrintErrors(errs)
+
+	g := NewGraph(pkgs)
+
+	w := OpenOutput(*outVar)
+	defer w.Close()
+
+	effects := make(map[string]InitEffects)
+	for _, p := range g.Order {
+		effects[p] = FindInitEffects(g.Pkgs[p])
+	}
+
+	fmt.Fprintln(w, "packages with init side effects:")
+	for _, p := range g.Order {
+		if e := effects[p]; e.Significant() {
+			fmt.Fprintf(w, "\t%s\t%s\n", p, e)
+		}
+	}
+	fmt.Fprintln(w)
+
+	var mains []string
+	if *mainVar != "" {
+		for _, m := range strings.Split(*mainVar, ",") {
+			pkg, ok := g.Lookup(strings.TrimSpace(m))
+			if !ok {
+				fmt.Fprintf(os.Stderr, "error: package %s was not scanned\n", m)
+				os.Exit(1)
+			}
+			mains = append(mains, pkg.ImportPath)
+		}
+	} else {
+		for _, p := range g.Order {
+			if g.Pkgs[p].Name == "main" {
+				mains = append(mains, p)
+			}
+		}
+	}
+
+	for _, m := range mains {
+		WriteInitOrder(w, g, m, effects)
+	}
+}
+
+type InitEffects struct {
+	// Inits counts the init functions in the package, Statements the
+	// statements across all of them.
+	Inits      int
+	Statements int
+	Blank      []string
+}
+
+func (e InitEffects) Significant() bool {
+	return e.Statements != 0 || len(e.Blank) != 0
+}
+
+func (e InitEffects) String() string {
+	var parts []string
+	if e.Inits != 0 {
+		parts = append(parts, fmt.Sprintf("init() x%d (%d statements)", e.Inits, e.Statements))
+	}
+	if len(e.Blank) != 0 {
+		parts = append(parts, "blank imports "+strings.Join(e.Blank, ", "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+func FindInitEffects(pkg *Package) InitEffects {
+	var e InitEffects
+	fset := token.NewFileSet()
+	for _, name := range pkg.Files {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, name, nil, parser.SkipObjectResolution)
+		if err != nil {
+			continue
+		}
+
+		for _, imp := range f.Imports {
+			if imp.Name == nil || imp.Name.Name != "_" {
+				continue
+			}
+			if p, err := strconv.Unquote(imp.Path.Value); err == nil && !slices.Contains(e.Blank, p) {
+				e.Blank = append(e.Blank, p)
+			}
+		}
+
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv != nil || fn.Name.Name != "init" || fn.Body == nil {
+				continue
+			}
+			e.Inits++
+			e.Statements += len(fn.Body.List)
+		}
+	}
+	slices.Sort(e.Blank)
+	return e
+}
+
+// InitOrder returns the internal packages main depends on in the order they
+// are initialized. As in the spec, the next package initialized is always the
+// first one by import path whose imports have all been initialized.
+func InitOrder(g *Graph, main string) []string {
+	closure := map[string]bool{main: true}
+	queue := []string{main}
+	for len(queue) != 0 {
+		p := queue[0]
+		queue = queue[1:]
+		for _, d := range g.InternalDeps(p) {
+			if !closure[d] {
+				closure[d] = true
+				queue = append(queue, d)
+			}
+		}
+	}
+
+	var pending []string
+	for p := range closure {
+		pending = append(pending, p)
+	}
+	slices.Sort(pending)
+
+	done := make(map[string]bool)
+	var order []string
+	for len(pending) != 0 {
+		next := -1
+		for i, p := range pending {
+			ready := true
+			for _, d := range g.InternalDeps(p) {
+				if !done[d] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				next = i
+				break
+			}
+		}
+		// import cycles don't build, but don't loop forever on them
+		if next == -1 {
+			next = 0
+		}
+
+		done[pending[next]] = true
+		order = append(order, pending[next])
+		pending = slices.Delete(pending, next, next+1)
+	}
+	return order
+}
+
+func WriteInitOrder(w io.Writer, g *Graph, main string, effects map[string]InitEffects) {
+	fmt.Fprintf(w, "init order of %s:\n", main)
+	for i, p := range InitOrder(g, main) {
+		if e := effects[p]; e.Significant() {
+			fmt.Fprintf(w, "%4d. %s\t%s\n", i+1, p, e)
+		} else {
+			fmt.Fprintf(w, "%4d. %s\n", i+1, p)
+		}
+	}
+	fmt.Fprintln(w)
+}
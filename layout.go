@@ -0,0 +1,120 @@
+package wuw
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+)
+
+// Sizes of the boxes of a layout, and the gaps between them.
+const (
+	layoutNodeHeight = 22
+	layoutRowGap     = 10
+	layoutColumnGap  = 80
+	layoutCharWidth  = 7
+	// layoutSweeps is how many times the columns are reordered to bring
+	// packages closer to what they import and are imported by.
+	layoutSweeps = 4
+)
+
+// Layout places the internal packages of a graph for drawing without
+// Graphviz: in columns by depth, so that every import points right, one box
+// per package, all of the same size.
+type Layout struct {
+	Nodes  []*LayoutNode
+	ByPkg  map[string]*LayoutNode
+	Width  int
+	Height int
+	// NodeWidth fits the longest label.
+	NodeWidth  int
+	NodeHeight int
+}
+
+type LayoutNode struct {
+	Pkg   string
+	Label string
+	Group string
+	X, Y  int
+}
+
+// LayoutLabel returns p less the module it is in, which every package of a
+// drawing usually shares.
+func LayoutLabel(g *Graph, p string) string {
+	if m := FindModule(g.Pkgs[p].Path); m != nil {
+		if rest, ok := strings.CutPrefix(p, m.Path+"/"); ok {
+			return rest
+		}
+	}
+	return p
+}
+
+// LayeredLayout lays out the internal packages of g. Within a column,
+// packages are ordered by the mean row of those they are connected to, over
+// a few sweeps, to keep edges short and crossings few, and then kept together
+// by group.
+func LayeredLayout(g *Graph, groups *Groups) *Layout {
+	depths := g.Depths()
+	maxDepth := 0
+	for _, d := range depths {
+		maxDepth = max(maxDepth, d)
+	}
+
+	l := &Layout{ByPkg: make(map[string]*LayoutNode), NodeHeight: layoutNodeHeight}
+	columns := make([][]*LayoutNode, maxDepth+1)
+	for _, p := range g.Order {
+		n := &LayoutNode{Pkg: p, Label: LayoutLabel(g, p), Group: groups.Of(p)}
+		l.NodeWidth = max(l.NodeWidth, len(n.Label)*layoutCharWidth+12)
+		l.Nodes = append(l.Nodes, n)
+		l.ByPkg[p] = n
+
+		col := maxDepth - depths[p]
+		columns[col] = append(columns[col], n)
+	}
+
+	neighbours := make(map[string][]string)
+	for _, p := range g.Order {
+		for _, d := range g.InternalDeps(p) {
+			if d != p {
+				neighbours[p] = append(neighbours[p], d)
+				neighbours[d] = append(neighbours[d], p)
+			}
+		}
+	}
+	row := make(map[string]float64)
+	place := func() {
+		for _, col := range columns {
+			for y, n := range col {
+				row[n.Pkg] = float64(y)
+			}
+		}
+	}
+	place()
+	for range layoutSweeps {
+		for _, col := range columns {
+			bary := make(map[string]float64)
+			for _, n := range col {
+				bary[n.Pkg] = row[n.Pkg]
+				if ns := neighbours[n.Pkg]; len(ns) != 0 {
+					var sum float64
+					for _, m := range ns {
+						sum += row[m]
+					}
+					bary[n.Pkg] = sum / float64(len(ns))
+				}
+			}
+			slices.SortStableFunc(col, func(a, b *LayoutNode) int { return cmp.Compare(bary[a.Pkg], bary[b.Pkg]) })
+		}
+		place()
+	}
+
+	for x, col := range columns {
+		slices.SortStableFunc(col, func(a, b *LayoutNode) int { return strings.Compare(a.Group, b.Group) })
+		for y, n := range col {
+			n.X = x * (l.NodeWidth + layoutColumnGap)
+			n.Y = y * (layoutNodeHeight + layoutRowGap)
+			l.Width = max(l.Width, n.X+l.NodeWidth)
+			l.Height = max(l.Height, n.Y+layoutNodeHeight)
+		}
+	}
+	return l
+}
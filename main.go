@@ -1,57 +1,518 @@
-package main
+package wuw
 
 import (
 	"bufio"
+	"errors"
 	"flag"
 	"fmt"
 	"go/build"
+	"go/parser"
+	"go/token"
 	"os"
 	"path/filepath"
 	"slices"
+	"strconv"
 	"strings"
+	"time"
 )
 
-type Directory struct {
-	Name  string
-	Files []*FileReader
+// Package is a scanned dir: its package name, import path, files and the
+// import paths its files import.
+type Package struct {
+	Name       string
+	Path       string
+	ImportPath string
+	Files      []string
+	Deps       []string
+	// Generated holds the generated Go files of the package and Sources
+	// the non-Go files they were generated from.
+	Generated []string
+	Sources   []string
+	// Qualifiers holds, for the deps that only some kinds of file import,
+	// which kinds those are.
+	Qualifiers map[string][]string
+	// Aliases holds, for the deps that some files import under a local
+	// name, those names, including "_" and ".".
+	Aliases map[string][]string
+	// Platforms holds, for the deps that only some of the platforms scanned
+	// with -all-platforms import, which those are.
+	Platforms map[string][]string
+	// TestSupport is set on packages that only exist to support tests, when
+	// asked to dim them.
+	TestSupport bool
+	// Experimental is set on packages marked //wuw:experimental, or listed
+	// as experimental in the config.
+	Experimental bool
+	// Cgo is set on packages with files importing "C", which is left out of
+	// Deps as it isn't a package but a build requirement.
+	Cgo bool
 }
 
-type FileReader struct {
-	Name string
-	R    *bufio.Reader
+// Usage returns the usage of wuw, with the flags of fs.
+func Usage(fs *flag.FlagSet) func() {
+	return func() {
+		w := fs.Output()
+		fmt.Fprintln(w, "'wuw' is a program for quickly seeing what parts of your Go project depend on what other parts of your project, or what external dependencies they use, so that you can quickly understand the architecture of a codebase.")
+
+		fmt.Fprintf(w, "Usage: %s [-opts] [dirs...]\n       %s <command> [-opts] [dirs...]\n", os.Args[0], os.Args[0])
+		fmt.Fprintln(w, "commands:")
+		fmt.Fprintln(w, "  simulate\tpreview the effect of a refactor on the graph")
+		fmt.Fprintln(w, "  edges\t\tclassify internal imports by the kinds of symbols they use")
+		fmt.Fprintln(w, "  decouple\tgenerate an interface for what one package calls of another")
+		fmt.Fprintln(w, "  check\t\tenforce the import rules in .wuw.yaml")
+		fmt.Fprintln(w, "  config\tvalidate .wuw.yaml")
+		fmt.Fprintln(w, "  export\twrite packages and edges to Parquet files")
+		fmt.Fprintln(w, "  externals\tlist external modules and who imports them")
+		fmt.Fprintln(w, "  init-order\treport init() side effects and initialization order")
+		fmt.Fprintln(w, "  daemon\tkeep scans warm in memory for -use-daemon clients")
+		fmt.Fprintln(w, "  hook\t\tcheck staged packages from a git pre-commit hook")
+		fmt.Fprintln(w, "  stats\t\tprint aggregate metrics of the import graph")
+		fmt.Fprintln(w, "  gate\t\tfail when aggregate metrics exceed thresholds")
+		fmt.Fprintln(w, "  weight\trank packages by how many external modules they drag in")
+		fmt.Fprintln(w, "  stack\t\tsummarize the frameworks in use and who uses them")
+		fmt.Fprintln(w, "  services\tlist the external services each main package talks to")
+		fmt.Fprintln(w, "  mocks\t\tlist the generated mocks and the interfaces they mock")
+		fmt.Fprintln(w, "  diff\t\tcompare the imports with those at a git ref")
+		fmt.Fprintln(w, "  names\t\treport package names that don't match their dir or stutter")
+		fmt.Fprintln(w, "  docs\t\tgenerate a Markdown architecture document")
+		fmt.Fprintln(w, "  risk\t\tscore a change by its dependents and test coverage")
+		fmt.Fprintln(w, "  explain\tsum up a package on one screen")
+		fmt.Fprintln(w, "  batch\t\trun a script of commands against a single scan")
+		fmt.Fprintln(w, "  go-versions\tlist external modules requiring a newer Go")
+		fmt.Fprintln(w, "  exposure\tlist the external modules each layer imports and enforce who may")
+		fmt.Fprintln(w, "  capabilities\tlist what uses plugin, os/exec, syscall, raw net or cgo")
+		fmt.Fprintln(w, "  changelog\tsum up the architecture changes between two git refs")
+		fmt.Fprintln(w, "  extract-candidates\n\t\tsuggest subtrees that could become separate modules")
+		fmt.Fprintln(w, "Every flag, of every command, can also be set with its WUW_ environment variable, such as WUW_NO_STD=true for -no-std.")
+		fmt.Fprintln(w, "opts:")
+		fs.PrintDefaults()
+	}
 }
 
-type Package struct {
-	Name string
-	Path string
-	Deps []string
-}
+// Main runs the wuw command line on os.Args, as the wuw binary does.
+func Main() {
+	os.Args = ConsumeDaemonFlag(os.Args)
 
-var usage = func() {
-	w := flag.CommandLine.Output()
-	fmt.Fprintln(w, "'wuw' is a program for quickly seeing what parts of your Go project depend on what other parts of your project, or what external dependencies they use, so that you can quickly understand the architecture of a codebase.")
+	if len(os.Args) > 1 && RunCommand(os.Args[1], os.Args[2:]) {
+		SaveClassCache()
+		return
+	}
 
-	fmt.Fprintf(w, "Usage: %s [-opts] [dirs...]\nopts:\n", os.Args[0])
-	flag.PrintDefaults()
+	RunGraph(flag.CommandLine, os.Args[1:])
+	SaveClassCache()
+	os.Exit(0)
 }
 
-func main() {
-	flag.Usage = usage
+// RunCommand runs the command called name with args, reporting whether there
+// is one by that name.
+func RunCommand(name string, args []string) bool {
+	switch name {
+	case "simulate":
+		RunSimulate(args)
+	case "edges":
+		RunEdges(args)
+	case "decouple":
+		RunDecouple(args)
+	case "check":
+		RunCheck(args)
+	case "config":
+		RunConfig(args)
+	case "export":
+		RunExport(args)
+	case "externals":
+		RunExternals(args)
+	case "init-order":
+		RunInitOrder(args)
+	case "daemon":
+		RunDaemon(args)
+	case "hook":
+		RunHook(args)
+	case "stats":
+		RunStats(args)
+	case "gate":
+		RunGate(args)
+	case "weight":
+		RunWeight(args)
+	case "stack":
+		RunStack(args)
+	case "services":
+		RunServices(args)
+	case "mocks":
+		RunMocks(args)
+	case "diff":
+		RunDiff(args)
+	case "names":
+		RunNames(args)
+	case "docs":
+		RunDocs(args)
+	case "risk":
+		RunRisk(args)
+	case "explain":
+		RunExplain(args)
+	case "batch":
+		RunBatch(args)
+	case "go-versions":
+		RunGoVersions(args)
+	case "exposure":
+		RunExposure(args)
+	case "capabilities":
+		RunCapabilities(args)
+	case "changelog":
+		RunChangelog(args)
+	case "extract-candidates":
+		RunExtractCandidates(args)
+	default:
+		return false
+	}
+	return true
+}
 
-	// subdirsVar := flag.Bool("subdirs", false, "Include sub-directories/packages.")
-	noStdVar := flag.Bool("no-std", false, "Exclude stdlib packages (including golang.org/x/)")
+// RunGraph is what wuw does without a command, reporting on the dirs in args
+// with the flags it defines on fs.
+func RunGraph(fs *flag.FlagSet, args []string) {
+	fs.Usage = Usage(fs)
+
+	// subdirsVar := fs.Bool("subdirs", false, "Include sub-directories/packages.")
+	noStdVar := fs.Bool("no-std", false, "Exclude stdlib packages (including golang.org/x/)")
+	formatVar := fs.String("format", "text", "Output format: text, datalog, json, dot, mermaid, graphml, gexf, cytoscape, plantuml, d2, structurizr, csv, tsv, yaml, markdown, html, svg, tree or matrix, or a text/template run for each package such as '{{.ImportPath}} {{join .Deps \",\"}}'")
+	jsonVar := fs.Bool("json", false, "Shorthand for -format json")
+	yamlVar := fs.Bool("yaml", false, "Shorthand for -format yaml, the JSON output as YAML")
+	dotVar := fs.Bool("dot", false, "Shorthand for -format dot, a Graphviz graph of the internal packages")
+	plantumlVar := fs.Bool("plantuml", false, "Shorthand for -format plantuml, a PlantUML component diagram of the internal packages")
+	matrixVar := fs.Bool("matrix", false, "Shorthand for -format matrix, the adjacency matrix of the internal packages")
+	dsmVar := fs.Bool("dsm", false, "Order the matrix as a design structure matrix, with imports against the layering above the diagonal")
+	treeVar := fs.Bool("tree", false, "Shorthand for -format tree, the packages nested under their dirs and their deps under them")
+	svgVar := fs.Bool("svg", false, "Shorthand for -format svg, an SVG image of the internal packages laid out without Graphviz")
+	htmlVar := fs.Bool("html", false, "Shorthand for -format html, a self-contained page with an interactive graph of the internal packages")
+	markdownVar := fs.Bool("markdown", false, "Shorthand for -format markdown, a report with a summary table and a section per package, for docs/ or a wiki")
+	csvVar := fs.Bool("csv", false, "Shorthand for -format csv, an importer,imported edge list")
+	schemaVar := fs.String("schema", CurrentJSONSchema, "Version of the JSON and YAML output to write, v1 or v2, for scripts to pin")
+	jsonSchemaVar := fs.Bool("json-schema", false, "Instead, write the JSON Schema of the JSON and YAML output at -schema, or of each line with -ndjson, to validate and generate code against")
+	categoryVar := fs.Bool("category", false, "Add the category of the imported package (std, internal or external) as a column of csv output")
+	legendVar := LegendFlag(fs)
+	outVar := OutputFlag(fs)
+	positionsVar := fs.Bool("positions", false, "List every import as file:line:col instead, so editors can jump to it, or with JSON and YAML output add the statements importing each import, for codemods")
+	sampleVar := fs.Float64("sample", 1, "Only scan this `fraction` of dirs, picked deterministically, and extrapolate totals")
+	maxDirsVar := fs.Int("max-dirs", 0, "Only scan at most `N` dirs, picked deterministically, and extrapolate totals")
+	fs.StringVar(&daemonSocket, "use-daemon", daemonSocket, "Ask the 'wuw daemon' listening on this `socket` to scan, also accepted before any command")
+	groupDepthVar := fs.Int("group-depth", 0, "Aggregate packages at this many path segments below their module, e.g. internal/payments/... becomes internal/payments at 2")
+	reproducibleVar := fs.Bool("reproducible", false, "Sort all output and omit absolute paths so it is byte-identical across machines")
+	excludeVar := QualifierFlag(fs)
+	importersVar := fs.String("importers", "", "Instead, show the tree of what imports this `package`, and what imports those")
+	depthVar := fs.Int("depth", 1, "With -importers, how many levels of importers to show, or 0 for all")
+	groupsVar := fs.String("groups", "", "Organize the output by the named groups of packages in this YAML `file`")
+	includeVar := fs.String("include", "", "Also scan everything below these comma-separated `roots`, as if given as root/...")
+	testSupportVar := TestSupportFlag(fs)
+	maxOpenVar := fs.Int("max-open", DefaultMaxOpen, "Keep at most this many files open at once while scanning")
+	timingsVar := fs.Bool("timings", false, "Print how long walking, parsing, classifying, building the graph and rendering took to stderr")
+	ndjsonVar := fs.Bool("ndjson", false, "Instead, write each package as a line of JSON as soon as it is scanned, for consumers of very large trees to start on right away")
+	perMainVar := fs.String("per-main", "", "Instead, write a separate output for each main package to this `dir`, scoped to the packages it links in")
+	ruleStatusVar := fs.Bool("rule-status", true, "Color the packages and imports of dot, mermaid, d2 and html output by whether they break the rules of the config file, are exempted by an allow or are clean, if it has any")
+	coverageVar := fs.Bool("coverage", false, "Also report the dirs that were skipped and why, such as being unreadable, ignored or without Go files, at the end of text output, under coverage in JSON and YAML output and on stderr for other formats")
+	TagsFlag(fs)
+	allPlatformsVar, platformsVar := PlatformsFlag(fs)
+	maxLinesVar, maxNodesVar := MaxOutputFlags(fs)
+	verboseVar := fs.Bool("v", false, "Verbose text output, also listing the local names imports are given, such as _ for blank imports")
+	testsVar := fs.Bool("tests", true, "Scan _test.go files, listing the imports only they have under test deps, or external test deps for those of package foo_test, in text output and qualifying them test-only elsewhere; -tests=false leaves them out")
+	islandsVar := fs.Bool("islands", false, "Instead, list the modules and loose dirs holding the Go code found, and how many packages each has")
+
+	ParseFlags(fs, args)
+
+	if *jsonVar {
+		*formatVar = "json"
+	}
+	if *yamlVar {
+		*formatVar = "yaml"
+	}
+	if *dotVar {
+		*formatVar = "dot"
+	}
+	if *plantumlVar {
+		*formatVar = "plantuml"
+	}
+	if *csvVar {
+		*formatVar = "csv"
+	}
+	if *markdownVar {
+		*formatVar = "markdown"
+	}
+	if *htmlVar {
+		*formatVar = "html"
+	}
+	if *svgVar {
+		*formatVar = "svg"
+	}
+	if *treeVar {
+		*formatVar = "tree"
+	}
+	if *matrixVar {
+		*formatVar = "matrix"
+	}
+	if *jsonSchemaVar {
+		schema, err := OutputSchema(*schemaVar, *ndjsonVar)
+		if err != nil {
+			Fatal(err)
+		}
+		w := OpenOutput(*outVar)
+		if _, err := w.Write(schema); err != nil {
+			Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			Fatal(err)
+		}
+		return
+	}
 
-	flag.Parse()
+	reporter, err := NewReporter(*formatVar, ReporterOptions{Positions: *positionsVar, Category: *categoryVar, Schema: *schemaVar, DSM: *dsmVar, Verbose: *verboseVar})
+	if err != nil {
+		Fatal(err)
+	}
 
-	args := flag.Args()
-	if len(args) == 0 {
-		scanner := bufio.NewScanner(os.Stdin)
+	if *perMainVar != "" && *importersVar != "" {
+		Fatal(errors.New("-per-main can't be used with -importers"))
+	}
+	if *ndjsonVar && (*groupDepthVar > 0 || *importersVar != "" || *reproducibleVar) {
+		Fatal(errors.New("-ndjson can't be used with -group-depth, -importers or -reproducible, which need the whole tree"))
+	}
+	if *ndjsonVar && *maxNodesVar > 0 {
+		Fatal(errors.New("-max-nodes can't be used with -ndjson, which writes packages before knowing their importers"))
+	}
+	if *perMainVar != "" && *maxLinesVar > 0 {
+		Fatal(errors.New("-max-output-lines can't be used with -per-main, which writes files rather than output"))
+	}
+
+	excluded, err := ParseQualifiers(*excludeVar)
+	if err != nil {
+		Fatal(err)
+	}
+
+	var platforms []Platform
+	if *allPlatformsVar {
+		if *ndjsonVar {
+			Fatal(errors.New("-all-platforms can't be used with -ndjson, which writes packages before every platform is scanned"))
+		}
+		if platforms, err = ParsePlatforms(*platformsVar); err != nil {
+			Fatal(err)
+		}
+	}
+
+	var groups *Groups
+	if *groupsVar != "" {
+		if groups, err = LoadGroups(*groupsVar); err != nil {
+			Fatal(err)
+		}
+	}
+
+	var timings *Timings
+	if *timingsVar {
+		timings = NewTimings()
+	}
+
+	if *coverageVar {
+		scanCoverage = &Coverage{}
+		defer func() { scanCoverage = nil }()
+	}
+
+	stop := timings.Start(PhaseWalk)
+	args = fs.Args()
+	for _, root := range strings.Split(*includeVar, ",") {
+		if root = strings.TrimSpace(root); root != "" {
+			args = append(args, strings.TrimSuffix(root, "/")+"/...")
+		}
+	}
+	args = ReadArgs(args, fs.Usage)
+	stop()
+
+	if *islandsVar {
+		w := OpenOutput(*outVar)
+		WriteIslands(w, Islands(args))
+		if err := w.Close(); err != nil {
+			Fatal(err)
+		}
+		return
+	}
+
+	sampling := *sampleVar < 1 || *maxDirsVar > 0
+	total := len(args)
+	if sampling {
+		args = SampleDirs(args, *sampleVar, *maxDirsVar)
+	}
+
+	scanner := NewScanner(*noStdVar)
+	scanner.MaxOpen = *maxOpenVar
+	scanner.Timings = timings
+	scanner.NoTests = !*testsVar
+	if *ndjsonVar {
+		w := LimitLines(OpenOutput(*outVar), *maxLinesVar)
+		stream, err := NewNDJSONStream(w, excluded, *testSupportVar, groups)
+		if err != nil {
+			Fatal(err)
+		}
+		var writeErr error
+		scanner.Hooks.OnPackage = func(p *Package) bool {
+			writeErr = stream.Package(p)
+			return writeErr == nil
+		}
+		scanner.Hooks.OnError = func(_ string, err error) bool {
+			writeErr = stream.Error(err)
+			return writeErr == nil
+		}
+		pkgs, _ := scanner.Scan(args)
+		if writeErr != nil {
+			Fatal(writeErr)
+		}
+		if err := w.Close(); err != nil {
+			Fatal(err)
+		}
+		if *coverageVar {
+			WriteCoverage(os.Stderr, scanner.Coverage)
+		}
+		if timings != nil {
+			timings.Write(os.Stderr)
+		}
+		if sampling {
+			WriteSampleSummary(os.Stderr, total, len(args), pkgs)
+		}
+		if l, ok := w.(*lineLimitWriter); ok && l.Omitted() != 0 {
+			os.Exit(ExitTruncated)
+		}
+		return
+	}
+	var pkgs []Package
+	var errs []error
+	if platforms != nil {
+		pkgs, errs = ScanPlatforms(scanner, args, platforms)
+	} else {
+		pkgs, errs = scanner.Scan(args)
+	}
+	if err := MarkExperimental(pkgs); err != nil {
+		Fatal(err)
+	}
+
+	stop = timings.Start(PhaseClassify)
+	pkgs = ExcludeQualified(pkgs, excluded)
+	if pkgs, err = ApplyTestSupport(pkgs, *testSupportVar); err != nil {
+		Fatal(err)
+	}
+
+	if *groupDepthVar > 0 {
+		pkgs = GroupPackages(pkgs, *groupDepthVar)
+	}
+	// the sample summary still goes by every package scanned
+	shown, omittedNodes := TruncateNodes(pkgs, *maxNodesVar)
+	stop()
+
+	stop = timings.Start(PhaseGraph)
+	res := NewResult(shown, errs)
+	stop()
+	res.ScannedAt = scanner.Now()
+	res.Groups = groups
+	coverageInOutput := *formatVar == "text" && !*positionsVar || (*formatVar == "json" || *formatVar == "yaml") && *schemaVar != JSONSchemaV1
+	if *coverageVar && coverageInOutput {
+		res.Coverage = scanner.Coverage
+	}
+	if *reproducibleVar {
+		MakeReproducible(res)
+	}
+	legend := dotLegend
+	if *ruleStatusVar && slices.Contains([]string{"dot", "mermaid", "d2", "html"}, *formatVar) {
+		c, err := LoadConfig(DefaultConfig)
+		if err != nil && !os.IsNotExist(err) {
+			Fatal(err)
+		}
+		if c != nil && len(c.Rules) != 0 {
+			res.Status = c.Status(res.Graph)
+			legend = append(slices.Clone(dotLegend), statusLegend...)
+		}
+	}
+	if *formatVar == "html" {
+		c, err := LoadConfig(DefaultConfig)
+		if err != nil && !os.IsNotExist(err) {
+			Fatal(err)
+		}
+		if derived := c.DerivedMetrics(); len(derived) != 0 {
+			res.Metrics = ComputeMetrics(res.Graph, derived)
+		}
+	}
+	if *legendVar && slices.Contains([]string{"dot", "mermaid", "plantuml", "d2", "structurizr", "html", "svg"}, *formatVar) {
+		res.Diagram = NewDiagramInfo(fs, res.ScannedAt, legend)
+	}
+	if omittedNodes != 0 {
+		note := fmt.Sprintf("%d more packages omitted (-max-nodes %d)", omittedNodes, *maxNodesVar)
+		if res.Diagram != nil {
+			res.Diagram.Notes = append(res.Diagram.Notes, note)
+		}
+		fmt.Fprintf(os.Stderr, "warning: %s\n", note)
+	}
+
+	PrintErrors(errs)
 
-		fi, err := os.Stdin.Stat()
+	stop = timings.Start(PhaseRender)
+	if *perMainVar != "" {
+		written, err := WritePerMain(*perMainVar, res, reporter, *formatVar)
 		if err != nil {
-			panic(err)
+			Fatal(err)
 		}
-		if fi.Mode()&os.ModeCharDevice != 0 {
+		stop()
+		for _, path := range written {
+			fmt.Fprintf(os.Stderr, "wrote %s\n", path)
+		}
+		if *coverageVar {
+			WriteCoverage(os.Stderr, scanner.Coverage)
+		}
+		if omittedNodes != 0 {
+			os.Exit(ExitTruncated)
+		}
+		return
+	}
+	w := LimitLines(OpenOutput(*outVar), *maxLinesVar)
+	if *importersVar != "" {
+		pkg, ok := res.Graph.Lookup(*importersVar)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "error: package %s was not scanned\n", *importersVar)
+			os.Exit(1)
+		}
+		n := WriteImporterTree(w, res.Graph, pkg.ImportPath, *depthVar)
+		fmt.Fprintf(w, "%d packages affected\n", n)
+	} else if err := reporter.Report(w, res); err != nil {
+		Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		Fatal(err)
+	}
+	stop()
+
+	if *coverageVar && (!coverageInOutput || *importersVar != "") {
+		WriteCoverage(os.Stderr, scanner.Coverage)
+	}
+	if timings != nil {
+		timings.Write(os.Stderr)
+	}
+	if sampling {
+		WriteSampleSummary(os.Stderr, total, len(args), pkgs)
+	}
+	if l, ok := w.(*lineLimitWriter); omittedNodes != 0 || ok && l.Omitted() != 0 {
+		os.Exit(ExitTruncated)
+	}
+}
+
+// ReadArgs returns args, or when no args were given the dirs of the batch
+// being run or the lines of stdin if it is not a terminal, with dir/...
+// expanded. It exits with usage if there is nothing to scan.
+func ReadArgs(args []string, usage func()) []string {
+	return ExpandDirs(ReadRawArgs(args, usage))
+}
+
+// ReadRawArgs is ReadArgs leaving dir/... as it is, for commands expanding
+// it against another tree too.
+func ReadRawArgs(args []string, usage func()) []string {
+	if len(args) == 0 && batchDirs != nil {
+		args = batchDirs
+	}
+	if len(args) == 0 {
+		scanner := bufio.NewScanner(os.Stdin)
+
+		if !HasStdin() {
 			goto noStdin
 		}
 
@@ -61,79 +522,121 @@ func main() {
 		}
 
 		if err := scanner.Err(); err != nil {
-			fmt.Println(err)
-			os.Exit(1)
+			Fatal(err)
 		}
 
 	noStdin:
 		if len(args) == 0 {
-			fmt.Println("No args provided. Displaying usage...")
-			flag.Usage()
+			fmt.Fprintln(os.Stderr, "No args provided. Displaying usage...")
+			usage()
 			os.Exit(1)
 		}
 	}
+	return args
+}
 
-	var pkgs []Package
-	var errs []error
-
-	for _, d := range args {
-		entry, err := os.ReadDir(d)
-		if err != nil {
-			continue
-		}
+// HasStdin reports whether something is being piped into stdin.
+func HasStdin() bool {
+	fi, err := os.Stdin.Stat()
+	if err != nil {
+		panic(err)
+	}
+	return fi.Mode()&os.ModeCharDevice == 0
+}
 
-		go_files := GetGoFiles(d, entry)
-		if len(go_files) == 0 {
-			continue
-		}
+func ScanDirs(dirs []string, noStd bool) ([]Package, []error) {
+	pkgs, errs := NewScanner(noStd).Scan(dirs)
+	if err := MarkExperimental(pkgs); err != nil {
+		Fatal(err)
+	}
+	return pkgs, errs
+}
 
-		dir := Directory{Name: d}
-		for _, g := range go_files {
-			f, err := os.Open(g)
-			if err != nil {
-				errs = append(errs, err)
-				continue
-			}
-			dir.Files = append(dir.Files, &FileReader{g, bufio.NewReader(f)})
+// MakeReproducible sorts the packages of r, their deps and files, rewrites
+// every path they, the coverage and the errors hold to be relative and zeroes
+// the scan time, so output doesn't depend on argument order, the machine or
+// when it ran.
+func MakeReproducible(r *Result) {
+	pkgs := r.Pkgs
+	var roots []string
+	for i := range pkgs {
+		p := &pkgs[i]
+		if m := FindModule(p.Path); m != nil && !slices.Contains(roots, m.Root) {
+			roots = append(roots, m.Root)
 		}
-
-		pkg_name, err := GetPackageName(&dir)
-		if err != nil {
-			errs = append(errs, err)
-			continue
+		slices.Sort(p.Deps)
+		p.Path = RelPath(p.Path)
+		if filepath.IsAbs(p.ImportPath) {
+			p.ImportPath = RelPath(p.ImportPath)
 		}
-
-		var imports []string
-		for _, f := range dir.Files {
-			i, err := ParseFileForImports(f.R)
-			if err != nil {
-				errs = append(errs, err)
-				continue
+		for _, files := range [][]string{p.Files, p.Generated, p.Sources} {
+			for j, f := range files {
+				files[j] = RelPath(f)
 			}
-			for _, s := range i {
-				if !slices.Contains(imports, s) {
-					imports = append(imports, s)
-				}
+			slices.Sort(files)
+		}
+		for _, m := range []map[string][]string{p.Qualifiers, p.Aliases, p.Platforms} {
+			for _, v := range m {
+				slices.Sort(v)
 			}
 		}
+	}
+	slices.SortFunc(pkgs, func(a, b Package) int {
+		return strings.Compare(a.ImportPath, b.ImportPath)
+	})
+	// nested modules first, so their paths aren't taken for the outer one's
+	slices.SortFunc(roots, func(a, b string) int { return len(b) - len(a) })
+
+	for i, err := range r.Errs {
+		if s := RelPaths(err.Error(), roots); s != err.Error() {
+			r.Errs[i] = errors.New(s)
+		}
+	}
+	if r.Coverage != nil {
+		for i := range r.Coverage.Skipped {
+			d := &r.Coverage.Skipped[i]
+			d.Dir = RelPath(d.Dir)
+			d.Detail = RelPaths(d.Detail, roots)
+		}
+	}
+
+	r.Graph = NewGraph(pkgs)
+	r.ScannedAt = time.Time{}
+}
+
+// RelPaths rewrites the paths s mentions under the working directory, or
+// under one of the module roots, as RelPath would.
+func RelPaths(s string, roots []string) string {
+	sep := string(filepath.Separator)
+	if wd, err := os.Getwd(); err == nil {
+		s = strings.ReplaceAll(s, wd+sep, "")
+	}
+	for _, root := range roots {
+		s = strings.ReplaceAll(s, root+sep, filepath.Base(root)+"/")
+	}
+	return s
+}
 
-		pkgs = append(pkgs, Package{Name: pkg_name, Path: d, Deps: FilterDependencies(imports, *noStdVar)})
+// RelPath returns p relative to the working directory, or to its module root
+// if it lies outside of it.
+func RelPath(p string) string {
+	if !filepath.IsAbs(p) {
+		return filepath.ToSlash(filepath.Clean(p))
 	}
 
-	if len(errs) != 0 {
-		fmt.Println("errors:")
-		for _, err := range errs {
-			fmt.Println(err)
+	if wd, err := os.Getwd(); err == nil {
+		rel, err := filepath.Rel(wd, p)
+		if err == nil && !strings.HasPrefix(rel, "..") {
+			return filepath.ToSlash(rel)
 		}
 	}
 
-	for _, p := range pkgs {
-		fmt.Printf("%s:\n%s", p.Path, p.Name)
-		for _, d := range p.Deps {
-			fmt.Printf("\t%s\n", d)
+	if m := FindModule(p); m != nil {
+		if rel, err := filepath.Rel(m.Root, p); err == nil {
+			return filepath.ToSlash(filepath.Join(filepath.Base(m.Root), rel))
 		}
 	}
-	os.Exit(0)
+	return filepath.Base(p)
 }
 
 // TODO
@@ -141,8 +644,7 @@ func FilterDependencies(deps []string, noStd bool) []string {
 	var ret []string
 	for _, d := range deps {
 		if noStd {
-			pkg, err := build.Import(d, "", build.FindOnly)
-			if strings.Contains(d, "golang.org/x/") || (err == nil && pkg.Goroot) {
+			if strings.Contains(d, "golang.org/x/") || IsStdlib(d) {
 				continue
 			}
 		}
@@ -152,89 +654,92 @@ func FilterDependencies(deps []string, noStd bool) []string {
 	return ret
 }
 
-// TODO properly parse instead of relying on gofmt conventions?
-func ParseFileForImports(r *bufio.Reader) ([]string, error) {
-	var imports []string
+var stdlib = make(map[string]bool)
 
-	var linesWithoutImport int
-	for {
-		line, err := r.ReadString('\n')
-		if err != nil {
-			return nil, err
-		}
+func IsStdlib(path string) bool {
+	if std, ok := stdlib[path]; ok {
+		return std
+	}
+	// only stdlib paths lack a dot in their first element, and checking the
+	// rest with go/build would run the go command, which may touch go.mod or
+	// the network
+	first, _, _ := strings.Cut(path, "/")
+	if strings.Contains(first, ".") {
+		stdlib[path] = false
+		return false
+	}
+	if std, ok := Classes().Std[path]; ok {
+		stdlib[path] = std
+		return std
+	}
+	pkg, err := build.Import(path, "", build.FindOnly)
+	stdlib[path] = err == nil && pkg.Goroot
+	Classes().SetStd(path, stdlib[path])
+	return stdlib[path]
+}
 
-		if strings.TrimSpace(line) == "" {
-			linesWithoutImport++
-			continue
-		}
+// ParseFileForImports returns the paths the Go file name, whose contents are
+// src, imports, in the order it imports them, and the local names it gives
+// them, if any. Only the imports are parsed, so the rest of the file doesn't
+// need to be valid Go.
+func ParseFileForImports(name string, src []byte) (imports []string, aliases map[string][]string, err error) {
+	f, err := parser.ParseFile(token.NewFileSet(), name, src, parser.ImportsOnly)
+	if err != nil {
+		return nil, nil, err
+	}
 
-		if strings.Contains(line, "import \"") {
-			split := strings.Fields(line)
-			imports = append(imports, split[1][1:len(split[1])-1])
-			continue
-		} else if strings.Contains(line, "import (") {
-			for {
-				line, err := r.ReadString('\n')
-				if err != nil {
-					return nil, err
-				}
-
-				ts := strings.TrimSpace(line)
-				if ts == ")" {
-					break
-				}
-				if ts == "" {
-					continue
-				}
-
-				split := strings.Fields(line)
-				var imp string
-				if len(split) == 2 {
-					imp = split[1]
-				} else {
-					imp = split[0]
-				}
-
-				imports = append(imports, imp[1:len(imp)-1])
-			}
-		} else {
-			linesWithoutImport++
+	for _, imp := range f.Imports {
+		path, err := strconv.Unquote(imp.Path.Value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: malformed import path %s", name, imp.Path.Value)
 		}
-
-		if linesWithoutImport >= 5 {
-			break
+		imports = append(imports, path)
+		if imp.Name != nil && !slices.Contains(aliases[path], imp.Name.Name) {
+			if aliases == nil {
+				aliases = make(map[string][]string)
+			}
+			aliases[path] = append(aliases[path], imp.Name.Name)
 		}
 	}
+	return imports, aliases, nil
+}
 
-	return imports, nil
+// ReadPackageName returns the package the Go file name, whose contents are
+// src, declares.
+func ReadPackageName(name string, src []byte) (string, error) {
+	f, err := parser.ParseFile(token.NewFileSet(), name, src, parser.PackageClauseOnly)
+	if err != nil {
+		return "", err
+	}
+	return f.Name.Name, nil
 }
 
-func GetPackageName(d *Directory) (string, error) {
+// GetPackageName returns the package the files of dir declare, given the
+// names each of them declares. The external tests of package foo, in package
+// foo_test, go with it.
+func GetPackageName(dir string, names []string) (string, error) {
 	seen := make(map[string]struct{})
 	var pkg_name string
 
-	for _, r := range d.Files {
-		line, err := r.R.ReadString('\n')
-		if err != nil {
-			return "", err
-		}
-
-		fields := strings.Fields(line)
-
-		if len(fields) != 2 || fields[0] != "package" {
-			return "", fmt.Errorf("error: malformed package line: %s in file %s", line, r.Name)
-		}
-
-		pkg_name = fields[1]
+	for _, n := range names {
+		pkg_name = n
 		seen[pkg_name] = struct{}{}
 	}
 
 	if len(seen) == 0 {
-		return "", fmt.Errorf("could not find a package in dir %s", d.Name)
+		return "", fmt.Errorf("could not find a package in dir %s", dir)
+	}
+
+	if len(seen) == 2 {
+		for n := range seen {
+			if _, ok := seen[n+"_test"]; ok {
+				return n, nil
+			}
+		}
 	}
 
 	if len(seen) != 1 {
-		return "", fmt.Errorf("more than one package declaration in folder %s", d.Name)
+		return "", fmt.Errorf("more than one package declaration in folder %s", dir)
 	}
 
 	return pkg_name, nil
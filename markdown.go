@@ -0,0 +1,105 @@
+package wuw
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteMarkdownReport writes a Markdown report of g: totals, a summary
+// table of every package and then a section per package listing what it
+// imports, by category, and what imports it. Unlike WriteDocs it is about
+// the packages rather than the areas they make up, for committing to docs/
+// or pasting into a wiki.
+func WriteMarkdownReport(w io.Writer, g *Graph, groups *Groups) {
+	m := g.Metrics()
+	fmt.Fprintln(w, "# Dependency report")
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "%d packages, with %d internal imports and %d external dependencies, at most %d imports deep", m.Packages, m.InternalEdges, m.ExternalDeps, m.MaxDepth)
+	if m.Cycles != 0 {
+		fmt.Fprintf(w, " with %d import cycles", m.Cycles)
+	}
+	fmt.Fprintln(w, ".")
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, "| package | group | files | internal | std | external | imported by |")
+	fmt.Fprintln(w, "| --- | --- | --- | --- | --- | --- | --- |")
+	for _, p := range g.Order {
+		deps := markdownDeps(g, p)
+		fmt.Fprintf(w, "| [`%s`](#%s) | %s | %d | %d | %d | %d | %d |\n", markdownCell(p), markdownAnchor(p), markdownCell(orNone(groups.Of(p))), len(g.Pkgs[p].Files),
+			len(deps[CategoryInternal]), len(deps[CategoryStd]), len(deps[CategoryExternal]), len(g.Importers(p)))
+	}
+
+	for _, p := range g.Order {
+		pkg := g.Pkgs[p]
+		fmt.Fprintf(w, "\n## %s\n\n", p)
+
+		fmt.Fprintf(w, "Package `%s` in `%s`", pkg.Name, RelPath(pkg.Path))
+		if group := groups.Of(p); group != "" {
+			fmt.Fprintf(w, ", in group %s", group)
+		}
+		fmt.Fprintf(w, ", %d files.", len(pkg.Files))
+		if len(pkg.Generated) != 0 && len(pkg.Generated) == len(pkg.Files) {
+			fmt.Fprint(w, " Generated.")
+		}
+		if pkg.TestSupport {
+			fmt.Fprint(w, " Only there to support tests.")
+		}
+		if pkg.Experimental {
+			fmt.Fprint(w, " Experimental.")
+		}
+		fmt.Fprintln(w)
+
+		deps := markdownDeps(g, p)
+		for _, c := range []string{CategoryInternal, CategoryStd, CategoryExternal} {
+			if len(deps[c]) == 0 {
+				continue
+			}
+			fmt.Fprintf(w, "\n**Imports, %s** (%d)\n\n", c, len(deps[c]))
+			for _, d := range deps[c] {
+				if c == CategoryInternal {
+					fmt.Fprintf(w, "- [`%s`](#%s)\n", d, markdownAnchor(d))
+				} else {
+					fmt.Fprintf(w, "- `%s`\n", d)
+				}
+			}
+		}
+
+		importers := g.Importers(p)
+		fmt.Fprintf(w, "\n**Imported by** (%d)\n\n", len(importers))
+		if len(importers) == 0 {
+			fmt.Fprintln(w, "Nothing that was scanned.")
+		}
+		for _, i := range importers {
+			fmt.Fprintf(w, "- [`%s`](#%s)\n", i, markdownAnchor(i))
+		}
+	}
+}
+
+// markdownDeps returns the deps of p by category.
+func markdownDeps(g *Graph, p string) map[string][]string {
+	ret := make(map[string][]string)
+	for _, d := range g.Pkgs[p].Deps {
+		if d == p {
+			continue
+		}
+		c := g.Category(d)
+		ret[c] = append(ret[c], d)
+	}
+	return ret
+}
+
+// markdownAnchor returns the anchor GitHub and GitLab give the heading s:
+// lowercased, with spaces turned into dashes and other punctuation dropped.
+func markdownAnchor(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case r == ' ':
+			b.WriteRune('-')
+		case r == '-' || r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r > 127:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
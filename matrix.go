@@ -0,0 +1,82 @@
+package wuw
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"unicode/utf8"
+)
+
+// MatrixOrder returns the internal packages of g in the order of the rows
+// and columns of its matrix. With dsm they are ordered as a design structure
+// matrix is, every package after what it imports and a cycle's packages next
+// to each other, so that imports fall below the diagonal and those above it
+// stand out as going against the layering.
+func MatrixOrder(g *Graph, dsm bool) []string {
+	if !dsm {
+		return g.Order
+	}
+	var ret []string
+	for _, c := range g.SCCs() {
+		c = slices.Clone(c)
+		slices.Sort(c)
+		ret = append(ret, c...)
+	}
+	return ret
+}
+
+// WriteMatrix writes the adjacency matrix of the internal packages of g, an
+// x in row i and column j meaning that package i imports package j. With
+// dsm, rows are in MatrixOrder and imports against the layering are marked !
+// instead, and counted at the end.
+func WriteMatrix(w io.Writer, g *Graph, dsm bool) {
+	order := MatrixOrder(g, dsm)
+	index := make(map[string]int)
+	for i, p := range order {
+		index[p] = i
+	}
+
+	width := len(strconv.Itoa(len(order)))
+	var labelWidth int
+	for _, p := range order {
+		labelWidth = max(labelWidth, utf8.RuneCountInString(LayoutLabel(g, p)))
+	}
+	cell := func(s string) string { return fmt.Sprintf(" %*s", width, s) }
+
+	fmt.Fprintf(w, "%*s %-*s ", width, "", labelWidth, "")
+	for i := range order {
+		fmt.Fprint(w, cell(strconv.Itoa(i+1)))
+	}
+	fmt.Fprintln(w)
+
+	var against int
+	for i, p := range order {
+		row := make([]string, len(order))
+		for j := range row {
+			row[j] = "."
+		}
+		row[i] = "-"
+		for _, d := range g.InternalDeps(p) {
+			j := index[d]
+			if j == i {
+				continue
+			}
+			row[j] = "x"
+			if dsm && j > i {
+				row[j] = "!"
+				against++
+			}
+		}
+
+		fmt.Fprintf(w, "%*d %-*s ", width, i+1, labelWidth, LayoutLabel(g, p))
+		for _, c := range row {
+			fmt.Fprint(w, cell(c))
+		}
+		fmt.Fprintln(w)
+	}
+
+	if dsm {
+		fmt.Fprintf(w, "%d imports above the diagonal\n", against)
+	}
+}
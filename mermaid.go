@@ -0,0 +1,117 @@
+package wuw
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// MermaidEdge is an arrow of a Mermaid flowchart, labeled if Label isn't
+// empty.
+type MermaidEdge struct {
+	Edge
+	Label string
+}
+
+// WriteMermaid writes a Mermaid graph TD flowchart of nodes, labeled with
+// label, and edges between them.
+func WriteMermaid(w io.Writer, nodes []string, label func(string) string, edges []MermaidEdge) {
+	fmt.Fprintln(w, "graph TD")
+	for _, n := range nodes {
+		fmt.Fprintf(w, "    %s[%s]\n", MermaidID(n), MermaidLabel(label(n)))
+	}
+	for _, e := range edges {
+		if e.Label != "" {
+			fmt.Fprintf(w, "    %s -->|%s| %s\n", MermaidID(e.From), MermaidLabel(e.Label), MermaidID(e.To))
+		} else {
+			fmt.Fprintf(w, "    %s --> %s\n", MermaidID(e.From), MermaidID(e.To))
+		}
+	}
+}
+
+// WriteMermaidGraph writes the internal packages of g and the imports
+// between them as a Mermaid flowchart fenced for Markdown, which GitHub and
+// GitLab render inline. Packages are grouped in subgraphs by groups if there
+// are any, generated packages are drawn as subroutines, packages only there
+// to support tests dashed and experimental ones greyed out. With status,
+// packages and imports are colored by how they fare against the rules. The
+// metadata of info goes in comments.
+func WriteMermaidGraph(w io.Writer, g *Graph, groups *Groups, status *RuleStatus, info *DiagramInfo) {
+	fmt.Fprintln(w, "```mermaid")
+	fmt.Fprintln(w, "graph TD")
+	if info != nil {
+		for _, l := range info.Lines() {
+			fmt.Fprintf(w, "    %%%% %s\n", l)
+		}
+	}
+
+	var dashed, greyed []string
+	byStatus := make(map[string][]string)
+	node := func(indent, p string) {
+		pkg := g.Pkgs[p]
+		if len(pkg.Generated) != 0 && len(pkg.Generated) == len(pkg.Files) {
+			fmt.Fprintf(w, "%s%s[[%s]]\n", indent, MermaidID(p), MermaidLabel(p))
+		} else {
+			fmt.Fprintf(w, "%s%s[%s]\n", indent, MermaidID(p), MermaidLabel(p))
+		}
+		if pkg.TestSupport {
+			dashed = append(dashed, MermaidID(p))
+		}
+		if pkg.Experimental {
+			greyed = append(greyed, MermaidID(p))
+		}
+		if s := status.Of(p); s != "" {
+			byStatus[s] = append(byStatus[s], MermaidID(p))
+		}
+	}
+
+	if groups != nil {
+		for _, name := range groups.Names() {
+			fmt.Fprintf(w, "    subgraph %s[%s]\n", MermaidID("group:"+name), MermaidLabel(name))
+			for _, p := range g.Order {
+				if groups.Of(p) == name {
+					node("        ", p)
+				}
+			}
+			fmt.Fprintln(w, "    end")
+		}
+	}
+	for _, p := range g.Order {
+		if groups.Of(p) == "" {
+			node("    ", p)
+		}
+	}
+
+	// links are styled by their index, in the order they were written
+	links := make(map[string][]string)
+	var n int
+	for _, p := range g.Order {
+		for _, d := range g.InternalDeps(p) {
+			fmt.Fprintf(w, "    %s --> %s\n", MermaidID(p), MermaidID(d))
+			if s := status.Edge(Edge{From: p, To: d}); s != "" {
+				links[s] = append(links[s], strconv.Itoa(n))
+			}
+			n++
+		}
+	}
+
+	if len(dashed) != 0 {
+		fmt.Fprintln(w, "    classDef testSupport stroke-dasharray: 5 5")
+		fmt.Fprintf(w, "    class %s testSupport\n", strings.Join(dashed, ","))
+	}
+	if len(greyed) != 0 {
+		fmt.Fprintln(w, "    classDef experimental stroke:#999,color:#999")
+		fmt.Fprintf(w, "    class %s experimental\n", strings.Join(greyed, ","))
+	}
+	for _, s := range []string{StatusClean, StatusExempted, StatusViolating} {
+		if len(byStatus[s]) != 0 {
+			fmt.Fprintf(w, "    classDef %s stroke:%s,stroke-width:2px\n", s, statusColors[s])
+			fmt.Fprintf(w, "    class %s %s\n", strings.Join(byStatus[s], ","), s)
+		}
+		if len(links[s]) != 0 {
+			fmt.Fprintf(w, "    linkStyle %s stroke:%s\n", strings.Join(links[s], ","), statusColors[s])
+		}
+	}
+	fmt.Fprintln(w, "```")
+}
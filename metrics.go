@@ -0,0 +1,329 @@
+package wuw
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"maps"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// PackageMetric is a built-in metric of packages, which derived metrics are
+// computed from.
+type PackageMetric struct {
+	Name, Description string
+}
+
+var packageMetrics = []PackageMetric{
+	{"fanin", "internal packages importing it"},
+	{"fanout", "internal packages it imports"},
+	{"deps", "packages it imports"},
+	{"external", "external packages it imports"},
+	{"files", "non-test Go files"},
+	{"loc", "lines of its non-test Go files"},
+	{"tests", "_test.go files"},
+	{"testloc", "lines of its _test.go files"},
+}
+
+// IsPackageMetric reports whether name is a built-in metric of packages.
+func IsPackageMetric(name string) bool {
+	return slices.ContainsFunc(packageMetrics, func(m PackageMetric) bool { return m.Name == name })
+}
+
+// PackageMetrics returns the built-in metrics of the package p of g, reading
+// its files for their line counts.
+func PackageMetrics(g *Graph, p string) map[string]float64 {
+	pkg := g.Pkgs[p]
+	m := make(map[string]float64)
+	for _, b := range packageMetrics {
+		m[b.Name] = 0
+	}
+	m["fanin"] = float64(len(g.Importers(p)))
+	m["fanout"] = float64(len(g.InternalDeps(p)))
+	m["deps"] = float64(len(pkg.Deps))
+	for _, d := range pkg.Deps {
+		if g.Category(d) == CategoryExternal {
+			m["external"]++
+		}
+	}
+	for _, f := range pkg.Files {
+		var lines float64
+		if data, err := os.ReadFile(f); err == nil {
+			lines = float64(bytes.Count(data, []byte("\n")))
+		}
+		if strings.HasSuffix(f, "_test.go") {
+			m["tests"]++
+			m["testloc"] += lines
+		} else {
+			m["files"]++
+			m["loc"] += lines
+		}
+	}
+	return m
+}
+
+// DerivedMetric is a metric of packages defined in the config, as an
+// arithmetic expression over the built-in ones such as fanin * loc / tests.
+// Besides + - * / and parentheses, there are min and max of any number of
+// arguments. Dividing by zero gives +Inf, max(tests, 1) avoids it.
+type DerivedMetric struct {
+	Name string
+	Expr string
+
+	expr ast.Expr
+}
+
+// ParseMetric parses the expression of the derived metric name.
+func ParseMetric(name, expr string) (*DerivedMetric, error) {
+	if !token.IsIdentifier(name) || IsPackageMetric(name) {
+		return nil, fmt.Errorf("metric %q: name must be an identifier other than a built-in metric", name)
+	}
+	e, err := ParseMetricExpr(expr)
+	if err != nil {
+		return nil, fmt.Errorf("metric %s: %w", name, err)
+	}
+	return &DerivedMetric{Name: name, Expr: expr, expr: e}, nil
+}
+
+// ParseMetricExpr parses and checks the expression of a derived metric.
+func ParseMetricExpr(expr string) (ast.Expr, error) {
+	e, err := parser.ParseExpr(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	var check func(e ast.Expr) error
+	check = func(e ast.Expr) error {
+		switch e := e.(type) {
+		case *ast.BasicLit:
+			if e.Kind == token.INT || e.Kind == token.FLOAT {
+				return nil
+			}
+		case *ast.Ident:
+			if IsPackageMetric(e.Name) {
+				return nil
+			}
+			var names []string
+			for _, m := range packageMetrics {
+				names = append(names, m.Name)
+			}
+			return fmt.Errorf("unknown metric %s, want one of %s", e.Name, strings.Join(names, ", "))
+		case *ast.ParenExpr:
+			return check(e.X)
+		case *ast.UnaryExpr:
+			if e.Op == token.SUB || e.Op == token.ADD {
+				return check(e.X)
+			}
+		case *ast.BinaryExpr:
+			if e.Op == token.ADD || e.Op == token.SUB || e.Op == token.MUL || e.Op == token.QUO {
+				if err := check(e.X); err != nil {
+					return err
+				}
+				return check(e.Y)
+			}
+		case *ast.CallExpr:
+			if f, ok := e.Fun.(*ast.Ident); ok && (f.Name == "min" || f.Name == "max") && len(e.Args) != 0 && !e.Ellipsis.IsValid() {
+				for _, a := range e.Args {
+					if err := check(a); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+		}
+		return fmt.Errorf("unsupported expression %s, want numbers and metrics with + - * / ( ) min and max", exprText(expr, e))
+	}
+	if err := check(e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// exprText returns the text of e within the expression expr.
+func exprText(expr string, e ast.Expr) string {
+	// positions are 1-based offsets into expr
+	from, to := int(e.Pos())-1, int(e.End())-1
+	if from < 0 || to > len(expr) || from > to {
+		return fmt.Sprintf("%T", e)
+	}
+	return strconv.Quote(expr[from:to])
+}
+
+// Eval computes m from the built-in metrics of a package.
+func (m *DerivedMetric) Eval(builtin map[string]float64) float64 {
+	var eval func(e ast.Expr) float64
+	eval = func(e ast.Expr) float64 {
+		switch e := e.(type) {
+		case *ast.BasicLit:
+			v, _ := strconv.ParseFloat(e.Value, 64)
+			return v
+		case *ast.Ident:
+			return builtin[e.Name]
+		case *ast.ParenExpr:
+			return eval(e.X)
+		case *ast.UnaryExpr:
+			if e.Op == token.SUB {
+				return -eval(e.X)
+			}
+			return eval(e.X)
+		case *ast.BinaryExpr:
+			x, y := eval(e.X), eval(e.Y)
+			switch e.Op {
+			case token.ADD:
+				return x + y
+			case token.SUB:
+				return x - y
+			case token.MUL:
+				return x * y
+			default:
+				return x / y
+			}
+		case *ast.CallExpr:
+			v := eval(e.Args[0])
+			for _, a := range e.Args[1:] {
+				if e.Fun.(*ast.Ident).Name == "min" {
+					v = math.Min(v, eval(a))
+				} else {
+					v = math.Max(v, eval(a))
+				}
+			}
+			return v
+		}
+		return math.NaN()
+	}
+	return eval(m.expr)
+}
+
+// DerivedMetrics returns the derived metrics of the config, sorted by name.
+func (c *Config) DerivedMetrics() []*DerivedMetric {
+	if c == nil {
+		return nil
+	}
+	return c.metrics
+}
+
+// compileMetrics parses the metrics of c, sorted by name.
+func (c *Config) compileMetrics() error {
+	c.metrics = nil
+	for _, name := range slices.Sorted(maps.Keys(c.Metrics)) {
+		m, err := ParseMetric(name, c.Metrics[name])
+		if err != nil {
+			return err
+		}
+		c.metrics = append(c.metrics, m)
+	}
+	return nil
+}
+
+// MetricValues holds the values of metrics, built-in and derived, of each
+// package.
+type MetricValues map[string]map[string]float64
+
+// ComputeMetrics computes the built-in metrics and the derived metrics of
+// every package of g. Experimental packages are left out, as they are of
+// Graph.Metrics, and so are their imports of and by the others.
+func ComputeMetrics(g *Graph, derived []*DerivedMetric) MetricValues {
+	g = g.WithoutExperimental()
+	ret := make(MetricValues)
+	for _, p := range g.Order {
+		m := PackageMetrics(g, p)
+		for _, d := range derived {
+			m[d.Name] = d.Eval(m)
+		}
+		ret[p] = m
+	}
+	return ret
+}
+
+// FormatMetric formats a metric value, whole numbers without decimals.
+func FormatMetric(v float64) string {
+	return strconv.FormatFloat(v, 'g', 6, 64)
+}
+
+// MetricNames returns the names of the built-in metrics and then those of
+// derived.
+func MetricNames(derived []*DerivedMetric) []string {
+	var ret []string
+	for _, m := range packageMetrics {
+		ret = append(ret, m.Name)
+	}
+	for _, m := range derived {
+		ret = append(ret, m.Name)
+	}
+	return ret
+}
+
+// WriteDerivedMetrics writes the highest and average value of each of
+// derived across the packages of g that aren't experimental.
+func WriteDerivedMetrics(w io.Writer, g *Graph, derived []*DerivedMetric, values MetricValues) {
+	g = g.WithoutExperimental()
+	fmt.Fprintln(w, "derived metrics:")
+	for _, d := range derived {
+		var top string
+		var sum float64
+		for _, p := range g.Order {
+			v := values[p][d.Name]
+			sum += v
+			if top == "" || v > values[top][d.Name] {
+				top = p
+			}
+		}
+		if top == "" {
+			fmt.Fprintf(w, "\t%s = %s: no packages\n", d.Name, d.Expr)
+			continue
+		}
+		fmt.Fprintf(w, "\t%s = %s: max %s (%s), avg %s\n", d.Name, d.Expr, FormatMetric(values[top][d.Name]), top, FormatMetric(sum/float64(len(g.Order))))
+	}
+}
+
+// TopPackages returns the n packages of g that aren't experimental with the
+// highest value of metric, or all of them if n isn't positive.
+func TopPackages(g *Graph, values MetricValues, metric string, n int) []string {
+	ranked := slices.Clone(g.WithoutExperimental().Order)
+	slices.SortStableFunc(ranked, func(a, b string) int {
+		return cmp.Compare(values[b][metric], values[a][metric])
+	})
+	if n > 0 && len(ranked) > n {
+		ranked = ranked[:n]
+	}
+	return ranked
+}
+
+// MetricLimit is the highest value a metric may take for any package.
+type MetricLimit struct {
+	Metric string
+	Limit  float64
+}
+
+// ParseMetricLimits parses comma-separated metric=limit pairs, the metrics
+// being among names.
+func ParseMetricLimits(s string, names []string) ([]MetricLimit, error) {
+	var ret []MetricLimit
+	for _, f := range strings.Split(s, ",") {
+		if f = strings.TrimSpace(f); f == "" {
+			continue
+		}
+		name, limit, ok := strings.Cut(f, "=")
+		name = strings.TrimSpace(name)
+		if !ok {
+			return nil, fmt.Errorf("bad metric limit %q, want metric=limit", f)
+		}
+		if !slices.Contains(names, name) {
+			return nil, fmt.Errorf("unknown metric %s, want one of %s", name, strings.Join(names, ", "))
+		}
+		l, err := strconv.ParseFloat(strings.TrimSpace(limit), 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad limit of metric %s: %w", name, err)
+		}
+		ret = append(ret, MetricLimit{Metric: name, Limit: l})
+	}
+	return ret, nil
+}
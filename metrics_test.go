@@ -0,0 +1,39 @@
+package wuw
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// TestMetricsExperimental checks that derived metrics, like the aggregate
+// ones, leave experimental packages out.
+func TestMetricsExperimental(t *testing.T) {
+	g := NewGraph([]Package{
+		{Name: "a", ImportPath: "m/a", Deps: []string{"m/b"}},
+		{Name: "b", ImportPath: "m/b"},
+		{Name: "x", ImportPath: "m/x", Deps: []string{"m/a", "m/b"}, Experimental: true},
+	})
+	m, err := ParseMetric("reach", "fanin + fanout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	derived := []*DerivedMetric{m}
+
+	values := ComputeMetrics(g, derived)
+	if _, ok := values["m/x"]; ok {
+		t.Errorf("ComputeMetrics() has values for the experimental m/x: %v", values["m/x"])
+	}
+	if got := values["m/b"]["fanin"]; got != 1 {
+		t.Errorf("fanin of m/b = %v, want 1, not counting m/x", got)
+	}
+	if got := TopPackages(g, values, "reach", 0); len(got) != 2 {
+		t.Errorf("TopPackages() = %q, want m/a and m/b", got)
+	}
+
+	var buf bytes.Buffer
+	WriteDerivedMetrics(&buf, g, derived, values)
+	if want := "reach = fanin + fanout: max 1 (m/a), avg 1\n"; !strings.Contains(buf.String(), want) {
+		t.Errorf("WriteDerivedMetrics() = %q, want it to have %q", buf.String(), want)
+	}
+}
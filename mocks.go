@@ -0,0 +1,260 @@
+package wuw
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// mockTools are the mock generators 'wuw mocks' recognizes, by a name their
+// "Code generated" header carries.
+var mockTools = []string{"mockgen", "mockery", "counterfeiter"}
+
+var (
+	// gomock: MockStore is a mock of Store interface.
+	gomockDoc = regexp.MustCompile(`is a mock of (\w+) interface`)
+	// mockery: Store is an autogenerated mock type for the Store type
+	mockeryDoc = regexp.MustCompile(`is an autogenerated mock type for the (\w+) type`)
+)
+
+// Mock is a generated mock of the interface Name of package Pkg, which is
+// empty when it couldn't be told.
+type Mock struct {
+	Tool string
+	Pkg  string
+	Name string
+	// Type is the mock type, declared in package In.
+	Type string
+	In   string
+	File string
+}
+
+// FindMocks finds the mocks generated into the packages of g, and works out
+// which package the interface each of them mocks comes from.
+func FindMocks(g *Graph) []Mock {
+	var mocks []Mock
+	for _, p := range g.Order {
+		pkg := g.Pkgs[p]
+		for _, file := range pkg.Files {
+			mocks = append(mocks, ParseMocks(g, pkg, file)...)
+		}
+	}
+	return mocks
+}
+
+// ParseMocks returns the mocks declared in file of pkg, if it was written by
+// one of the mock generators.
+func ParseMocks(g *Graph, pkg *Package, file string) []Mock {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, file, nil, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return nil
+	}
+
+	var tool, source string
+	for _, cg := range f.Comments {
+		if cg.Pos() > f.Package {
+			break
+		}
+		for _, c := range cg.List {
+			line := strings.TrimSpace(strings.TrimPrefix(c.Text, "//"))
+			if strings.HasPrefix(line, "Code generated") {
+				for _, t := range mockTools {
+					if strings.Contains(strings.ToLower(line), t) {
+						tool = t
+					}
+				}
+			} else if s, ok := strings.CutPrefix(line, "Source: "); ok {
+				source = s
+			}
+		}
+	}
+	if tool == "" {
+		return nil
+	}
+
+	imports := make(map[string]string)
+	for _, imp := range f.Imports {
+		p, err := strconv.Unquote(imp.Path.Value)
+		if err != nil {
+			continue
+		}
+		local := ImportName(p)
+		if dep, ok := g.Pkgs[p]; ok {
+			local = dep.Name
+		}
+		if imp.Name != nil {
+			local = imp.Name.Name
+		}
+		imports[local] = p
+	}
+
+	var mocks []Mock
+	byType := make(map[string]int)
+	for _, decl := range f.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			switch spec := spec.(type) {
+			case *ast.TypeSpec:
+				if _, ok := spec.Type.(*ast.StructType); !ok {
+					continue
+				}
+				doc := spec.Doc
+				if doc == nil {
+					doc = gen.Doc
+				}
+
+				name := ""
+				if m := gomockDoc.FindStringSubmatch(doc.Text()); m != nil {
+					name = m[1]
+				} else if m := mockeryDoc.FindStringSubmatch(doc.Text()); m != nil {
+					name = m[1]
+				} else if n, ok := strings.CutPrefix(spec.Name.Name, "Fake"); ok && tool == "counterfeiter" {
+					name = n
+				}
+				if name == "" {
+					continue
+				}
+				byType[spec.Name.Name] = len(mocks)
+				mocks = append(mocks, Mock{Tool: tool, Name: name, Type: spec.Name.Name, In: pkg.ImportPath, File: file})
+
+			case *ast.ValueSpec:
+				// counterfeiter asserts var _ pkg.Store = new(FakeStore)
+				sel, ok := spec.Type.(*ast.SelectorExpr)
+				if !ok || len(spec.Values) != 1 {
+					continue
+				}
+				call, ok := spec.Values[0].(*ast.CallExpr)
+				if !ok || len(call.Args) != 1 {
+					continue
+				}
+				x, ok := sel.X.(*ast.Ident)
+				if !ok {
+					continue
+				}
+				t, ok := call.Args[0].(*ast.Ident)
+				if !ok {
+					continue
+				}
+				if i, ok := byType[t.Name]; ok {
+					mocks[i].Name = sel.Sel.Name
+					mocks[i].Pkg = imports[x.Name]
+				}
+			}
+		}
+	}
+
+	for i := range mocks {
+		if mocks[i].Pkg == "" {
+			mocks[i].Pkg = MockSource(g, pkg, source, mocks[i].Name)
+		}
+	}
+	return mocks
+}
+
+// MockSource guesses which package declares the interface name, going by the
+// Source line of the mock's header, which mockgen fills with either an import
+// path or the file the interface was read from, and otherwise by which scanned
+// package declares a type of that name.
+func MockSource(g *Graph, in *Package, source, name string) string {
+	if source != "" {
+		path, _, _ := strings.Cut(source, " ")
+		if _, ok := g.Pkgs[path]; ok {
+			return path
+		}
+		for _, p := range g.Order {
+			if slices.ContainsFunc(g.Pkgs[p].Files, func(f string) bool { return strings.HasSuffix(filepath.ToSlash(f), filepath.ToSlash(path)) }) {
+				return p
+			}
+		}
+	}
+
+	var found []string
+	for _, p := range g.Order {
+		if p == in.ImportPath {
+			continue
+		}
+		if PackageSymbols(g.Pkgs[p])[name] == SymbolType {
+			found = append(found, p)
+		}
+	}
+	if len(found) == 1 {
+		return found[0]
+	}
+	// mocks are often generated next to the interface
+	if PackageSymbols(in)[name] == SymbolType {
+		return in.ImportPath
+	}
+	return ""
+}
+
+func RunMocks(args []string) {
+	fs := flag.NewFlagSet("mocks", flag.ExitOnError)
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintln(w, "'wuw mocks' finds mocks generated by gomock, mockery and counterfeiter, and lists which interfaces of which packages are mocked where, revealing which boundaries are treated as seams.")
+		fmt.Fprintf(w, "Usage: %s mocks [-opts] [dirs...]\nopts:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+
+	outVar := OutputFlag(fs)
+
+	ParseFlags(fs, args)
+
+	dirs := ReadArgs(fs.Args(), fs.Usage)
+	pkgs, errs := ScanDirs(dirs, true)
+
+	PrintErrors(errs)
+
+	g := NewGraph(pkgs)
+
+	w := OpenOutput(*outVar)
+	defer w.Close()
+
+	mocks := FindMocks(g)
+	slices.SortStableFunc(mocks, func(a, b Mock) int {
+		return strings.Compare(a.Pkg+"."+a.Name, b.Pkg+"."+b.Name)
+	})
+
+	seams := make(map[string]int)
+	var order []string
+	var last string
+	for _, m := range mocks {
+		iface := m.Name
+		if m.Pkg != "" {
+			iface = m.Pkg + "." + m.Name
+		} else {
+			iface += " (package unknown)"
+		}
+		if iface != last {
+			fmt.Fprintln(w, iface)
+			last = iface
+		}
+		fmt.Fprintf(w, "\tmocked as %s.%s by %s in %s\n", m.In, m.Type, m.Tool, m.File)
+
+		if m.Pkg != "" {
+			if seams[m.Pkg] == 0 {
+				order = append(order, m.Pkg)
+			}
+			seams[m.Pkg]++
+		}
+	}
+
+	if len(order) != 0 {
+		fmt.Fprintf(w, "\n%d packages treated as seams:\n", len(order))
+		for _, p := range order {
+			fmt.Fprintf(w, "\t%s\t%d mocks\n", p, seams[p])
+		}
+	}
+}
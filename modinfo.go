@@ -0,0 +1,141 @@
+package wuw
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ModuleVersions holds what a module records about its dependencies, read
+// from files in the module itself so no network access is needed.
+type ModuleVersions struct {
+	Versions map[string]string
+	// Packages maps each vendored package to its module, which is only known
+	// when there is a vendor/modules.txt.
+	Packages map[string]string
+	Vendored bool
+
+	// classes caches what ModuleOf works out, across runs.
+	classes *ModuleClasses
+}
+
+var moduleVersions = make(map[string]*ModuleVersions)
+
+// LoadModuleVersions reads the dependency versions of the module rooted at
+// root from vendor/modules.txt if it exists, falling back to go.mod.
+func LoadModuleVersions(root string) *ModuleVersions {
+	if mv, ok := moduleVersions[root]; ok {
+		return mv
+	}
+
+	mv := &ModuleVersions{Versions: make(map[string]string), Packages: make(map[string]string), classes: Classes().Module(root)}
+	if !mv.readModulesTxt(filepath.Join(root, "vendor", "modules.txt")) {
+		mv.readGoMod(filepath.Join(root, "go.mod"))
+	}
+	moduleVersions[root] = mv
+	return mv
+}
+
+func (mv *ModuleVersions) readModulesTxt(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	mv.Vendored = true
+
+	var mod string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "## "):
+			// markers such as "## explicit; go 1.21"
+		case strings.HasPrefix(line, "# "):
+			// "# path version" optionally followed by "=> replacement version"
+			fields := strings.Fields(line[2:])
+			mod = ""
+			if len(fields) < 2 || fields[1] == "=>" {
+				continue
+			}
+			mod = fields[0]
+			version := fields[1]
+			if i := strings.Index(line, "=>"); i != -1 {
+				repl := strings.Fields(line[i+2:])
+				if len(repl) == 2 {
+					version = repl[1]
+				} else if len(repl) == 1 {
+					version = version + " => " + repl[0]
+				}
+			}
+			mv.Versions[mod] = version
+		case line != "" && mod != "":
+			mv.Packages[line] = mod
+		}
+	}
+	return true
+}
+
+func (mv *ModuleVersions) readGoMod(path string) {
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	mv.parseGoMod(f)
+}
+
+// parseGoMod adds the requirements of the go.mod read from r to mv.
+func (mv *ModuleVersions) parseGoMod(r io.Reader) {
+	var inRequire bool
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "//")
+		fields := strings.Fields(line)
+
+		switch {
+		case len(fields) == 0:
+		case inRequire && fields[0] == ")":
+			inRequire = false
+		case inRequire && len(fields) >= 2:
+			mv.Versions[fields[0]] = fields[1]
+		case fields[0] == "require" && len(fields) >= 2 && fields[1] == "(":
+			inRequire = true
+		case fields[0] == "require" && len(fields) >= 3:
+			mv.Versions[fields[1]] = fields[2]
+		}
+	}
+}
+
+// ModuleOf returns the module the package at path belongs to, using the
+// vendored package lists or the longest known module path that prefixes it,
+// and guessing from the path otherwise.
+func (mv *ModuleVersions) ModuleOf(path string) string {
+	if m, ok := mv.Packages[path]; ok {
+		return m
+	}
+	if c, ok := mv.classes.lookup(path); ok {
+		return c.Module
+	}
+	m := mv.moduleOf(path)
+	if mv.classes != nil {
+		Classes().SetPath(mv.classes, path, PathClass{Module: m, Version: mv.Versions[m]})
+	}
+	return m
+}
+
+func (mv *ModuleVersions) moduleOf(path string) string {
+
+	var best string
+	for m := range mv.Versions {
+		if MatchesPath(path, m) && len(m) > len(best) {
+			best = m
+		}
+	}
+	if best != "" {
+		return best
+	}
+	return GuessModule(path)
+}
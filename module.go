@@ -0,0 +1,110 @@
+package wuw
+
+import (
+	"bufio"
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+type Module struct {
+	Root string
+	Path string
+}
+
+type moduleKey struct {
+	fsys fs.FS
+	dir  string
+}
+
+var modules = make(map[moduleKey]*Module)
+
+// FindModule walks up from dir looking for a go.mod and returns the module it
+// declares, or nil if dir is not inside a module.
+func FindModule(dir string) *Module {
+	return FindModuleFS(OS, dir)
+}
+
+func FindModuleFS(fsys fs.FS, dir string) *Module {
+	abs := filepath.Clean(dir)
+	if fsys == OS {
+		var err error
+		if abs, err = filepath.Abs(dir); err != nil {
+			return nil
+		}
+	}
+
+	var visited []string
+	for {
+		if m, ok := modules[moduleKey{fsys, abs}]; ok {
+			for _, v := range visited {
+				modules[moduleKey{fsys, v}] = m
+			}
+			return m
+		}
+		visited = append(visited, abs)
+
+		if path, ok := ReadModulePath(fsys, filepath.Join(abs, "go.mod")); ok {
+			m := &Module{Root: abs, Path: path}
+			for _, v := range visited {
+				modules[moduleKey{fsys, v}] = m
+			}
+			return m
+		}
+
+		parent := filepath.Dir(abs)
+		if parent == abs {
+			break
+		}
+		abs = parent
+	}
+
+	for _, v := range visited {
+		modules[moduleKey{fsys, v}] = nil
+	}
+	return nil
+}
+
+func ReadModulePath(fsys fs.FS, gomod string) (string, bool) {
+	f, err := fsys.Open(gomod)
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "module" {
+			return strings.Trim(fields[1], "\""), true
+		}
+	}
+	return "", false
+}
+
+// ImportPath returns the import path of the package in dir, falling back to
+// the cleaned dir itself when it is not part of a module.
+func ImportPath(dir string) string {
+	return ImportPathFS(OS, dir)
+}
+
+func ImportPathFS(fsys fs.FS, dir string) string {
+	m := FindModuleFS(fsys, dir)
+	if m == nil {
+		return filepath.ToSlash(filepath.Clean(dir))
+	}
+
+	abs := filepath.Clean(dir)
+	if fsys == OS {
+		var err error
+		if abs, err = filepath.Abs(dir); err != nil {
+			return filepath.ToSlash(filepath.Clean(dir))
+		}
+	}
+
+	rel, err := filepath.Rel(m.Root, abs)
+	if err != nil || rel == "." {
+		return m.Path
+	}
+	return m.Path + "/" + filepath.ToSlash(rel)
+}
@@ -0,0 +1,115 @@
+package wuw
+
+import (
+	"flag"
+	"fmt"
+	"go/token"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// NameIssue is a package whose name makes it harder to import than it needs
+// to be, with what to rename to fix that.
+type NameIssue struct {
+	Pkg     *Package
+	Problem string
+	Suggest string
+}
+
+var majorVersion = regexp.MustCompile(`^v[0-9]+$`)
+
+// ExpectedName returns the name the go command and most tools guess for the
+// package at importPath, as goimports does: its last element, less any
+// major version, go- prefix or -go suffix, and with what isn't allowed in an
+// identifier dropped.
+func ExpectedName(importPath string) string {
+	base := path.Base(importPath)
+	if majorVersion.MatchString(base) && path.Dir(importPath) != "." {
+		base = path.Base(path.Dir(importPath))
+	}
+	base = strings.TrimPrefix(base, "go-")
+	base = strings.TrimSuffix(strings.TrimSuffix(base, "-go"), ".go")
+
+	var b strings.Builder
+	for _, r := range strings.ToLower(base) {
+		if r == '_' || 'a' <= r && r <= 'z' || '0' <= r && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// NameIssues finds the packages of g declaring a name other than their dir
+// suggests, and, with stutter, those whose name repeats the dir above them.
+func NameIssues(g *Graph, mismatch, stutter bool) []NameIssue {
+	var ret []NameIssue
+	for _, p := range g.Order {
+		pkg := g.Pkgs[p]
+		if pkg.Name == "main" {
+			continue
+		}
+
+		expected := ExpectedName(p)
+		if mismatch && pkg.Name != expected {
+			issue := NameIssue{Pkg: pkg, Problem: fmt.Sprintf("declares package %s in dir %s", pkg.Name, path.Base(p))}
+			if token.IsIdentifier(expected) {
+				issue.Suggest = fmt.Sprintf("rename the package to %s, or the dir to %s", expected, pkg.Name)
+			} else {
+				issue.Suggest = fmt.Sprintf("rename the dir to %s", pkg.Name)
+			}
+			ret = append(ret, issue)
+			continue
+		}
+
+		parent := ExpectedName(path.Dir(p))
+		if !stutter || len(parent) < 2 || path.Dir(p) == "." {
+			continue
+		}
+		switch rest := strings.TrimPrefix(pkg.Name, parent); {
+		case pkg.Name == parent:
+			ret = append(ret, NameIssue{Pkg: pkg, Problem: fmt.Sprintf("stutters, %s repeats the dir above it", pkg.Name), Suggest: fmt.Sprintf("merge it into %s, or name it for what sets it apart", path.Dir(p))})
+		case rest != pkg.Name && token.IsIdentifier(rest):
+			ret = append(ret, NameIssue{Pkg: pkg, Problem: fmt.Sprintf("stutters, %s starts with %s like the dir above it", pkg.Name, parent), Suggest: fmt.Sprintf("rename it to %s", rest)})
+		}
+	}
+	return ret
+}
+
+func RunNames(args []string) {
+	fs := flag.NewFlagSet("names", flag.ExitOnError)
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintln(w, "'wuw names' reports packages whose declared name doesn't match their dir, or stutters with the dir above it, and suggests renames.")
+		fmt.Fprintf(w, "Usage: %s names [-opts] [dirs...]\nopts:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+
+	mismatchVar := fs.Bool("mismatch", true, "Report packages named differently from their dir")
+	stutterVar := fs.Bool("stutter", true, "Report packages whose name repeats the dir above them")
+	outVar := OutputFlag(fs)
+
+	ParseFlags(fs, args)
+
+	dirs := ReadArgs(fs.Args(), fs.Usage)
+	pkgs, errs := ScanDirs(dirs, true)
+
+	PrintErrors(errs)
+
+	g := NewGraph(pkgs)
+
+	w := OpenOutput(*outVar)
+	defer w.Close()
+
+	issues := NameIssues(g, *mismatchVar, *stutterVar)
+	for _, i := range issues {
+		fmt.Fprintf(w, "%s: %s\n", i.Pkg.ImportPath, i.Problem)
+		fmt.Fprintf(w, "\t%s", i.Suggest)
+		if n := len(g.Importers(i.Pkg.ImportPath)); n != 0 {
+			fmt.Fprintf(w, " (%d importers to update)", n)
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "%d packages to rename\n", len(issues))
+}
@@ -0,0 +1,103 @@
+package wuw
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+)
+
+// NDJSONStream writes each package as a line of JSON as soon as the scan
+// finds it, rather than once the whole tree was read, for consumers of very
+// large trees to start on the first packages right away. Lines are packages
+// of the v2 JSON schema, or {"error": ...} for the errors of the scan.
+//
+// Nothing is known yet about the packages still to come, so an import is
+// internal when it is in the same module as its importer, where the other
+// outputs only count those that were scanned.
+type NDJSONStream struct {
+	enc          *json.Encoder
+	excluded     []string
+	testSupport  *TestSupport
+	mode         string
+	experimental []*regexp.Regexp
+	groups       *Groups
+}
+
+// NewNDJSONStream returns a stream writing to w, leaving out the imports
+// qualified by one of excluded and dealing with test helper packages as
+// -test-support mode says.
+func NewNDJSONStream(w io.Writer, excluded []string, mode string, groups *Groups) (*NDJSONStream, error) {
+	s := &NDJSONStream{enc: json.NewEncoder(w), excluded: excluded, mode: mode, groups: groups}
+	switch mode {
+	case "show":
+	case "dim", "exclude":
+		ts, err := LoadTestSupport(DefaultConfig)
+		if err != nil {
+			return nil, err
+		}
+		s.testSupport = ts
+	default:
+		return nil, fmt.Errorf("unknown -test-support %q, want show, dim or exclude", mode)
+	}
+
+	var err error
+	if s.experimental, err = ExperimentalPatterns(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Package writes pkg, unless it is a test helper being excluded.
+func (s *NDJSONStream) Package(pkg *Package) error {
+	p := *pkg
+	p.Deps = slices.Clone(p.Deps)
+	p.Experimental = p.Experimental || IsExperimental(s.experimental, p.ImportPath)
+	p = ExcludeQualified([]Package{p}, s.excluded)[0]
+	if s.testSupport != nil {
+		if s.mode == "exclude" {
+			if s.testSupport.Is(p.ImportPath) {
+				return nil
+			}
+			p.Deps = slices.DeleteFunc(p.Deps, s.testSupport.Is)
+		} else {
+			p.TestSupport = s.testSupport.Is(p.ImportPath)
+		}
+	}
+
+	var module string
+	if m := FindModule(p.Path); m != nil {
+		module = m.Path
+	}
+	imports := []jsonImport{}
+	for _, d := range p.Deps {
+		category := CategoryExternal
+		switch {
+		case module != "" && MatchesPath(d, module):
+			category = CategoryInternal
+		case IsStdlib(d):
+			category = CategoryStd
+		}
+		imports = append(imports, jsonImport{Path: d, Category: category, Qualifiers: p.Qualifiers[d], Aliases: p.Aliases[d]})
+	}
+
+	return s.enc.Encode(jsonPackageV2{
+		Name:         p.Name,
+		Path:         p.Path,
+		ImportPath:   p.ImportPath,
+		Imports:      imports,
+		Generated:    p.Generated,
+		Sources:      p.Sources,
+		TestSupport:  p.TestSupport,
+		Experimental: p.Experimental,
+		Cgo:          p.Cgo,
+		Group:        s.groups.Of(p.ImportPath),
+	})
+}
+
+func (s *NDJSONStream) Error(err error) error {
+	return s.enc.Encode(struct {
+		Error string `json:"error"`
+	}{err.Error()})
+}
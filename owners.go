@@ -0,0 +1,97 @@
+package wuw
+
+import (
+	"fmt"
+	"os"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultOwners is the file 'wuw check' reads the owners of external modules
+// from, if it exists.
+const DefaultOwners = "owners.yaml"
+
+// Owners registers who owns each external module the tree may import, and
+// why it is worth depending on, so that no third-party dep comes in without
+// somebody answering for it.
+type Owners struct {
+	Modules map[string]*ModuleOwner `yaml:"modules"`
+}
+
+type ModuleOwner struct {
+	Team string `yaml:"team"`
+	// Justification links to where the dep was argued for, such as an issue
+	// or design doc.
+	Justification string `yaml:"justification"`
+}
+
+func LoadOwners(path string) (*Owners, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var o Owners
+	if err := yaml.Unmarshal(data, &o); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for m, owner := range o.Modules {
+		if owner == nil || owner.Team == "" {
+			return nil, fmt.Errorf("%s: module %s has no team", path, m)
+		}
+	}
+	return &o, nil
+}
+
+// Of returns the owner registered for the module at path, or for the module
+// it is nested in.
+func (o *Owners) Of(path string) *ModuleOwner {
+	var best string
+	for m := range o.Modules {
+		if MatchesPath(path, m) && len(m) > len(best) {
+			best = m
+		}
+	}
+	return o.Modules[best]
+}
+
+type UnownedImport struct {
+	Edge
+	Module string
+}
+
+func (u UnownedImport) String() string {
+	return fmt.Sprintf("%s -> %s: module %s has no owner", u.From, u.To, u.Module)
+}
+
+// UnownedImports finds the imports of external modules that have no owner in
+// o. Experimental packages are left out.
+func UnownedImports(g *Graph, o *Owners) []UnownedImport {
+	var ret []UnownedImport
+	for _, p := range g.Order {
+		pkg := g.Pkgs[p]
+		if pkg.Experimental {
+			continue
+		}
+
+		mv := &ModuleVersions{}
+		if m := FindModule(pkg.Path); m != nil {
+			mv = LoadModuleVersions(m.Root)
+		}
+
+		var seen []string
+		for _, d := range pkg.Deps {
+			if g.Category(d) != CategoryExternal {
+				continue
+			}
+			m := mv.ModuleOf(d)
+			if o.Of(m) != nil || slices.Contains(seen, m) {
+				continue
+			}
+			seen = append(seen, m)
+			ret = append(ret, UnownedImport{Edge: Edge{From: p, To: d}, Module: m})
+		}
+	}
+	return ret
+}
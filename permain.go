@@ -0,0 +1,91 @@
+package wuw
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// formatExtensions are the file extensions of the output of each format,
+// those missing from it, templates included, being written as .txt.
+var formatExtensions = map[string]string{
+	"json":        ".json",
+	"yaml":        ".yaml",
+	"dot":         ".dot",
+	"mermaid":     ".md",
+	"graphml":     ".graphml",
+	"gexf":        ".gexf",
+	"cytoscape":   ".json",
+	"plantuml":    ".puml",
+	"d2":          ".d2",
+	"structurizr": ".dsl",
+	"csv":         ".csv",
+	"tsv":         ".tsv",
+	"markdown":    ".md",
+	"html":        ".html",
+	"svg":         ".svg",
+	"datalog":     ".dl",
+}
+
+// MainResult is a result scoped to what a main package links in.
+type MainResult struct {
+	Main   string
+	Result *Result
+}
+
+// PerMain splits r into a result for each of its main packages, holding the
+// main package and the internal packages it imports directly or not, with
+// all of their imports. What r says about the whole of it, such as its
+// groups and diagram info, is kept.
+func PerMain(r *Result) []MainResult {
+	var ret []MainResult
+	for _, p := range r.Graph.Order {
+		if r.Graph.Pkgs[p].Name != "main" {
+			continue
+		}
+
+		var pkgs []Package
+		for _, q := range r.Graph.Reachable(p) {
+			pkgs = append(pkgs, *r.Graph.Pkgs[q])
+		}
+		res := NewResult(pkgs, nil)
+		res.ScannedAt = r.ScannedAt
+		res.Groups = r.Groups
+		res.Diagram = r.Diagram
+		res.Status = r.Status
+		ret = append(ret, MainResult{Main: p, Result: res})
+	}
+	return ret
+}
+
+// WritePerMain reports each result of PerMain(r) with reporter to a file of
+// dir, named after the main package's path in its module, and returns the
+// paths written.
+func WritePerMain(dir string, r *Result, reporter Reporter, format string) ([]string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	ext, ok := formatExtensions[format]
+	if !ok {
+		ext = ".txt"
+	}
+
+	var written []string
+	for _, m := range PerMain(r) {
+		name := strings.ReplaceAll(LayoutLabel(r.Graph, m.Main), "/", "-") + ext
+		path := filepath.Join(dir, name)
+		f, err := os.Create(path)
+		if err != nil {
+			return written, err
+		}
+		err = reporter.Report(f, m.Result)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return written, err
+		}
+		written = append(written, path)
+	}
+	return written, nil
+}
@@ -0,0 +1,67 @@
+package wuw
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// PlantUMLQuote quotes s as a PlantUML string. PlantUML has no escape for
+// double quotes inside one, so they become single quotes.
+func PlantUMLQuote(s string) string {
+	r := strings.NewReplacer(`"`, "'", "\n", `\n`, "\r", "")
+	return `"` + r.Replace(strings.ToValidUTF8(s, "�")) + `"`
+}
+
+// WritePlantUML writes the internal packages of g as the components of a
+// PlantUML component diagram, with the imports between them as arrows and
+// groups, if there are any, as packages around them. Generated and test
+// support packages get a stereotype, and experimental ones are greyed out.
+// The metadata of info goes in comments.
+func WritePlantUML(w io.Writer, g *Graph, groups *Groups, info *DiagramInfo) {
+	fmt.Fprintln(w, "@startuml")
+	if info != nil {
+		for _, l := range info.Lines() {
+			fmt.Fprintf(w, "' %s\n", l)
+		}
+	}
+
+	component := func(indent, p string) {
+		pkg := g.Pkgs[p]
+		fmt.Fprintf(w, "%scomponent %s as %s", indent, PlantUMLQuote(p), MermaidID(p))
+		if len(pkg.Generated) != 0 && len(pkg.Generated) == len(pkg.Files) {
+			fmt.Fprint(w, " <<generated>>")
+		}
+		if pkg.TestSupport {
+			fmt.Fprint(w, " <<test support>>")
+		}
+		if pkg.Experimental {
+			fmt.Fprint(w, " #lightgray")
+		}
+		fmt.Fprintln(w)
+	}
+
+	if groups != nil {
+		for _, name := range groups.Names() {
+			fmt.Fprintf(w, "package %s {\n", PlantUMLQuote(name))
+			for _, p := range g.Order {
+				if groups.Of(p) == name {
+					component("  ", p)
+				}
+			}
+			fmt.Fprintln(w, "}")
+		}
+	}
+	for _, p := range g.Order {
+		if groups.Of(p) == "" {
+			component("", p)
+		}
+	}
+
+	for _, p := range g.Order {
+		for _, d := range g.InternalDeps(p) {
+			fmt.Fprintf(w, "%s --> %s\n", MermaidID(p), MermaidID(d))
+		}
+	}
+	fmt.Fprintln(w, "@enduml")
+}
@@ -0,0 +1,170 @@
+package wuw
+
+import (
+	"flag"
+	"fmt"
+	"go/build"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Platform is a GOOS and GOARCH to scan the files of.
+type Platform struct {
+	GOOS, GOARCH string
+}
+
+func (p Platform) String() string {
+	return p.GOOS + "/" + p.GOARCH
+}
+
+// DefaultPlatforms are what -all-platforms scans for unless -platforms says
+// otherwise.
+const DefaultPlatforms = "linux/amd64,darwin/arm64,windows/amd64"
+
+// PlatformsFlag registers the -all-platforms and -platforms flags on fs.
+func PlatformsFlag(fs *flag.FlagSet) (all *bool, platforms *string) {
+	all = fs.Bool("all-platforms", false, "Scan the files of each of -platforms in turn and merge the packages found, listing the platforms of the imports that only some of them have")
+	platforms = fs.String("platforms", DefaultPlatforms, "Comma-separated GOOS/GOARCH `pairs` for -all-platforms")
+	return all, platforms
+}
+
+// ParsePlatforms parses a comma-separated list of GOOS/GOARCH pairs.
+func ParsePlatforms(s string) ([]Platform, error) {
+	var ret []Platform
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		goos, goarch, _ := strings.Cut(f, "/")
+		if !slices.Contains(knownOS, goos) || !slices.Contains(knownArch, goarch) {
+			return nil, fmt.Errorf("unknown platform %q, want GOOS/GOARCH such as linux/amd64", f)
+		}
+		if p := (Platform{goos, goarch}); !slices.Contains(ret, p) {
+			ret = append(ret, p)
+		}
+	}
+	if len(ret) == 0 {
+		return nil, fmt.Errorf("no platforms in %q", s)
+	}
+	return ret, nil
+}
+
+// ScanPlatforms scans dirs with s once for each of platforms, reading only
+// the files built for it with the tags of s.Build, if any, and merges the
+// packages found. A package has the files and imports it has on any of the
+// platforms, and the qualifiers of an import are those it has on all of
+// them. Imports that aren't there on every platform have the ones they are
+// in Platforms. Dirs are only reported skipped if no platform has a package
+// in them.
+func ScanPlatforms(s *Scanner, dirs []string, platforms []Platform) ([]Package, []error) {
+	merged := make(map[string]*Package)
+	on := make(map[string]map[string][]string)
+	var paths []string
+	var errs []error
+	var skipped []SkippedDir
+	for _, p := range platforms {
+		ctx := build.Default
+		if s.Build != nil {
+			ctx = *s.Build
+		}
+		ctx.GOOS, ctx.GOARCH = p.GOOS, p.GOARCH
+		ps := *s
+		ps.Build = &ctx
+		if s.Coverage != nil {
+			ps.Coverage = &Coverage{}
+		}
+
+		pkgs, perrs := ps.Scan(dirs)
+		for _, err := range perrs {
+			if !slices.ContainsFunc(errs, func(e error) bool { return e.Error() == err.Error() }) {
+				errs = append(errs, err)
+			}
+		}
+		if ps.Coverage != nil {
+			skipped = append(skipped, ps.Coverage.Skipped...)
+		}
+
+		for _, pkg := range pkgs {
+			if on[pkg.Path] == nil {
+				on[pkg.Path] = make(map[string][]string)
+			}
+			for _, d := range pkg.Deps {
+				on[pkg.Path][d] = append(on[pkg.Path][d], p.String())
+			}
+
+			m, ok := merged[pkg.Path]
+			if !ok {
+				pkg.Qualifiers = maps.Clone(pkg.Qualifiers)
+				pkg.Aliases = maps.Clone(pkg.Aliases)
+				merged[pkg.Path] = &pkg
+				paths = append(paths, pkg.Path)
+				continue
+			}
+			for _, d := range pkg.Deps {
+				if !slices.Contains(m.Deps, d) {
+					m.Deps = append(m.Deps, d)
+					if q := pkg.Qualifiers[d]; len(q) != 0 {
+						if m.Qualifiers == nil {
+							m.Qualifiers = make(map[string][]string)
+						}
+						m.Qualifiers[d] = q
+					}
+				} else if q := MergeQualifiers(m.Qualifiers[d], pkg.Qualifiers[d]); len(q) != 0 {
+					m.Qualifiers[d] = q
+				} else {
+					delete(m.Qualifiers, d)
+				}
+			}
+			for d, names := range pkg.Aliases {
+				if m.Aliases == nil {
+					m.Aliases = make(map[string][]string)
+				}
+				m.Aliases[d] = union(m.Aliases[d], names)
+			}
+			m.Files = union(m.Files, pkg.Files)
+			m.Generated = union(m.Generated, pkg.Generated)
+			m.Sources = union(m.Sources, pkg.Sources)
+			m.Experimental = m.Experimental || pkg.Experimental
+			m.Cgo = m.Cgo || pkg.Cgo
+		}
+	}
+
+	// keep the order of dirs, which a package missing from the first
+	// platforms would otherwise come after
+	slices.SortStableFunc(paths, func(a, b string) int {
+		return slices.Index(dirs, a) - slices.Index(dirs, b)
+	})
+	var ret []Package
+	for _, path := range paths {
+		pkg := merged[path]
+		for _, d := range pkg.Deps {
+			if ps := on[path][d]; len(ps) != len(platforms) {
+				if pkg.Platforms == nil {
+					pkg.Platforms = make(map[string][]string)
+				}
+				pkg.Platforms[d] = ps
+			}
+		}
+		ret = append(ret, *pkg)
+	}
+
+	for _, sk := range skipped {
+		_, scanned := merged[sk.Dir]
+		if !scanned && !slices.ContainsFunc(s.Coverage.Skipped, func(o SkippedDir) bool { return o.Dir == sk.Dir }) {
+			s.Coverage.Skip(sk.Dir, sk.Reason, sk.Detail)
+		}
+	}
+	return ret, errs
+}
+
+// union returns a with the elements of b it lacks appended.
+func union(a, b []string) []string {
+	for _, s := range b {
+		if !slices.Contains(a, s) {
+			a = append(a, s)
+		}
+	}
+	return a
+}
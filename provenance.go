@@ -0,0 +1,109 @@
+package wuw
+
+import (
+	"bufio"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// generators maps the tool named in a "Code generated by" header to the
+// extensions of the files it generates from, and the config files it reads.
+var generators = []struct {
+	Tool    string
+	Exts    []string
+	Configs []string
+}{
+	{"protoc-gen-go", []string{".proto"}, []string{"buf.gen.yaml", "buf.yaml"}},
+	{"sqlc", []string{".sql"}, []string{"sqlc.yaml", "sqlc.yml", "sqlc.json"}},
+	{"gqlgen", []string{".graphql", ".graphqls"}, []string{"gqlgen.yml", "gqlgen.yaml"}},
+}
+
+// sourceExts are the extensions of non-Go files that commonly generate Go.
+var sourceExts = []string{".proto", ".sql", ".graphql", ".graphqls"}
+
+// Provenance finds the generated files among goFiles, and the non-Go files in
+// dir they were most likely generated from.
+func Provenance(fsys fs.FS, dir string, entries []fs.DirEntry, goFiles []string) (generated, sources []string) {
+	var tools, named []string
+	for _, g := range goFiles {
+		tool, source, ok := ReadGeneratedHeader(fsys, g)
+		if !ok {
+			continue
+		}
+		generated = append(generated, g)
+		if tool != "" && !slices.Contains(tools, tool) {
+			tools = append(tools, tool)
+		}
+		if source != "" {
+			named = append(named, source)
+		}
+	}
+	if len(generated) == 0 {
+		return nil, nil
+	}
+
+	exts := sourceExts
+	var configs []string
+	for _, gen := range generators {
+		for _, t := range tools {
+			if strings.Contains(t, gen.Tool) {
+				exts = gen.Exts
+				configs = append(configs, gen.Configs...)
+			}
+		}
+	}
+
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		if slices.Contains(exts, filepath.Ext(e.Name())) || slices.Contains(configs, e.Name()) {
+			sources = append(sources, filepath.Join(dir, e.Name()))
+		}
+	}
+
+	// headers name sources relative to the generator's include path, so
+	// prefer the sibling file when there is one
+	for _, n := range named {
+		if !slices.ContainsFunc(sources, func(s string) bool { return filepath.Base(s) == filepath.Base(n) }) {
+			sources = append(sources, n)
+		}
+	}
+
+	slices.Sort(sources)
+	return generated, slices.Compact(sources)
+}
+
+// ReadGeneratedHeader reports whether the file at name carries the standard
+// "Code generated ... DO NOT EDIT." header, with the generator and the
+// "source:" file it names, if any.
+func ReadGeneratedHeader(fsys fs.FS, name string) (tool, source string, ok bool) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return "", "", false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "package ") {
+			break
+		}
+		if !strings.HasPrefix(line, "//") {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "//"))
+
+		if strings.HasPrefix(line, "Code generated") && strings.HasSuffix(line, "DO NOT EDIT.") {
+			ok = true
+			tool = strings.TrimSuffix(strings.TrimPrefix(line, "Code generated"), "DO NOT EDIT.")
+			tool = strings.Trim(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(tool), "by")), ",. ")
+		} else if s, found := strings.CutPrefix(line, "source:"); found {
+			source = strings.TrimSpace(s)
+		}
+	}
+	return tool, source, ok
+}
@@ -0,0 +1,130 @@
+package wuw
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"slices"
+	"strings"
+)
+
+// Qualifiers mark the edges that only exist in some kinds of file, so they can
+// be told apart from, or left out of, the imports a package always builds
+// with.
+const (
+	QualifierTest      = "test-only"
+	QualifierXTest     = "external-test-only"
+	QualifierGenerated = "generated-only"
+	QualifierBuildTag  = "build-tag-only"
+	QualifierDI        = "di-only"
+)
+
+var qualifiers = []string{QualifierTest, QualifierXTest, QualifierGenerated, QualifierBuildTag, QualifierDI}
+
+// EdgeQualifiers returns the qualifiers of each import in fileImports, which
+// maps the Go files of a package to what they import, and xtests are the
+// files of its external test package. Imports that some plain file has are
+// left out.
+func EdgeQualifiers(fsys fs.FS, fileImports map[string][]string, generated, xtests []string) map[string][]string {
+	files := make(map[string][]string)
+	for f, imports := range fileImports {
+		for _, i := range imports {
+			files[i] = append(files[i], f)
+		}
+	}
+
+	constrained := make(map[string]bool)
+	wiring := make(map[string]bool)
+	ret := make(map[string][]string)
+	for i, in := range files {
+		var q []string
+		if !slices.ContainsFunc(in, func(f string) bool { return !strings.HasSuffix(f, "_test.go") }) {
+			q = append(q, QualifierTest)
+		}
+		if !slices.ContainsFunc(in, func(f string) bool { return !slices.Contains(xtests, f) }) {
+			q = append(q, QualifierXTest)
+		}
+		if !slices.ContainsFunc(in, func(f string) bool { return !slices.Contains(generated, f) }) {
+			q = append(q, QualifierGenerated)
+		}
+		if !slices.ContainsFunc(in, func(f string) bool {
+			c, ok := constrained[f]
+			if !ok {
+				c = Constrained(fsys, f)
+				constrained[f] = c
+			}
+			return !c
+		}) {
+			q = append(q, QualifierBuildTag)
+		}
+		if !slices.ContainsFunc(in, func(f string) bool {
+			w, ok := wiring[f]
+			if !ok {
+				w = DIWiring(fsys, f, fileImports[f])
+				wiring[f] = w
+			}
+			return !w
+		}) {
+			q = append(q, QualifierDI)
+		}
+		if len(q) != 0 {
+			ret[i] = q
+		}
+	}
+	if len(ret) == 0 {
+		return nil
+	}
+	return ret
+}
+
+// MergeQualifiers returns the qualifiers an edge keeps when it comes from
+// files with qualifiers a as well as ones with qualifiers b.
+func MergeQualifiers(a, b []string) []string {
+	var ret []string
+	for _, q := range a {
+		if slices.Contains(b, q) {
+			ret = append(ret, q)
+		}
+	}
+	return ret
+}
+
+// QualifierFlag registers the -exclude-qualified flag on fs.
+func QualifierFlag(fs *flag.FlagSet) *string {
+	return fs.String("exclude-qualified", "", "Drop edges that only exist in these comma-separated kinds of file: test, external-test, generated, build-tag or di")
+}
+
+// ParseQualifiers parses a comma-separated list of qualifiers, accepting them
+// with or without their -only suffix.
+func ParseQualifiers(s string) ([]string, error) {
+	var ret []string
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		if !strings.HasSuffix(f, "-only") {
+			f += "-only"
+		}
+		if !slices.Contains(qualifiers, f) {
+			return nil, fmt.Errorf("unknown qualifier %q, want one of test, external-test, generated, build-tag or di", strings.TrimSuffix(f, "-only"))
+		}
+		ret = append(ret, f)
+	}
+	return ret, nil
+}
+
+// ExcludeQualified drops the deps of pkgs that carry any of the excluded
+// qualifiers.
+func ExcludeQualified(pkgs []Package, excluded []string) []Package {
+	if len(excluded) == 0 {
+		return pkgs
+	}
+	for i := range pkgs {
+		p := &pkgs[i]
+		p.Deps = slices.DeleteFunc(p.Deps, func(d string) bool {
+			return slices.ContainsFunc(p.Qualifiers[d], func(q string) bool { return slices.Contains(excluded, q) })
+		})
+	}
+	return pkgs
+}
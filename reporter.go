@@ -0,0 +1,649 @@
+package wuw
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Result is everything a scan produced, for a Reporter to render.
+type Result struct {
+	Pkgs      []Package
+	Graph     *Graph
+	Errs      []error
+	ScannedAt time.Time
+	// Groups, if set, is what reporters organize the packages by.
+	Groups *Groups
+	// Diagram, if set, is what reporters drawing diagrams say about them.
+	Diagram *DiagramInfo
+	// Status, if set, is how the packages fare against the rules, for
+	// diagrams to color them by.
+	Status *RuleStatus
+	// Coverage, if set, is what the scan left out, for reporters to end
+	// with.
+	Coverage *Coverage
+	// Metrics, if set, are the metrics of each package, built-in and
+	// derived, for reporters to show.
+	Metrics MetricValues
+}
+
+func NewResult(pkgs []Package, errs []error) *Result {
+	return &Result{Pkgs: pkgs, Graph: NewGraph(pkgs), Errs: errs}
+}
+
+// Reporter renders a Result to w. Diagnostics such as scan errors are not part
+// of the report and go to stderr instead.
+type Reporter interface {
+	Report(w io.Writer, r *Result) error
+}
+
+// ReporterOptions are the settings of the reporters that have any.
+type ReporterOptions struct {
+	Positions bool
+	Category  bool
+	Schema    string
+	DSM       bool
+	Verbose   bool
+}
+
+func NewReporter(format string, opts ReporterOptions) (Reporter, error) {
+	switch format {
+	case "text":
+		return TextReporter{Positions: opts.Positions, Verbose: opts.Verbose}, nil
+	case "datalog":
+		return DatalogReporter{}, nil
+	case "json", "yaml":
+		deprecated, err := CheckJSONSchema(opts.Schema)
+		if err != nil {
+			return nil, err
+		}
+		if deprecated != "" {
+			fmt.Fprintf(os.Stderr, "warning: %s\n", deprecated)
+		}
+		if opts.Positions && opts.Schema == JSONSchemaV1 {
+			return nil, fmt.Errorf("-positions needs JSON schema %s", JSONSchemaV2)
+		}
+		if format == "yaml" {
+			return YAMLReporter{Schema: opts.Schema, Positions: opts.Positions}, nil
+		}
+		return JSONReporter{Schema: opts.Schema, Positions: opts.Positions}, nil
+	case "dot":
+		return DotReporter{}, nil
+	case "mermaid":
+		return MermaidReporter{}, nil
+	case "graphml":
+		return GraphMLReporter{}, nil
+	case "cytoscape":
+		return CytoscapeReporter{}, nil
+	case "gexf":
+		return GEXFReporter{}, nil
+	case "markdown":
+		return MarkdownReporter{}, nil
+	case "plantuml":
+		return PlantUMLReporter{}, nil
+	case "d2":
+		return D2Reporter{}, nil
+	case "structurizr":
+		return StructurizrReporter{}, nil
+	case "csv":
+		return CSVReporter{Category: opts.Category}, nil
+	case "tsv":
+		return TSVReporter{}, nil
+	case "html":
+		return HTMLReporter{}, nil
+	case "svg":
+		return SVGReporter{}, nil
+	case "tree":
+		return TreeReporter{}, nil
+	case "matrix":
+		return MatrixReporter{DSM: opts.DSM}, nil
+	}
+	if strings.Contains(format, "{{") {
+		tmpl, err := ParseFormatTemplate(format)
+		if err != nil {
+			return nil, err
+		}
+		return TemplateReporter{Tmpl: tmpl}, nil
+	}
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+// TextReporter lists each package with its deps. With Positions, it instead
+// lists every import as file:line:col, the form editors and terminals make
+// clickable.
+type TextReporter struct {
+	Positions bool
+	// Verbose adds the local names imports are given.
+	Verbose bool
+}
+
+func (t TextReporter) Report(w io.Writer, r *Result) error {
+	if t.Positions {
+		for i := range r.Pkgs {
+			for _, s := range ImportSites(&r.Pkgs[i]) {
+				if slices.Contains(r.Pkgs[i].Deps, s.Path) {
+					fmt.Fprintf(w, "%s:%d:%d: %s\n", s.File, s.Line, s.Col, s.Path)
+				}
+			}
+		}
+		return nil
+	}
+
+	pkgs := r.Pkgs
+	if r.Groups != nil {
+		pkgs = nil
+		for _, name := range append(r.Groups.Names(), "") {
+			for _, p := range r.Pkgs {
+				if r.Groups.Of(p.ImportPath) == name {
+					pkgs = append(pkgs, p)
+				}
+			}
+		}
+	}
+
+	var group string
+	for i, p := range pkgs {
+		if g := r.Groups.Of(p.ImportPath); r.Groups != nil && (i == 0 || g != group) {
+			group = g
+			if g == "" {
+				g = "ungrouped"
+			}
+			fmt.Fprintf(w, "[%s]\n", g)
+		}
+		var marks []string
+		if p.TestSupport {
+			marks = append(marks, "test support")
+		}
+		if p.Experimental {
+			marks = append(marks, "experimental")
+		}
+		if p.Cgo {
+			marks = append(marks, "cgo")
+		}
+		if len(marks) != 0 {
+			fmt.Fprintf(w, "%s:\n%s (%s)\n", p.Path, p.Name, strings.Join(marks, ", "))
+		} else {
+			fmt.Fprintf(w, "%s:\n%s\n", p.Path, p.Name)
+		}
+		if len(p.Sources) != 0 {
+			fmt.Fprintf(w, "(generated from %s)\n", strings.Join(p.Sources, ", "))
+		} else if len(p.Generated) != 0 {
+			fmt.Fprintf(w, "(%d generated files)\n", len(p.Generated))
+		}
+		// the deps only tests have come after what the package itself
+		// depends on, those of its external tests last
+		var testDeps, xtestDeps []string
+		dep := func(d string) {
+			q := slices.DeleteFunc(slices.Clone(p.Qualifiers[d]), func(q string) bool { return q == QualifierTest || q == QualifierXTest })
+			q = append(q, p.Platforms[d]...)
+			if t.Verbose {
+				for _, a := range p.Aliases[d] {
+					q = append(q, "as "+a)
+				}
+			}
+			if len(q) != 0 {
+				fmt.Fprintf(w, "\t%s (%s)\n", d, strings.Join(q, ", "))
+			} else {
+				fmt.Fprintf(w, "\t%s\n", d)
+			}
+		}
+		for _, d := range p.Deps {
+			if slices.Contains(p.Qualifiers[d], QualifierXTest) {
+				xtestDeps = append(xtestDeps, d)
+			} else if slices.Contains(p.Qualifiers[d], QualifierTest) {
+				testDeps = append(testDeps, d)
+			} else {
+				dep(d)
+			}
+		}
+		if len(testDeps) != 0 {
+			fmt.Fprintln(w, "test deps:")
+			for _, d := range testDeps {
+				dep(d)
+			}
+		}
+		if len(xtestDeps) != 0 {
+			fmt.Fprintln(w, "external test deps:")
+			for _, d := range xtestDeps {
+				dep(d)
+			}
+		}
+	}
+	if r.Coverage != nil {
+		WriteCoverage(w, r.Coverage)
+	}
+	return nil
+}
+
+type DatalogReporter struct{}
+
+func (DatalogReporter) Report(w io.Writer, r *Result) error {
+	WriteDatalog(w, r.Graph, r.Groups)
+	return nil
+}
+
+type DotReporter struct{}
+
+func (DotReporter) Report(w io.Writer, r *Result) error {
+	WriteDot(w, r.Graph, r.Groups, r.Status, r.Diagram)
+	return nil
+}
+
+type MermaidReporter struct{}
+
+func (MermaidReporter) Report(w io.Writer, r *Result) error {
+	WriteMermaidGraph(w, r.Graph, r.Groups, r.Status, r.Diagram)
+	return nil
+}
+
+type MatrixReporter struct {
+	DSM bool
+}
+
+func (m MatrixReporter) Report(w io.Writer, r *Result) error {
+	WriteMatrix(w, r.Graph, m.DSM)
+	return nil
+}
+
+type TreeReporter struct{}
+
+func (TreeReporter) Report(w io.Writer, r *Result) error {
+	WriteTree(w, r.Pkgs)
+	return nil
+}
+
+type SVGReporter struct{}
+
+func (SVGReporter) Report(w io.Writer, r *Result) error {
+	WriteSVG(w, r.Graph, r.Groups, r.Diagram)
+	return nil
+}
+
+type HTMLReporter struct{}
+
+func (HTMLReporter) Report(w io.Writer, r *Result) error {
+	return WriteHTML(w, r.Graph, r.Groups, r.Status, r.Diagram, r.Metrics)
+}
+
+// CSVReporter writes an importer,imported edge list with a header, and with
+// Category, the category of the imported package as a third column.
+type CSVReporter struct {
+	Category bool
+}
+
+func (c CSVReporter) Report(w io.Writer, r *Result) error {
+	cw := csv.NewWriter(w)
+	header := []string{"importer", "imported"}
+	if c.Category {
+		header = append(header, "category")
+	}
+	cw.Write(header)
+	for _, p := range r.Graph.Order {
+		for _, d := range r.Graph.Pkgs[p].Deps {
+			row := []string{p, d}
+			if c.Category {
+				row = append(row, r.Graph.Category(d))
+			}
+			cw.Write(row)
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// TSVReporter writes a row per package with how many std, internal and
+// external deps it has, what imports it and all of its deps joined in the
+// last column, for sorting and filtering in a spreadsheet.
+type TSVReporter struct{}
+
+func (TSVReporter) Report(w io.Writer, r *Result) error {
+	cw := csv.NewWriter(w)
+	cw.Comma = '\t'
+	cw.Write([]string{"package", "dir", "group", "files", "std", "internal", "external", "imported by", "deps"})
+	for _, p := range r.Graph.Order {
+		pkg := r.Graph.Pkgs[p]
+		deps := markdownDeps(r.Graph, p)
+		cw.Write([]string{
+			p,
+			RelPath(pkg.Path),
+			r.Groups.Of(p),
+			strconv.Itoa(len(pkg.Files)),
+			strconv.Itoa(len(deps[CategoryStd])),
+			strconv.Itoa(len(deps[CategoryInternal])),
+			strconv.Itoa(len(deps[CategoryExternal])),
+			strconv.Itoa(len(r.Graph.Importers(p))),
+			strings.Join(slices.DeleteFunc(slices.Clone(pkg.Deps), func(d string) bool { return d == p }), ", "),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+type PlantUMLReporter struct{}
+
+func (PlantUMLReporter) Report(w io.Writer, r *Result) error {
+	WritePlantUML(w, r.Graph, r.Groups, r.Diagram)
+	return nil
+}
+
+type GraphMLReporter struct{}
+
+func (GraphMLReporter) Report(w io.Writer, r *Result) error {
+	return WriteGraphML(w, r.Graph, r.Groups)
+}
+
+type D2Reporter struct{}
+
+func (D2Reporter) Report(w io.Writer, r *Result) error {
+	WriteD2(w, r.Graph, r.Groups, r.Status, r.Diagram)
+	return nil
+}
+
+type StructurizrReporter struct{}
+
+func (StructurizrReporter) Report(w io.Writer, r *Result) error {
+	WriteStructurizr(w, r.Graph, r.Groups, r.Diagram)
+	return nil
+}
+
+type MarkdownReporter struct{}
+
+func (MarkdownReporter) Report(w io.Writer, r *Result) error {
+	WriteMarkdownReport(w, r.Graph, r.Groups)
+	return nil
+}
+
+type GEXFReporter struct{}
+
+func (GEXFReporter) Report(w io.Writer, r *Result) error {
+	return WriteGEXF(w, r.Graph, r.Groups)
+}
+
+type CytoscapeReporter struct{}
+
+func (CytoscapeReporter) Report(w io.Writer, r *Result) error {
+	return WriteCytoscape(w, r.Graph, r.Groups)
+}
+
+// The versions of the JSON output. Scripts pin one with -schema so that they
+// keep working as the output changes, and old ones are kept for a while after
+// a new one replaces them. A version is frozen once a newer one replaces it,
+// and until then only gains optional keys and enum values, listed in the
+// $comment of its JSON Schema.
+const (
+	// JSONSchemaV1 lists the deps of each package as plain import paths,
+	// with their qualifiers alongside.
+	JSONSchemaV1 = "v1"
+	// JSONSchemaV2 lists each import as an object with its path, category
+	// and qualifiers, and says which schema it is in.
+	JSONSchemaV2 = "v2"
+
+	CurrentJSONSchema = JSONSchemaV2
+)
+
+// JSONSchemas are the versions of the JSON output that can be asked for, and
+// when they are deprecated, what to do instead.
+var JSONSchemas = map[string]string{
+	JSONSchemaV1: "JSON schema v1 is deprecated and will be removed, move to v2, which lists imports as objects with their path, category and qualifiers",
+	JSONSchemaV2: "",
+}
+
+// CheckJSONSchema returns an error if the JSON output can't be written in
+// schema, and if it is deprecated, the warning to give.
+func CheckJSONSchema(schema string) (string, error) {
+	if schema == "" {
+		return "", nil
+	}
+	deprecated, ok := JSONSchemas[schema]
+	if !ok {
+		return "", fmt.Errorf("unknown JSON schema %q, want one of %s", schema, strings.Join(slices.Sorted(maps.Keys(JSONSchemas)), ", "))
+	}
+	return deprecated, nil
+}
+
+// JSONReporter writes the packages and scan errors as a single JSON object,
+// for scripts to read, in Schema or by default the current one. With
+// Positions, each import also lists the statements importing it, for tools
+// rewriting imports.
+type JSONReporter struct {
+	Schema    string
+	Positions bool
+}
+
+type jsonReport struct {
+	ScannedAt time.Time     `json:"scanned_at" yaml:"scanned_at"`
+	Packages  []jsonPackage `json:"packages" yaml:"packages"`
+	Errors    []string      `json:"errors" yaml:"errors"`
+}
+
+type jsonPackage struct {
+	Name         string              `json:"name" yaml:"name"`
+	Path         string              `json:"path" yaml:"path"`
+	ImportPath   string              `json:"import_path" yaml:"import_path"`
+	Deps         []string            `json:"deps" yaml:"deps"`
+	Qualifiers   map[string][]string `json:"qualifiers,omitempty" yaml:"qualifiers,omitempty"`
+	Generated    []string            `json:"generated,omitempty" yaml:"generated,omitempty"`
+	Sources      []string            `json:"sources,omitempty" yaml:"sources,omitempty"`
+	TestSupport  bool                `json:"test_support,omitempty" yaml:"test_support,omitempty"`
+	Experimental bool                `json:"experimental,omitempty" yaml:"experimental,omitempty"`
+	Group        string              `json:"group,omitempty" yaml:"group,omitempty"`
+}
+
+type jsonReportV2 struct {
+	Schema    string          `json:"schema" yaml:"schema"`
+	ScannedAt time.Time       `json:"scanned_at" yaml:"scanned_at"`
+	Packages  []jsonPackageV2 `json:"packages" yaml:"packages"`
+	Errors    []string        `json:"errors" yaml:"errors"`
+	Coverage  *[]SkippedDir   `json:"coverage,omitempty" yaml:"coverage,omitempty"`
+}
+
+type jsonPackageV2 struct {
+	Name         string       `json:"name" yaml:"name"`
+	Path         string       `json:"path" yaml:"path"`
+	ImportPath   string       `json:"import_path" yaml:"import_path"`
+	Imports      []jsonImport `json:"imports" yaml:"imports"`
+	Generated    []string     `json:"generated,omitempty" yaml:"generated,omitempty"`
+	Sources      []string     `json:"sources,omitempty" yaml:"sources,omitempty"`
+	TestSupport  bool         `json:"test_support,omitempty" yaml:"test_support,omitempty"`
+	Experimental bool         `json:"experimental,omitempty" yaml:"experimental,omitempty"`
+	Cgo          bool         `json:"cgo,omitempty" yaml:"cgo,omitempty"`
+	Group        string       `json:"group,omitempty" yaml:"group,omitempty"`
+}
+
+type jsonImport struct {
+	Path       string     `json:"path" yaml:"path"`
+	Category   string     `json:"category" yaml:"category"`
+	Qualifiers []string   `json:"qualifiers,omitempty" yaml:"qualifiers,omitempty"`
+	Platforms  []string   `json:"platforms,omitempty" yaml:"platforms,omitempty"`
+	Aliases    []string   `json:"aliases,omitempty" yaml:"aliases,omitempty"`
+	Sites      []jsonSite `json:"sites,omitempty" yaml:"sites,omitempty"`
+}
+
+type jsonSite struct {
+	File  string `json:"file" yaml:"file"`
+	Line  int    `json:"line" yaml:"line"`
+	Col   int    `json:"col" yaml:"col"`
+	Alias string `json:"alias,omitempty" yaml:"alias,omitempty"`
+	Blank bool   `json:"blank,omitempty" yaml:"blank,omitempty"`
+	Dot   bool   `json:"dot,omitempty" yaml:"dot,omitempty"`
+}
+
+func (j JSONReporter) Report(w io.Writer, r *Result) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if j.Schema == JSONSchemaV1 {
+		return enc.Encode(newJSONReportV1(r))
+	}
+	return enc.Encode(newJSONReportV2(r, j.Positions))
+}
+
+// newJSONReportV2 returns the report of JSON and YAML output. With positions,
+// the import statements of each import are read from the files of its
+// importer.
+func newJSONReportV2(r *Result, positions bool) jsonReportV2 {
+	report := jsonReportV2{Schema: JSONSchemaV2, ScannedAt: r.ScannedAt, Packages: []jsonPackageV2{}, Errors: []string{}}
+	for _, p := range r.Pkgs {
+		var sites []ImportSite
+		if positions {
+			sites = ImportSites(&p)
+		}
+		imports := []jsonImport{}
+		for _, d := range p.Deps {
+			imp := jsonImport{Path: d, Category: r.Graph.Category(d), Qualifiers: p.Qualifiers[d], Platforms: p.Platforms[d], Aliases: p.Aliases[d]}
+			for _, s := range sites {
+				if s.Path == d {
+					imp.Sites = append(imp.Sites, jsonSite{File: s.File, Line: s.Line, Col: s.Col, Alias: s.Alias, Blank: s.Alias == "_", Dot: s.Alias == "."})
+				}
+			}
+			imports = append(imports, imp)
+		}
+		report.Packages = append(report.Packages, jsonPackageV2{
+			Name:         p.Name,
+			Path:         p.Path,
+			ImportPath:   p.ImportPath,
+			Imports:      imports,
+			Generated:    p.Generated,
+			Sources:      p.Sources,
+			TestSupport:  p.TestSupport,
+			Experimental: p.Experimental,
+			Cgo:          p.Cgo,
+			Group:        r.Groups.Of(p.ImportPath),
+		})
+	}
+	for _, err := range r.Errs {
+		report.Errors = append(report.Errors, err.Error())
+	}
+	if r.Coverage != nil {
+		skipped := append([]SkippedDir{}, r.Coverage.Skipped...)
+		report.Coverage = &skipped
+	}
+	return report
+}
+
+// newJSONReportV1 returns the report of JSON and YAML output in schema v1,
+// which is frozen: what was added to the output since is left out, cgo
+// packages list "C" as a dep again, and only the qualifiers v1 knows are
+// given, external tests counting as tests.
+func newJSONReportV1(r *Result) jsonReport {
+	report := jsonReport{ScannedAt: r.ScannedAt, Packages: []jsonPackage{}, Errors: []string{}}
+	for _, p := range r.Pkgs {
+		deps := append([]string{}, p.Deps...)
+		if p.Cgo {
+			// cgo files import "C" first, right below their preamble
+			deps = append([]string{"C"}, deps...)
+		}
+		report.Packages = append(report.Packages, jsonPackage{
+			Name:         p.Name,
+			Path:         p.Path,
+			ImportPath:   p.ImportPath,
+			Deps:         deps,
+			Qualifiers:   qualifiersV1(p.Qualifiers),
+			Generated:    p.Generated,
+			Sources:      p.Sources,
+			TestSupport:  p.TestSupport,
+			Experimental: p.Experimental,
+			Group:        r.Groups.Of(p.ImportPath),
+		})
+	}
+	for _, err := range r.Errs {
+		report.Errors = append(report.Errors, err.Error())
+	}
+	return report
+}
+
+// qualifiersV1 returns quals with only the qualifiers of schema v1.
+func qualifiersV1(quals map[string][]string) map[string][]string {
+	var ret map[string][]string
+	for d, qs := range quals {
+		var q []string
+		for _, qual := range qs {
+			switch qual {
+			case QualifierXTest:
+				qual = QualifierTest
+			case QualifierDI:
+				continue
+			}
+			if !slices.Contains(q, qual) {
+				q = append(q, qual)
+			}
+		}
+		if len(q) == 0 {
+			continue
+		}
+		if ret == nil {
+			ret = make(map[string][]string)
+		}
+		ret[d] = q
+	}
+	return ret
+}
+
+// YAMLReporter writes the same report as JSONReporter, as YAML.
+type YAMLReporter struct {
+	Schema    string
+	Positions bool
+}
+
+func (y YAMLReporter) Report(w io.Writer, r *Result) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	var err error
+	if y.Schema == JSONSchemaV1 {
+		err = enc.Encode(newJSONReportV1(r))
+	} else {
+		err = enc.Encode(newJSONReportV2(r, y.Positions))
+	}
+	if err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// PrintErrors writes scan errors to stderr.
+func PrintErrors(errs []error) {
+	if len(errs) == 0 {
+		return
+	}
+	fmt.Fprintln(os.Stderr, "errors:")
+	for _, err := range errs {
+		fmt.Fprintln(os.Stderr, err)
+	}
+}
+
+// Fatal prints err to stderr and exits.
+func Fatal(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
+
+// OutputFlag registers the -o flag on fs.
+func OutputFlag(fs *flag.FlagSet) *string {
+	return fs.String("o", "", "Write output to this `file` instead of stdout")
+}
+
+type nopCloser struct{ io.Writer }
+
+func (nopCloser) Close() error { return nil }
+
+// OpenOutput returns a writer for the file at path, or stdout if path is
+// empty or "-".
+func OpenOutput(path string) io.WriteCloser {
+	if path == "" || path == "-" {
+		return nopCloser{os.Stdout}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		Fatal(err)
+	}
+	return f
+}
@@ -0,0 +1,190 @@
+package wuw
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Thresholds of the risk score of a change, below which it is low or medium
+// risk.
+const (
+	lowRisk    = 10
+	mediumRisk = 50
+)
+
+// PackageRisk is how risky changing a package is, going by how many packages
+// depend on it and how well it is tested.
+type PackageRisk struct {
+	Pkg        string
+	Dependents []string
+	Tested     bool
+	// Coverage is the share of statements covered by tests, or -1 if there
+	// is no coverage profile for the package.
+	Coverage float64
+	Score    float64
+}
+
+// NewPackageRisk scores a change to p as one point for it and every package
+// depending on it, doubled when p has no tests, or by the share of its
+// statements left uncovered when its coverage is known.
+func NewPackageRisk(g *Graph, p string, coverage map[string]float64) *PackageRisk {
+	r := &PackageRisk{Pkg: p, Dependents: g.Dependents(p), Coverage: -1}
+	r.Tested = slices.ContainsFunc(g.Pkgs[p].Files, func(f string) bool { return strings.HasSuffix(f, "_test.go") })
+
+	weight := 2.0
+	if c, ok := coverage[p]; ok {
+		r.Coverage = c
+		weight = 2 - c
+	} else if r.Tested {
+		weight = 1
+	}
+	r.Score = float64(1+len(r.Dependents)) * weight
+	return r
+}
+
+// RiskLevel names how risky a change with the given score is.
+func RiskLevel(score float64) string {
+	switch {
+	case score < lowRisk:
+		return "low"
+	case score < mediumRisk:
+		return "medium"
+	}
+	return "high"
+}
+
+// ReadCoverProfile returns the share of statements covered of each package
+// in the profile written by 'go test -coverprofile'.
+func ReadCoverProfile(r io.Reader) (map[string]float64, error) {
+	stmts := make(map[string]int)
+	covered := make(map[string]int)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "mode:") || line == "" {
+			continue
+		}
+
+		// file.go:line.col,line.col statements count
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("malformed coverage line %q", line)
+		}
+		file, _, ok := strings.Cut(fields[0], ":")
+		if !ok {
+			return nil, fmt.Errorf("malformed coverage line %q", line)
+		}
+		n, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("malformed coverage line %q", line)
+		}
+		count, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("malformed coverage line %q", line)
+		}
+
+		pkg := path.Dir(file)
+		stmts[pkg] += n
+		if count > 0 {
+			covered[pkg] += n
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	ret := make(map[string]float64)
+	for pkg, n := range stmts {
+		if n != 0 {
+			ret[pkg] = float64(covered[pkg]) / float64(n)
+		}
+	}
+	return ret, nil
+}
+
+func RunRisk(args []string) {
+	fs := flag.NewFlagSet("risk", flag.ExitOnError)
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintln(w, "'wuw risk' scores the change from a git ref to the working tree by how many packages depend on the packages it touches, and how well those are tested.")
+		fmt.Fprintf(w, "Usage: %s risk [-opts] [dirs...]\nopts:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+
+	againstVar := fs.String("against", "origin/main", "Git `ref` the change is made against")
+	coverVar := fs.String("coverprofile", "", "Weigh packages by their coverage in this `file` written by 'go test -coverprofile'")
+	outVar := OutputFlag(fs)
+
+	ParseFlags(fs, args)
+
+	var coverage map[string]float64
+	if *coverVar != "" {
+		f, err := os.Open(*coverVar)
+		if err != nil {
+			Fatal(err)
+		}
+		coverage, err = ReadCoverProfile(f)
+		f.Close()
+		if err != nil {
+			Fatal(fmt.Errorf("%s: %w", *coverVar, err))
+		}
+	}
+
+	dirs := ReadArgs(fs.Args(), fs.Usage)
+	pkgs, errs := ScanDirs(dirs, true)
+
+	PrintErrors(errs)
+
+	g := NewGraph(pkgs)
+
+	v, err := OpenVCS(".")
+	if err != nil {
+		Fatal(err)
+	}
+	changed, err := v.Changed(*againstVar)
+	if err != nil {
+		Fatal(err)
+	}
+
+	var modified []string
+	for _, p := range g.Order {
+		dir := filepath.Clean(g.Pkgs[p].Path)
+		if slices.ContainsFunc(changed, func(f string) bool { return filepath.Ext(f) == ".go" && filepath.Dir(f) == dir }) {
+			modified = append(modified, p)
+		}
+	}
+
+	w := OpenOutput(*outVar)
+	defer w.Close()
+
+	var score float64
+	affected := make(map[string]bool)
+	fmt.Fprintf(w, "modified packages (%d):\n", len(modified))
+	for _, p := range modified {
+		r := NewPackageRisk(g, p, coverage)
+		score += r.Score
+		affected[p] = true
+		for _, d := range r.Dependents {
+			affected[d] = true
+		}
+
+		tests := "no tests"
+		if r.Coverage >= 0 {
+			tests = fmt.Sprintf("%.1f%% covered", 100*r.Coverage)
+		} else if r.Tested {
+			tests = "tested"
+		}
+		fmt.Fprintf(w, "\t%s: %d dependents, %s, score %.1f\n", p, len(r.Dependents), tests, r.Score)
+	}
+	fmt.Fprintf(w, "affected packages: %d of %d\n", len(affected), len(g.Order))
+	fmt.Fprintf(w, "risk: %s (score %.1f)\n", RiskLevel(score), score)
+}
@@ -0,0 +1,80 @@
+package wuw
+
+// How imports and packages fare against the rules of the config.
+const (
+	StatusClean     = "clean"
+	StatusExempted  = "exempted"
+	StatusViolating = "violating"
+)
+
+// statusColors are what diagrams color each status with.
+var statusColors = map[string]string{
+	StatusClean:     "#2ca02c",
+	StatusExempted:  "#ff7f0e",
+	StatusViolating: "#d62728",
+}
+
+// statusLegend explains the colors of the statuses in Graphviz legends.
+var statusLegend = []LegendEntry{
+	{Shape: "box", Label: "breaks a rule", Color: statusColors[StatusViolating]},
+	{Shape: "box", Label: "exempted by an allow", Color: statusColors[StatusExempted]},
+	{Shape: "box", Label: "clean", Color: statusColors[StatusClean]},
+}
+
+// RuleStatus is the status of every import of the packages checked against
+// the rules, and of the packages themselves, which is the worst of their
+// imports. An import is exempted when a rule denies it but one of the
+// rule's allow patterns lets it through.
+type RuleStatus struct {
+	Edges map[Edge]string
+	Pkgs  map[string]string
+}
+
+// Status works out the status of the imports of g. Experimental packages
+// are not checked, and have none.
+func (c *Config) Status(g *Graph) *RuleStatus {
+	s := &RuleStatus{Edges: make(map[Edge]string), Pkgs: make(map[string]string)}
+	rank := map[string]int{StatusClean: 0, StatusExempted: 1, StatusViolating: 2}
+	for _, p := range g.Order {
+		if g.Pkgs[p].Experimental {
+			continue
+		}
+		s.Pkgs[p] = StatusClean
+		for _, d := range g.Pkgs[p].Deps {
+			status := StatusClean
+			for _, r := range c.Rules {
+				dec := r.Evaluate(p, d)
+				if dec.Denied {
+					status = StatusViolating
+					break
+				}
+				if dec.Deny != nil {
+					status = StatusExempted
+				}
+			}
+			s.Edges[Edge{From: p, To: d}] = status
+			if rank[status] > rank[s.Pkgs[p]] {
+				s.Pkgs[p] = status
+			}
+		}
+	}
+	return s
+}
+
+// Of returns the status of the package at path, or "" if s is nil or the
+// package wasn't checked.
+func (s *RuleStatus) Of(path string) string {
+	if s == nil {
+		return ""
+	}
+	return s.Pkgs[path]
+}
+
+// Edge returns the status of the import of e, or "" if s is nil or the
+// import wasn't checked.
+func (s *RuleStatus) Edge(e Edge) string {
+	if s == nil {
+		return ""
+	}
+	return s.Edges[e]
+}
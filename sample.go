@@ -0,0 +1,64 @@
+package wuw
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"slices"
+)
+
+// SampleDirs picks a deterministic subset of dirs: those whose hash falls
+// below fraction, capped at max if it is not 0. Hashing the path rather than
+// shuffling means a dir stays in or out of the sample as the tree grows.
+func SampleDirs(dirs []string, fraction float64, max int) []string {
+	hash := func(s string) uint64 {
+		h := fnv.New64a()
+		h.Write([]byte(s))
+		return h.Sum64()
+	}
+
+	var ret []string
+	for _, d := range dirs {
+		if float64(hash(d)) < fraction*math.MaxUint64 {
+			ret = append(ret, d)
+		}
+	}
+
+	if max > 0 && len(ret) > max {
+		slices.SortFunc(ret, func(a, b string) int {
+			ha, hb := hash(a), hash(b)
+			switch {
+			case ha < hb:
+				return -1
+			case ha > hb:
+				return 1
+			}
+			return 0
+		})
+		ret = ret[:max]
+	}
+	return ret
+}
+
+// WriteSampleSummary extrapolates totals for the whole tree from the packages
+// found in a sample.
+func WriteSampleSummary(w io.Writer, total, sampled int, pkgs []Package) {
+	if sampled == 0 {
+		fmt.Fprintf(w, "sample: 0 of %d dirs\n", total)
+		return
+	}
+	scale := float64(total) / float64(sampled)
+
+	var imports int
+	for _, p := range pkgs {
+		imports += len(p.Deps)
+	}
+
+	fmt.Fprintf(w, "sample: %d of %d dirs (%.1f%%)\n", sampled, total, 100/scale)
+	fmt.Fprintf(w, "packages found: %d, estimated total: %.0f\n", len(pkgs), float64(len(pkgs))*scale)
+	fmt.Fprintf(w, "imports found: %d, estimated total: %.0f\n", imports, float64(imports)*scale)
+	if len(pkgs) != 0 {
+		fmt.Fprintf(w, "avg imports per package: %.2f\n", float64(imports)/float64(len(pkgs)))
+	}
+}
@@ -0,0 +1,301 @@
+package wuw
+
+import (
+	"cmp"
+	"fmt"
+	"go/build"
+	"io"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OS is the file system of the machine wuw runs on. Unlike os.DirFS, it takes
+// paths as the user wrote them, absolute or relative to the working dir.
+var OS fs.FS = osFS{}
+
+type osFS struct{}
+
+func (osFS) Open(name string) (fs.File, error) {
+	return os.Open(name)
+}
+
+func (osFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return os.ReadDir(name)
+}
+
+// Scanner reads the packages in a list of dirs. FS and Now default to the
+// real file system and clock, and can be swapped out to scan fixtures.
+type Scanner struct {
+	FS    fs.FS
+	Now   func() time.Time
+	NoStd bool
+	Hooks Hooks
+	// MaxOpen bounds how many files are open at once.
+	MaxOpen int
+	// Timings, if set, is where the time spent reading dirs, parsing files
+	// and classifying their packages is added up.
+	Timings *Timings
+	// Coverage, if set, is where the dirs left out of the scan are
+	// recorded, with why.
+	Coverage *Coverage
+	// Build, if set, is the build configuration whose files are read,
+	// leaving out the others.
+	Build *build.Context
+	// NoTests leaves out _test.go files, and the dirs only having those.
+	NoTests bool
+}
+
+// DefaultMaxOpen keeps well below the usual ulimit -n of 256 to 1024.
+const DefaultMaxOpen = 64
+
+// Hooks are called as a Scan goes along, for embedders to report progress or
+// collect metrics. Any of them may be nil. Those returning a bool can cut the
+// scan short: OnDirStart skips the dir when it returns false, and OnPackage
+// and OnError stop the scan, returning what was read so far.
+//
+// Scans served by the daemon only call OnPackage and OnError. Scans
+// recording their Coverage, for a Build or leaving out tests aren't served
+// by it.
+type Hooks struct {
+	OnDirStart   func(dir string) bool
+	OnFileParsed func(file string, imports []string)
+	OnPackage    func(pkg *Package) bool
+	OnError      func(dir string, err error) bool
+}
+
+// NewScanner returns a Scanner of the real file system, leaving out the
+// standard library from deps if noStd is set.
+func NewScanner(noStd bool) *Scanner {
+	return &Scanner{FS: OS, Now: time.Now, NoStd: noStd, MaxOpen: DefaultMaxOpen, Coverage: scanCoverage, Build: scanBuild}
+}
+
+// Scan reads the package in each of dirs, in order. Dirs that can't be read
+// or have no Go files are skipped, and the files that can't be parsed are
+// returned as errors along with the packages that could be.
+func (s *Scanner) Scan(dirs []string) ([]Package, []error) {
+	// the daemon doesn't say what it skipped, and reads every file
+	if useDaemon(s.FS) && s.Coverage == nil && s.Build == nil && !s.NoTests {
+		pkgs, errs, err := ScanWithDaemon(daemonSocket, dirs, s.NoStd)
+		if err == nil {
+			return s.replay(pkgs, errs)
+		}
+		fmt.Fprintf(os.Stderr, "warning: could not use daemon, scanning locally: %v\n", err)
+	}
+	if scanCache != nil && s.FS == OS && s.Build == nil && !s.NoTests {
+		pkgs, errs := scanCache.Scan(s, dirs)
+		return s.replay(pkgs, errs)
+	}
+	return s.scan(dirs)
+}
+
+func (s *Scanner) scan(dirs []string) ([]Package, []error) {
+	var pkgs []Package
+	var errs []error
+
+	// fail records err, reporting whether the scan should go on
+	fail := func(d string, err error) bool {
+		errs = append(errs, err)
+		return s.Hooks.OnError == nil || s.Hooks.OnError(d, err)
+	}
+
+	for _, d := range dirs {
+		if s.Hooks.OnDirStart != nil && !s.Hooks.OnDirStart(d) {
+			s.Coverage.Skip(d, SkipHook, "")
+			continue
+		}
+
+		stop := s.Timings.Start(PhaseWalk)
+		entry, err := fs.ReadDir(s.FS, d)
+		stop()
+		if err != nil {
+			s.Coverage.Skip(d, SkipUnreadable, err.Error())
+			continue
+		}
+
+		go_files := GetGoFiles(d, entry)
+		if len(go_files) == 0 {
+			s.Coverage.Skip(d, SkipNoGo, "")
+			continue
+		}
+		if s.NoTests {
+			go_files = slices.DeleteFunc(go_files, func(f string) bool { return strings.HasSuffix(f, "_test.go") })
+			if len(go_files) == 0 {
+				s.Coverage.Skip(d, SkipNoGo, "only _test.go files")
+				continue
+			}
+		}
+		if s.Build != nil {
+			if go_files = BuildFiles(s.Build, s.FS, d, go_files); len(go_files) == 0 {
+				s.Coverage.Skip(d, SkipBuild, "")
+				continue
+			}
+		}
+
+		stop = s.Timings.Start(PhaseParse)
+		files := s.scanFiles(go_files)
+		stop()
+
+		var names []string
+		var nameErr error
+		for _, f := range files {
+			switch {
+			case f.openErr != nil:
+				if !fail(d, f.openErr) {
+					return pkgs, errs
+				}
+			case f.nameErr != nil:
+				nameErr = cmp.Or(nameErr, f.nameErr)
+			default:
+				names = append(names, f.name)
+			}
+		}
+
+		pkg_name, err := GetPackageName(d, names)
+		if err = cmp.Or(nameErr, err); err != nil {
+			if !fail(d, err) {
+				return pkgs, errs
+			}
+			continue
+		}
+
+		var imports, xtests []string
+		fileImports := make(map[string][]string)
+		aliases := make(map[string][]string)
+		for _, f := range files {
+			if f.openErr != nil {
+				continue
+			}
+			if f.name == pkg_name+"_test" {
+				xtests = append(xtests, f.file)
+			}
+			if f.importsErr != nil {
+				if !fail(d, f.importsErr) {
+					return pkgs, errs
+				}
+				continue
+			}
+			fileImports[f.file] = f.imports
+			if s.Hooks.OnFileParsed != nil {
+				s.Hooks.OnFileParsed(f.file, f.imports)
+			}
+			for _, s := range f.imports {
+				if !slices.Contains(imports, s) {
+					imports = append(imports, s)
+				}
+			}
+			for path, names := range f.aliases {
+				aliases[path] = union(aliases[path], names)
+			}
+		}
+
+		stop = s.Timings.Start(PhaseClassify)
+		pkg := Package{Name: pkg_name, Path: d, ImportPath: ImportPathFS(s.FS, d), Files: go_files, Deps: FilterDependencies(imports, s.NoStd)}
+		pkg.Generated, pkg.Sources = Provenance(s.FS, d, entry, go_files)
+		pkg.Qualifiers = EdgeQualifiers(s.FS, fileImports, pkg.Generated, xtests)
+		for _, d := range pkg.Deps {
+			if len(aliases[d]) != 0 {
+				if pkg.Aliases == nil {
+					pkg.Aliases = make(map[string][]string)
+				}
+				pkg.Aliases[d] = aliases[d]
+			}
+		}
+		// the external tests importing the package they test doesn't make
+		// it import itself
+		if i := slices.Index(pkg.Deps, pkg.ImportPath); i != -1 && slices.Contains(pkg.Qualifiers[pkg.ImportPath], QualifierXTest) {
+			pkg.Deps = slices.Delete(pkg.Deps, i, i+1)
+			delete(pkg.Qualifiers, pkg.ImportPath)
+			delete(pkg.Aliases, pkg.ImportPath)
+		}
+		if i := slices.Index(pkg.Deps, "C"); i != -1 {
+			pkg.Deps = slices.Delete(pkg.Deps, i, i+1)
+			delete(pkg.Qualifiers, "C")
+			delete(pkg.Aliases, "C")
+			pkg.Cgo = true
+		}
+		pkg.Experimental = slices.ContainsFunc(go_files, func(f string) bool {
+			return !strings.HasSuffix(f, "_test.go") && HasExperimentalMarker(s.FS, f)
+		})
+		stop()
+		pkgs = append(pkgs, pkg)
+		if s.Hooks.OnPackage != nil && !s.Hooks.OnPackage(&pkgs[len(pkgs)-1]) {
+			return pkgs, errs
+		}
+	}
+
+	return pkgs, errs
+}
+
+// fileScan is what reading the head of a Go file found.
+type fileScan struct {
+	file    string
+	name    string
+	imports []string
+	aliases map[string][]string
+
+	openErr, nameErr, importsErr error
+}
+
+// scanFiles reads the package clause and imports of each of files, closing
+// each file as soon as it is read. At most MaxOpen files are open at once.
+func (s *Scanner) scanFiles(files []string) []fileScan {
+	ret := make([]fileScan, len(files))
+	sem := make(chan struct{}, max(s.MaxOpen, 1))
+
+	var wg sync.WaitGroup
+	for i, file := range files {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			ret[i] = s.scanFile(file)
+		}()
+	}
+	wg.Wait()
+	return ret
+}
+
+func (s *Scanner) scanFile(file string) fileScan {
+	fs := fileScan{file: file}
+
+	f, err := s.FS.Open(file)
+	if err != nil {
+		fs.openErr = err
+		return fs
+	}
+	src, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		fs.openErr = err
+		return fs
+	}
+
+	if fs.name, fs.nameErr = ReadPackageName(file, src); fs.nameErr != nil {
+		return fs
+	}
+	fs.imports, fs.aliases, fs.importsErr = ParseFileForImports(file, src)
+	return fs
+}
+
+// replay calls the hooks for a scan the daemon did, cutting its results
+// short when they ask to.
+func (s *Scanner) replay(pkgs []Package, errs []error) ([]Package, []error) {
+	for i, err := range errs {
+		if s.Hooks.OnError != nil && !s.Hooks.OnError("", err) {
+			return nil, errs[:i+1]
+		}
+	}
+	for i := range pkgs {
+		if s.Hooks.OnPackage != nil && !s.Hooks.OnPackage(&pkgs[i]) {
+			return pkgs[:i+1], errs
+		}
+	}
+	return pkgs, errs
+}
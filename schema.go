@@ -0,0 +1,194 @@
+package wuw
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed config.schema.json
+var configSchemaJSON []byte
+
+// outputSchemas are the JSON Schemas of each version of the JSON and YAML
+// output, for integrators to validate it and generate code from.
+var (
+	//go:embed output.v1.schema.json
+	outputSchemaV1 []byte
+	//go:embed output.v2.schema.json
+	outputSchemaV2 []byte
+)
+
+// OutputSchema returns the JSON Schema of the JSON and YAML output in
+// schema, or of a line of NDJSON output if ndjson is set, which is a
+// package of the v2 output or a scan error.
+func OutputSchema(schema string, ndjson bool) ([]byte, error) {
+	if _, err := CheckJSONSchema(schema); err != nil {
+		return nil, err
+	}
+	if !ndjson {
+		if schema == JSONSchemaV1 {
+			return outputSchemaV1, nil
+		}
+		return outputSchemaV2, nil
+	}
+	if schema == JSONSchemaV1 {
+		return nil, fmt.Errorf("NDJSON output only has schema %s", JSONSchemaV2)
+	}
+
+	var v2 map[string]any
+	if err := json.Unmarshal(outputSchemaV2, &v2); err != nil {
+		return nil, err
+	}
+	line := map[string]any{
+		"$schema":  v2["$schema"],
+		"$comment": v2["$comment"],
+		"title":    "wuw NDJSON output line, schema v2",
+		"oneOf": []any{
+			map[string]any{"$ref": "#/$defs/package"},
+			map[string]any{
+				"type":                 "object",
+				"additionalProperties": false,
+				"required":             []string{"error"},
+				"properties":           map[string]any{"error": map[string]any{"type": "string"}},
+			},
+		},
+		"$defs": v2["$defs"],
+	}
+	data, err := json.MarshalIndent(line, "", "  ")
+	return append(data, '\n'), err
+}
+
+// Schema is the subset of JSON Schema that wuw uses to describe its files.
+type Schema struct {
+	Type                 string             `json:"type"`
+	Properties           map[string]*Schema `json:"properties"`
+	AdditionalProperties *Additional        `json:"additionalProperties"`
+	Required             []string           `json:"required"`
+	Items                *Schema            `json:"items"`
+	Format               string             `json:"format"`
+	Enum                 []string           `json:"enum"`
+}
+
+// Additional is the additionalProperties of a Schema: either whether keys
+// outside of Properties are allowed at all, or the schema of their values.
+type Additional struct {
+	Allowed bool
+	Schema  *Schema
+}
+
+func (a *Additional) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &a.Allowed); err == nil {
+		return nil
+	}
+	a.Allowed = true
+	return json.Unmarshal(data, &a.Schema)
+}
+
+func ConfigSchema() *Schema {
+	var s Schema
+	if err := json.Unmarshal(configSchemaJSON, &s); err != nil {
+		panic(err)
+	}
+	return &s
+}
+
+type SchemaError struct {
+	Line int
+	Col  int
+	Path string
+	Msg  string
+}
+
+func (e SchemaError) Error() string {
+	return fmt.Sprintf("%d:%d: %s: %s", e.Line, e.Col, e.Path, e.Msg)
+}
+
+// Validate checks a decoded yaml document against s.
+func (s *Schema) Validate(node *yaml.Node) []SchemaError {
+	if node.Kind == yaml.DocumentNode {
+		if len(node.Content) == 0 {
+			return nil
+		}
+		node = node.Content[0]
+	}
+	return s.validate(node, "$")
+}
+
+func (s *Schema) validate(n *yaml.Node, path string) []SchemaError {
+	errorf := func(n *yaml.Node, format string, args ...any) SchemaError {
+		return SchemaError{Line: n.Line, Col: n.Column, Path: path, Msg: fmt.Sprintf(format, args...)}
+	}
+
+	if n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+
+	var errs []SchemaError
+	switch s.Type {
+	case "object":
+		if n.Kind != yaml.MappingNode {
+			return []SchemaError{errorf(n, "expected a mapping")}
+		}
+		seen := make(map[string]bool)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, v := n.Content[i], n.Content[i+1]
+			seen[k.Value] = true
+			prop, ok := s.Properties[k.Value]
+			if !ok {
+				switch a := s.AdditionalProperties; {
+				case a == nil:
+				case !a.Allowed:
+					errs = append(errs, errorf(k, "unknown key %q", k.Value))
+				case a.Schema != nil:
+					errs = append(errs, a.Schema.validate(v, path+"."+k.Value)...)
+				}
+				continue
+			}
+			errs = append(errs, prop.validate(v, path+"."+k.Value)...)
+		}
+		for _, r := range s.Required {
+			if !seen[r] {
+				errs = append(errs, errorf(n, "missing required key %q", r))
+			}
+		}
+	case "array":
+		if n.Kind != yaml.SequenceNode {
+			return []SchemaError{errorf(n, "expected a list")}
+		}
+		if s.Items != nil {
+			for i, item := range n.Content {
+				errs = append(errs, s.Items.validate(item, path+"["+strconv.Itoa(i)+"]")...)
+			}
+		}
+	case "string", "boolean", "integer", "number":
+		if n.Kind != yaml.ScalarNode {
+			return []SchemaError{errorf(n, "expected a %s", s.Type)}
+		}
+		tag := n.ShortTag()
+		switch {
+		case s.Type == "boolean" && tag != "!!bool",
+			s.Type == "integer" && tag != "!!int",
+			s.Type == "number" && tag != "!!int" && tag != "!!float":
+			return []SchemaError{errorf(n, "expected a %s, got %q", s.Type, n.Value)}
+		}
+		if s.Format == "regex" {
+			if _, err := regexp.Compile(n.Value); err != nil {
+				errs = append(errs, errorf(n, "bad regex: %v", err))
+			}
+		}
+		if s.Format == "metric" {
+			if _, err := ParseMetricExpr(n.Value); err != nil {
+				errs = append(errs, errorf(n, "bad metric: %v", err))
+			}
+		}
+		if len(s.Enum) != 0 && !slices.Contains(s.Enum, n.Value) {
+			errs = append(errs, errorf(n, "%q is not one of %v", n.Value, s.Enum))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,146 @@
+package wuw
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+)
+
+// serviceKinds are the kinds of framework that talk to something running
+// outside of the process.
+var serviceKinds = []string{"database", "messaging", "cloud", "rpc"}
+
+// ServiceUse is a service client linked into a main package, with the
+// internal packages that import it.
+type ServiceUse struct {
+	*Framework
+	Via []string
+}
+
+// MainServices returns the service clients that main links in through any
+// of the internal packages it imports, directly or not.
+func MainServices(g *Graph, main string) []*ServiceUse {
+	uses := make(map[string]*ServiceUse)
+	for _, p := range g.Reachable(main) {
+		for _, d := range g.Pkgs[p].Deps {
+			f, ok := FrameworkOf(d)
+			if !ok || !slices.Contains(serviceKinds, f.Kind) {
+				continue
+			}
+			u, ok := uses[f.Name]
+			if !ok {
+				u = &ServiceUse{Framework: f}
+				uses[f.Name] = u
+			}
+			if !slices.Contains(u.Via, p) {
+				u.Via = append(u.Via, p)
+			}
+		}
+	}
+
+	var ret []*ServiceUse
+	for _, f := range frameworks {
+		if u, ok := uses[f.Name]; ok {
+			ret = append(ret, u)
+		}
+	}
+	return ret
+}
+
+func RunServices(args []string) {
+	fs := flag.NewFlagSet("services", flag.ExitOnError)
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintln(w, "'wuw services' lists, for each main package, the database, queue, cloud and RPC clients it links in, as an approximation of what it talks to at runtime.")
+		fmt.Fprintf(w, "Usage: %s services [-opts] [dirs...]\nopts:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+
+	formatVar := fs.String("format", "text", "Output format: text, or dot for a deployment diagram")
+	legendVar := LegendFlag(fs)
+	outVar := OutputFlag(fs)
+
+	ParseFlags(fs, args)
+
+	if *formatVar != "text" && *formatVar != "dot" {
+		Fatal(fmt.Errorf("unknown format %q", *formatVar))
+	}
+
+	dirs := ReadArgs(fs.Args(), fs.Usage)
+	scanner := NewScanner(false)
+	pkgs, errs := scanner.Scan(dirs)
+
+	PrintErrors(errs)
+
+	g := NewGraph(pkgs)
+
+	services := make(map[string][]*ServiceUse)
+	var mains []string
+	for _, p := range g.Order {
+		if g.Pkgs[p].Name == "main" {
+			mains = append(mains, p)
+			services[p] = MainServices(g, p)
+		}
+	}
+
+	w := OpenOutput(*outVar)
+	defer w.Close()
+
+	if *formatVar == "dot" {
+		var info *DiagramInfo
+		if *legendVar {
+			legend := []LegendEntry{{Shape: "box3d", Label: "main package"}}
+			for _, k := range serviceKinds {
+				legend = append(legend, LegendEntry{Shape: serviceShapes[k], Label: k})
+			}
+			info = NewDiagramInfo(fs, scanner.Now(), legend)
+		}
+		WriteServicesDot(w, mains, services, info)
+		return
+	}
+
+	for _, m := range mains {
+		fmt.Fprintf(w, "%s:\n", m)
+		for _, u := range services[m] {
+			fmt.Fprintf(w, "\t%s (%s)\n", u.Name, u.Kind)
+			for _, p := range u.Via {
+				fmt.Fprintf(w, "\t\tvia %s\n", p)
+			}
+		}
+	}
+}
+
+// serviceShapes are the Graphviz shapes services are drawn with, by kind.
+var serviceShapes = map[string]string{
+	"database":  "cylinder",
+	"messaging": "cds",
+	"cloud":     "octagon",
+	"rpc":       "component",
+}
+
+// WriteServicesDot draws the main packages as boxes with an edge to each
+// service they link in, and the legend of info unless it is nil.
+func WriteServicesDot(w io.Writer, mains []string, services map[string][]*ServiceUse, info *DiagramInfo) {
+	q := DotQuote
+
+	fmt.Fprintln(w, "digraph services {")
+	fmt.Fprintln(w, "\trankdir=LR;")
+
+	var drawn []string
+	for _, m := range mains {
+		fmt.Fprintf(w, "\t%s [shape=box3d];\n", q(m))
+		for _, u := range services[m] {
+			if !slices.Contains(drawn, u.Name) {
+				drawn = append(drawn, u.Name)
+				fmt.Fprintf(w, "\t%s [shape=%s, label=%s];\n", q("service:"+u.Name), serviceShapes[u.Kind], q(u.Name+"\n"+u.Kind))
+			}
+			fmt.Fprintf(w, "\t%s -> %s;\n", q(m), q("service:"+u.Name))
+		}
+	}
+	if info != nil {
+		WriteDotLegend(w, info)
+	}
+	fmt.Fprintln(w, "}")
+}
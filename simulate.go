@@ -0,0 +1,373 @@
+package wuw
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+func RunSimulate(args []string) {
+	fs := flag.NewFlagSet("simulate", flag.ExitOnError)
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintln(w, "'wuw simulate' reports what would happen to the dependency graph if a refactor was carried out, without touching any code.")
+		fmt.Fprintf(w, "Usage: %s simulate [-opts] [dirs...]\nopts:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+
+	removeVar := fs.String("remove", "", "Simulate removing an import path (and every package below it)")
+	moveVar := fs.String("move", "", "Simulate moving a package (and every package below it) as `old=new`")
+	mergeVar := fs.String("merge", "", "Simulate merging packages `pkgA,pkgB,...` into the first one")
+	outVar := OutputFlag(fs)
+	noStdVar := fs.Bool("no-std", false, "Exclude stdlib packages (including golang.org/x/)")
+
+	ParseFlags(fs, args)
+
+	if *removeVar == "" && *moveVar == "" && *mergeVar == "" {
+		fmt.Fprintln(os.Stderr, "No simulation provided. Displaying usage...")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	var from, to string
+	if *moveVar != "" {
+		var ok bool
+		from, to, ok = strings.Cut(*moveVar, "=")
+		if !ok || from == "" || to == "" {
+			fmt.Fprintf(os.Stderr, "error: malformed -move %q, expected old=new\n", *moveVar)
+			os.Exit(1)
+		}
+	}
+
+	dirs := ReadArgs(fs.Args(), fs.Usage)
+	pkgs, errs := ScanDirs(dirs, *noStdVar)
+
+	PrintErrors(errs)
+
+	before := NewGraph(pkgs)
+	after := before
+
+	w := OpenOutput(*outVar)
+	defer w.Close()
+
+	if *removeVar != "" {
+		path := before.Resolve(*removeVar)
+
+		var broken []BrokenPackage
+		after, broken = SimulateRemove(after, path)
+
+		fmt.Fprintf(w, "simulating removal of %s\n\n", path)
+
+		fmt.Fprintf(w, "would fail to build (%d):\n", len(broken))
+		for _, b := range broken {
+			fmt.Fprintf(w, "\t%s\n", b.Pkg)
+			for _, i := range b.Imports {
+				fmt.Fprintf(w, "\t\timports %s\n", i)
+			}
+		}
+		fmt.Fprintln(w)
+	}
+
+	if *moveVar != "" {
+		from = after.Resolve(from)
+		to = ResolveTarget(after, from, to)
+
+		var moved map[string]string
+		after, moved = SimulateMove(after, from, to)
+
+		fmt.Fprintf(w, "simulating move of %s to %s\n\n", from, to)
+
+		if len(moved) == 0 {
+			fmt.Fprintf(w, "no scanned packages match %s\n\n", from)
+		}
+
+		var keys []string
+		for k := range moved {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+
+		fmt.Fprintf(w, "moved packages (%d):\n", len(keys))
+		for _, k := range keys {
+			fmt.Fprintf(w, "\t%s -> %s\n", k, moved[k])
+			if importers := after.Importers(moved[k]); len(importers) != 0 {
+				fmt.Fprintf(w, "\t\t%d importers to update\n", len(importers))
+			}
+		}
+		fmt.Fprintln(w)
+
+		existing := make(map[Edge]bool)
+		for _, v := range before.Violations() {
+			existing[Edge{From: Renamed(moved, v.From), To: Renamed(moved, v.To)}] = true
+		}
+
+		var introduced []Edge
+		for _, v := range after.Violations() {
+			if !existing[v] {
+				introduced = append(introduced, v)
+			}
+		}
+
+		fmt.Fprintf(w, "new internal visibility violations (%d):\n", len(introduced))
+		for _, v := range introduced {
+			fmt.Fprintf(w, "\t%s -> %s\n", v.From, v.To)
+		}
+		fmt.Fprintln(w)
+
+		c, err := LoadConfig(DefaultConfig)
+		if err != nil && !os.IsNotExist(err) {
+			Fatal(err)
+		}
+		if c != nil && len(c.Rules) != 0 {
+			broken := make(map[Violation]bool)
+			for _, v := range c.Check(before) {
+				v.Edge = Edge{From: Renamed(moved, v.From), To: Renamed(moved, v.To)}
+				broken[v] = true
+			}
+
+			var layering []Violation
+			for _, v := range c.Check(after) {
+				if !broken[v] {
+					layering = append(layering, v)
+				}
+			}
+
+			fmt.Fprintf(w, "new layering violations (%d):\n", len(layering))
+			for _, v := range layering {
+				fmt.Fprintf(w, "\t%s -> %s: %s", v.From, v.To, v.Rule.Name)
+				if v.Rule.Reason != "" {
+					fmt.Fprintf(w, " (%s)", v.Rule.Reason)
+				}
+				fmt.Fprintln(w)
+			}
+			fmt.Fprintln(w)
+		}
+	}
+
+	if *mergeVar != "" {
+		var paths []string
+		for _, m := range strings.Split(*mergeVar, ",") {
+			paths = append(paths, after.Resolve(strings.TrimSpace(m)))
+		}
+
+		// after so far has any move applied, which may have made one of paths
+		merging := after
+		var ok bool
+		after, ok = SimulateMerge(merging, paths)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "error: -merge needs at least two scanned packages, got %s\n", *mergeVar)
+			os.Exit(1)
+		}
+		merged := paths[0]
+
+		fmt.Fprintf(w, "simulating merge of %s into %s\n\n", strings.Join(paths[1:], ", "), merged)
+
+		moved := make(map[string]string)
+		for _, p := range paths[1:] {
+			moved[p] = merged
+		}
+		var existing [][]string
+		for _, c := range merging.Cycles() {
+			var renamed []string
+			for _, p := range c {
+				renamed = append(renamed, Renamed(moved, p))
+			}
+			existing = append(existing, renamed)
+		}
+
+		// a cycle all of whose packages were already in one isn't new
+		var cycles [][]string
+		for _, c := range after.Cycles() {
+			if !slices.Contains(c, merged) {
+				continue
+			}
+			if !slices.ContainsFunc(existing, func(e []string) bool {
+				return !slices.ContainsFunc(c, func(p string) bool { return !slices.Contains(e, p) })
+			}) {
+				cycles = append(cycles, c)
+			}
+		}
+
+		fmt.Fprintf(w, "cycles created (%d):\n", len(cycles))
+		for _, c := range cycles {
+			fmt.Fprintf(w, "\t%s\n", strings.Join(c, ", "))
+		}
+		fmt.Fprintln(w)
+
+		fmt.Fprintf(w, "fan-in:  %d\n", len(after.Importers(merged)))
+		fmt.Fprintf(w, "fan-out: %d\n\n", len(after.InternalDeps(merged)))
+
+		var external []string
+		for _, d := range after.Pkgs[merged].Deps {
+			if !after.IsInternal(d) {
+				external = append(external, d)
+			}
+		}
+
+		fmt.Fprintf(w, "external footprint (%d):\n", len(external))
+		for _, e := range external {
+			if slices.Contains(merging.Pkgs[merged].Deps, e) {
+				fmt.Fprintf(w, "\t%s\n", e)
+			} else {
+				fmt.Fprintf(w, "\t%s (new)\n", e)
+			}
+		}
+		fmt.Fprintln(w)
+	}
+
+	PrintMetricsDiff(w, before.Metrics(), after.Metrics())
+}
+
+type BrokenPackage struct {
+	Pkg     string
+	Imports []string
+}
+
+// SimulateRemove returns a copy of g with every package matching path removed
+// and every import of it dropped, along with the packages that imported it
+// directly and so would no longer build.
+func SimulateRemove(g *Graph, path string) (*Graph, []BrokenPackage) {
+	after := g.Clone()
+
+	for _, p := range slices.Clone(after.Order) {
+		if MatchesPath(p, path) {
+			after.Remove(p)
+		}
+	}
+
+	var broken []BrokenPackage
+	for _, p := range after.Order {
+		pkg := after.Pkgs[p]
+
+		var matched []string
+		pkg.Deps = slices.DeleteFunc(pkg.Deps, func(d string) bool {
+			if MatchesPath(d, path) {
+				matched = append(matched, d)
+				return true
+			}
+			return false
+		})
+
+		if len(matched) != 0 {
+			broken = append(broken, BrokenPackage{Pkg: p, Imports: matched})
+		}
+	}
+
+	return after, broken
+}
+
+// ResolveTarget expands a module-relative move target using the module of the
+// package being moved.
+func ResolveTarget(g *Graph, from, to string) string {
+	for _, p := range g.Order {
+		if !MatchesPath(p, from) {
+			continue
+		}
+		m := FindModule(g.Pkgs[p].Path)
+		if m != nil && !MatchesPath(to, m.Path) {
+			return m.Path + "/" + strings.TrimPrefix(to, "./")
+		}
+		break
+	}
+	return to
+}
+
+// SimulateMove returns a copy of g with every package matching from moved
+// below to, with the imports of every package rewritten to match. The returned
+// map holds the old and new import path of every moved package.
+func SimulateMove(g *Graph, from, to string) (*Graph, map[string]string) {
+	moved := make(map[string]string)
+	for _, p := range g.Order {
+		if MatchesPath(p, from) {
+			moved[p] = to + strings.TrimPrefix(p, from)
+		}
+	}
+
+	var pkgs []Package
+	for _, p := range g.Order {
+		pkg := *g.Pkgs[p]
+		pkg.ImportPath = Renamed(moved, p)
+		pkg.Deps = slices.Clone(pkg.Deps)
+		for i, d := range pkg.Deps {
+			pkg.Deps[i] = Renamed(moved, d)
+		}
+		pkgs = append(pkgs, pkg)
+	}
+
+	return NewGraph(pkgs), moved
+}
+
+// SimulateMerge returns a copy of g with every package in paths folded into
+// the first one. It reports false if fewer than two of paths were scanned.
+func SimulateMerge(g *Graph, paths []string) (*Graph, bool) {
+	var found int
+	for _, p := range paths {
+		if g.IsInternal(p) {
+			found++
+		}
+	}
+	if found < 2 || !g.IsInternal(paths[0]) {
+		return nil, false
+	}
+
+	moved := make(map[string]string)
+	for _, p := range paths[1:] {
+		moved[p] = paths[0]
+	}
+
+	var pkgs []Package
+	for _, p := range g.Order {
+		if _, ok := moved[p]; ok {
+			continue
+		}
+
+		pkg := *g.Pkgs[p]
+		var deps []string
+		if p == paths[0] {
+			deps = slices.Clone(pkg.Deps)
+			for _, m := range paths[1:] {
+				if g.IsInternal(m) {
+					deps = append(deps, g.Pkgs[m].Deps...)
+				}
+			}
+		} else {
+			deps = pkg.Deps
+		}
+
+		pkg.Deps = nil
+		for _, d := range deps {
+			d = Renamed(moved, d)
+			if (p == paths[0] && d == p) || slices.Contains(pkg.Deps, d) {
+				continue
+			}
+			pkg.Deps = append(pkg.Deps, d)
+		}
+		pkgs = append(pkgs, pkg)
+	}
+
+	return NewGraph(pkgs), true
+}
+
+func Renamed(moved map[string]string, path string) string {
+	if n, ok := moved[path]; ok {
+		return n
+	}
+	return path
+}
+
+func PrintMetricsDiff(w io.Writer, before, after Metrics) {
+	fmt.Fprintf(w, "%-16s%10s%10s\n", "metric", "before", "after")
+	row := func(name string, b, a any) {
+		fmt.Fprintf(w, "%-16s%10v%10v\n", name, b, a)
+	}
+	row("packages", before.Packages, after.Packages)
+	row("internal edges", before.InternalEdges, after.InternalEdges)
+	row("external deps", before.ExternalDeps, after.ExternalDeps)
+	row("avg fan-out", fmt.Sprintf("%.2f", before.AvgFanOut), fmt.Sprintf("%.2f", after.AvgFanOut))
+	row("max fan-in", before.MaxFanIn, after.MaxFanIn)
+	row("max depth", before.MaxDepth, after.MaxDepth)
+	row("cycles", before.Cycles, after.Cycles)
+}
@@ -0,0 +1,34 @@
+package wuw
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestSimulateMoveMerge merges into a package that only exists once -move
+// has run, so the report on it has to be made against the moved graph.
+func TestSimulateMoveMerge(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out.txt")
+	t.Chdir(filepath.Join("testdata", "fixture"))
+
+	RunSimulate(append([]string{"-o", out, "-move", "app=e", "-merge", "e,web"}, fixtureDirs...))
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(data)
+	for _, want := range []string{
+		"simulating merge of example.com/fixture/web into example.com/fixture/e\n",
+		// the cycle between store and web was there before the merge
+		"cycles created (0):\n",
+		"\tgithub.com/lib/pq\n",
+		"\tnet/http (new)\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output lacks %q:\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package wuw
+
+import (
+	"go/parser"
+	"go/token"
+	"strconv"
+)
+
+// ImportSite is a single import statement of a file.
+type ImportSite struct {
+	Path string
+	File string
+	Line int
+	Col  int
+	// Alias is the local name given to the import, if any, including "_"
+	// and ".".
+	Alias string
+}
+
+// ImportSites returns every import statement in the files of pkg, in file
+// order.
+func ImportSites(pkg *Package) []ImportSite {
+	var sites []ImportSite
+	fset := token.NewFileSet()
+	for _, name := range pkg.Files {
+		sites = append(sites, FileImportSites(fset, name, nil)...)
+	}
+	return sites
+}
+
+// FileImportSites returns the import statements of the file name, read from
+// src if it isn't nil, as with parser.ParseFile.
+func FileImportSites(fset *token.FileSet, name string, src any) []ImportSite {
+	f, err := parser.ParseFile(fset, name, src, parser.ImportsOnly)
+	if err != nil {
+		return nil
+	}
+
+	var sites []ImportSite
+	for _, imp := range f.Imports {
+		p, err := strconv.Unquote(imp.Path.Value)
+		if err != nil {
+			continue
+		}
+		pos := fset.Position(imp.Pos())
+		site := ImportSite{Path: p, File: name, Line: pos.Line, Col: pos.Column}
+		if imp.Name != nil {
+			site.Alias = imp.Name.Name
+		}
+		sites = append(sites, site)
+	}
+	return sites
+}
@@ -0,0 +1,82 @@
+package wuw
+
+import (
+	"database/sql"
+	"os"
+	"slices"
+	"strings"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqliteSchema creates the tables of the SQLite export. Packages are keyed
+// by import path, which files and edges refer to, so that other project
+// metadata can be joined on it too.
+const sqliteSchema = `
+CREATE TABLE packages (
+	path TEXT PRIMARY KEY,
+	module TEXT NOT NULL,
+	name TEXT NOT NULL,
+	dir TEXT NOT NULL,
+	files INTEGER NOT NULL,
+	imports INTEGER NOT NULL,
+	importers INTEGER NOT NULL,
+	test_support INTEGER NOT NULL,
+	experimental INTEGER NOT NULL
+);
+CREATE TABLE files (
+	package TEXT NOT NULL REFERENCES packages(path),
+	path TEXT NOT NULL,
+	generated INTEGER NOT NULL
+);
+CREATE TABLE edges (
+	importer TEXT NOT NULL REFERENCES packages(path),
+	imported TEXT NOT NULL,
+	category TEXT NOT NULL,
+	qualifiers TEXT NOT NULL
+);
+CREATE INDEX edges_imported ON edges(imported);
+`
+
+// WriteSQLiteExport writes g to a new SQLite database at path, replacing
+// any file already there, with a packages table of the scanned packages, a
+// files table of their Go files and an edges table of every import.
+// Qualifiers are comma-separated, or empty for imports by every file.
+func WriteSQLiteExport(path string, g *Graph) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		return err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, r := range PackageRows(g) {
+		pkg := g.Pkgs[r.Path]
+		if _, err := tx.Exec("INSERT INTO packages VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			r.Path, r.Module, r.Name, r.Dir, r.Files, r.Imports, r.Importers, pkg.TestSupport, pkg.Experimental); err != nil {
+			return err
+		}
+		for _, f := range pkg.Files {
+			if _, err := tx.Exec("INSERT INTO files VALUES (?, ?, ?)", r.Path, f, slices.Contains(pkg.Generated, f)); err != nil {
+				return err
+			}
+		}
+		for _, d := range pkg.Deps {
+			if _, err := tx.Exec("INSERT INTO edges VALUES (?, ?, ?, ?)", r.Path, d, g.Category(d), strings.Join(pkg.Qualifiers[d], ",")); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
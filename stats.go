@@ -0,0 +1,151 @@
+package wuw
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+func RunStats(args []string) {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintln(w, "'wuw stats' prints aggregate metrics of the import graph, and the highest and average value of the derived metrics of .wuw.yaml, which are expressions over the built-in metrics of packages:")
+		for _, m := range packageMetrics {
+			fmt.Fprintf(w, "  %s\t%s\n", m.Name, m.Description)
+		}
+		fmt.Fprintf(w, "Usage: %s stats [-opts] [dirs...]\nopts:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+
+	noStdVar := fs.Bool("no-std", false, "Exclude stdlib packages (including golang.org/x/)")
+	testSupportVar := TestSupportFlag(fs)
+	histogramVar := fs.Bool("histogram", false, "Also print histograms of packages by dependency count and by fan-in")
+	sortVar := fs.String("sort", "", "Also list the packages with the highest value of this `metric`, built-in or derived")
+	topVar := fs.Int("top", 10, "With -sort, how many packages to list, or 0 for all")
+	outVar := OutputFlag(fs)
+
+	ParseFlags(fs, args)
+
+	c, err := LoadConfig(DefaultConfig)
+	if err != nil && !os.IsNotExist(err) {
+		Fatal(err)
+	}
+	derived := c.DerivedMetrics()
+	if names := MetricNames(derived); *sortVar != "" && !slices.Contains(names, *sortVar) {
+		Fatal(fmt.Errorf("unknown metric %s, want one of %s", *sortVar, strings.Join(names, ", ")))
+	}
+
+	dirs := ReadArgs(fs.Args(), fs.Usage)
+	pkgs, errs := ScanDirs(dirs, *noStdVar)
+
+	PrintErrors(errs)
+
+	pkgs, err = ApplyTestSupport(pkgs, *testSupportVar)
+	if err != nil {
+		Fatal(err)
+	}
+
+	g := NewGraph(pkgs)
+
+	w := OpenOutput(*outVar)
+	defer w.Close()
+
+	PrintMetrics(w, g.Metrics())
+
+	if len(derived) != 0 || *sortVar != "" {
+		values := ComputeMetrics(g, derived)
+		if len(derived) != 0 {
+			fmt.Fprintln(w)
+			WriteDerivedMetrics(w, g, derived, values)
+		}
+		if *sortVar != "" {
+			fmt.Fprintln(w)
+			fmt.Fprintf(w, "packages by %s:\n", *sortVar)
+			for _, p := range TopPackages(g, values, *sortVar, *topVar) {
+				fmt.Fprintf(w, "%10s  %s\n", FormatMetric(values[p][*sortVar]), p)
+			}
+		}
+	}
+
+	if *histogramVar {
+		var deps, fanIn []int
+		for _, p := range g.Order {
+			deps = append(deps, len(g.Pkgs[p].Deps))
+			fanIn = append(fanIn, len(g.Importers(p)))
+		}
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "packages by dependency count:")
+		WriteHistogram(w, deps)
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "packages by fan-in:")
+		WriteHistogram(w, fanIn)
+	}
+}
+
+func PrintMetrics(w io.Writer, m Metrics) {
+	row := func(name string, v any) {
+		fmt.Fprintf(w, "%-16s%10v\n", name, v)
+	}
+	row("packages", m.Packages)
+	row("internal edges", m.InternalEdges)
+	row("external deps", m.ExternalDeps)
+	row("avg fan-out", fmt.Sprintf("%.2f", m.AvgFanOut))
+	row("max fan-in", m.MaxFanIn)
+	row("max depth", m.MaxDepth)
+	row("cycles", m.Cycles)
+}
+
+// histogramWidth is how many columns the longest bar of a histogram takes.
+const histogramWidth = 40
+
+// WriteHistogram writes an ASCII histogram of values, bucketed by powers of two
+// (0, 1, 2-3, 4-7, ...) so that a few heavily coupled packages don't squash
+// everything else into the first bucket.
+func WriteHistogram(w io.Writer, values []int) {
+	var counts []int
+	for _, v := range values {
+		b := bucket(v)
+		for len(counts) <= b {
+			counts = append(counts, 0)
+		}
+		counts[b]++
+	}
+
+	most := 0
+	for _, c := range counts {
+		most = max(most, c)
+	}
+
+	for b, c := range counts {
+		lo, hi := bucketRange(b)
+		label := fmt.Sprint(lo)
+		if hi != lo {
+			label = fmt.Sprintf("%d-%d", lo, hi)
+		}
+		bar := 0
+		if most != 0 {
+			bar = (c*histogramWidth + most - 1) / most
+		}
+		fmt.Fprintf(w, "%8s | %-*s %d\n", label, histogramWidth, strings.Repeat("#", bar), c)
+	}
+}
+
+func bucket(v int) int {
+	b := 0
+	for v > 0 {
+		b++
+		v >>= 1
+	}
+	return b
+}
+
+func bucketRange(b int) (lo, hi int) {
+	if b == 0 {
+		return 0, 0
+	}
+	return 1 << (b - 1), 1<<b - 1
+}
@@ -0,0 +1,157 @@
+package wuw
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// StructurizrQuote quotes s as a Structurizr DSL string. The DSL only
+// escapes double quotes, and strings can't span lines.
+func StructurizrQuote(s string) string {
+	r := strings.NewReplacer(`"`, `\"`, "\n", " ", "\r", "")
+	return `"` + r.Replace(strings.ToValidUTF8(s, "�")) + `"`
+}
+
+// structurizrID is the identifier of the element of kind for s. Kinds keep
+// apart a module and a package of the same path, which share a namespace in
+// the DSL.
+func structurizrID(kind, s string) string {
+	return kind + strings.TrimPrefix(MermaidID(s), "n")
+}
+
+// WriteStructurizr writes g as a Structurizr DSL workspace, to bootstrap a
+// C4 model from. Every scanned module is a software system with its
+// packages as containers, and every external module a software system
+// tagged External. With groups, the packages of a group become components
+// of a container named after it. Imports are relationships, those of
+// external packages going to the system of their module. The workspace has
+// a landscape view, a container view of each module and a component view
+// of each group. The metadata of info goes in comments.
+func WriteStructurizr(w io.Writer, g *Graph, groups *Groups, info *DiagramInfo) {
+	if info != nil {
+		for _, l := range info.Lines() {
+			fmt.Fprintf(w, "// %s\n", l)
+		}
+	}
+
+	var modules []string
+	moduleOf := make(map[string]string)
+	for _, p := range g.Order {
+		m := "(no module)"
+		if mod := FindModule(g.Pkgs[p].Path); mod != nil {
+			m = mod.Path
+		}
+		moduleOf[p] = m
+		if !slices.Contains(modules, m) {
+			modules = append(modules, m)
+		}
+	}
+	slices.Sort(modules)
+
+	element := func(indent, kind, p string) {
+		pkg := g.Pkgs[p]
+		tags := []string{"Go package"}
+		if len(pkg.Generated) != 0 && len(pkg.Generated) == len(pkg.Files) {
+			tags = append(tags, "Generated")
+		}
+		if pkg.TestSupport {
+			tags = append(tags, "Test support")
+		}
+		if pkg.Experimental {
+			tags = append(tags, "Experimental")
+		}
+		fmt.Fprintf(w, "%s%s = %s %s %s \"Go\" %s\n", indent, structurizrID("p", p), kind, StructurizrQuote(LayoutLabel(g, p)), StructurizrQuote(p), StructurizrQuote(strings.Join(tags, ",")))
+	}
+
+	var groupIDs []string
+	fmt.Fprintln(w, "workspace {")
+	fmt.Fprintln(w, "    model {")
+	for _, m := range modules {
+		fmt.Fprintf(w, "        %s = softwareSystem %s {\n", structurizrID("m", m), StructurizrQuote(m))
+		if groups != nil {
+			for _, name := range groups.Names() {
+				var pkgs []string
+				for _, p := range g.Order {
+					if moduleOf[p] == m && groups.Of(p) == name {
+						pkgs = append(pkgs, p)
+					}
+				}
+				if len(pkgs) == 0 {
+					continue
+				}
+				id := structurizrID("g", m+":"+name)
+				groupIDs = append(groupIDs, id)
+				fmt.Fprintf(w, "            %s = container %s \"\" \"Go\" {\n", id, StructurizrQuote(name))
+				for _, p := range pkgs {
+					element("                ", "component", p)
+				}
+				fmt.Fprintln(w, "            }")
+			}
+		}
+		for _, p := range g.Order {
+			if moduleOf[p] == m && groups.Of(p) == "" {
+				element("            ", "container", p)
+			}
+		}
+		fmt.Fprintln(w, "        }")
+	}
+
+	externals := ExternalModules(g)
+	externalOf := make(map[string]string)
+	for _, m := range externals {
+		fmt.Fprintf(w, "        %s = softwareSystem %s \"External module\" \"External\"\n", structurizrID("x", m.Path), StructurizrQuote(m.Path))
+		for _, p := range m.Packages {
+			externalOf[p] = m.Path
+		}
+	}
+
+	fmt.Fprintln(w)
+	for _, p := range g.Order {
+		var used []string
+		for _, d := range g.Pkgs[p].Deps {
+			switch {
+			case d == p:
+			case g.IsInternal(d):
+				fmt.Fprintf(w, "        %s -> %s \"imports\"\n", structurizrID("p", p), structurizrID("p", d))
+			case externalOf[d] != "" && !slices.Contains(used, externalOf[d]):
+				used = append(used, externalOf[d])
+				fmt.Fprintf(w, "        %s -> %s \"imports\"\n", structurizrID("p", p), structurizrID("x", externalOf[d]))
+			}
+		}
+	}
+	fmt.Fprintln(w, "    }")
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, "    views {")
+	fmt.Fprintln(w, "        systemLandscape {")
+	fmt.Fprintln(w, "            include *")
+	fmt.Fprintln(w, "            autoLayout")
+	fmt.Fprintln(w, "        }")
+	for _, m := range modules {
+		fmt.Fprintf(w, "        container %s {\n", structurizrID("m", m))
+		fmt.Fprintln(w, "            include *")
+		fmt.Fprintln(w, "            autoLayout")
+		fmt.Fprintln(w, "        }")
+	}
+	for _, id := range groupIDs {
+		fmt.Fprintf(w, "        component %s {\n", id)
+		fmt.Fprintln(w, "            include *")
+		fmt.Fprintln(w, "            autoLayout")
+		fmt.Fprintln(w, "        }")
+	}
+	fmt.Fprintln(w, "        styles {")
+	fmt.Fprintln(w, "            element \"External\" {")
+	fmt.Fprintln(w, "                background #999999")
+	fmt.Fprintln(w, "            }")
+	fmt.Fprintln(w, "            element \"Experimental\" {")
+	fmt.Fprintln(w, "                opacity 40")
+	fmt.Fprintln(w, "            }")
+	fmt.Fprintln(w, "            element \"Test support\" {")
+	fmt.Fprintln(w, "                border dashed")
+	fmt.Fprintln(w, "            }")
+	fmt.Fprintln(w, "        }")
+	fmt.Fprintln(w, "    }")
+	fmt.Fprintln(w, "}")
+}
@@ -0,0 +1,72 @@
+package wuw
+
+import (
+	"fmt"
+	"html"
+	"io"
+)
+
+// svgMargin is the space left around an SVG drawing, and svgLineHeight that
+// of each line of its metadata.
+const (
+	svgMargin     = 20
+	svgLineHeight = 16
+)
+
+// WriteSVG draws the LayeredLayout of g as an SVG image, without needing
+// Graphviz. Generated packages are filled green, packages only there to
+// support tests dashed and experimental ones greyed out. The metadata of info
+// goes below the graph.
+func WriteSVG(w io.Writer, g *Graph, groups *Groups, info *DiagramInfo) {
+	l := LayeredLayout(g, groups)
+	q := html.EscapeString
+
+	var lines []string
+	if info != nil {
+		lines = info.Lines()
+	}
+	width := l.Width + 2*svgMargin
+	height := l.Height + 2*svgMargin + len(lines)*svgLineHeight
+
+	fmt.Fprintf(w, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\" font-family=\"sans-serif\" font-size=\"11\">\n", width, height, width, height)
+	fmt.Fprintln(w, `  <defs><marker id="arrow" viewBox="0 0 10 10" refX="10" refY="5" markerWidth="6" markerHeight="6" orient="auto"><path d="M0,0L10,5L0,10z" fill="#999"/></marker></defs>`)
+	fmt.Fprintln(w, `  <rect width="100%" height="100%" fill="white"/>`)
+	fmt.Fprintf(w, "  <g transform=\"translate(%d,%d)\">\n", svgMargin, svgMargin)
+
+	for _, p := range g.Order {
+		from := l.ByPkg[p]
+		for _, d := range g.InternalDeps(p) {
+			if d == p {
+				continue
+			}
+			to := l.ByPkg[d]
+			x1, y1 := from.X+l.NodeWidth, from.Y+l.NodeHeight/2
+			x2, y2 := to.X, to.Y+l.NodeHeight/2
+			mid := (x1 + x2) / 2
+			fmt.Fprintf(w, "    <path d=\"M%d,%d C%d,%d %d,%d %d,%d\" fill=\"none\" stroke=\"#999\" marker-end=\"url(#arrow)\"/>\n", x1, y1, mid, y1, mid, y2, x2, y2)
+		}
+	}
+
+	for _, n := range l.Nodes {
+		pkg := g.Pkgs[n.Pkg]
+		fill, stroke, text, dash := "#eef", "#446", "black", ""
+		if len(pkg.Generated) != 0 && len(pkg.Generated) == len(pkg.Files) {
+			fill = "#efe"
+		}
+		if pkg.TestSupport {
+			dash = ` stroke-dasharray="4 3"`
+		}
+		if pkg.Experimental {
+			stroke, text = "#999", "#999"
+		}
+		fmt.Fprintf(w, "    <g transform=\"translate(%d,%d)\"><title>%s</title>", n.X, n.Y, q(n.Pkg))
+		fmt.Fprintf(w, "<rect width=\"%d\" height=\"%d\" rx=\"4\" fill=\"%s\" stroke=\"%s\"%s/>", l.NodeWidth, l.NodeHeight, fill, stroke, dash)
+		fmt.Fprintf(w, "<text x=\"6\" y=\"%d\" fill=\"%s\">%s</text></g>\n", l.NodeHeight/2+4, text, q(n.Label))
+	}
+	fmt.Fprintln(w, "  </g>")
+
+	for i, line := range lines {
+		fmt.Fprintf(w, "  <text x=\"%d\" y=\"%d\" fill=\"#666\">%s</text>\n", svgMargin, l.Height+2*svgMargin+i*svgLineHeight, q(line))
+	}
+	fmt.Fprintln(w, "</svg>")
+}
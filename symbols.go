@@ -0,0 +1,201 @@
+package wuw
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type SymbolKind int
+
+const (
+	SymbolUnknown SymbolKind = iota
+	SymbolType
+	SymbolConst
+	SymbolVar
+	SymbolFunc
+)
+
+func (k SymbolKind) String() string {
+	switch k {
+	case SymbolType:
+		return "type"
+	case SymbolConst:
+		return "const"
+	case SymbolVar:
+		return "var"
+	case SymbolFunc:
+		return "func"
+	}
+	return "unknown"
+}
+
+// symbolCache holds the exported top-level declarations of each parsed
+// package, keyed by import path.
+var symbolCache = make(map[string]map[string]SymbolKind)
+
+// PackageSymbols returns the kind of every exported top-level declaration in
+// the package.
+func PackageSymbols(pkg *Package) map[string]SymbolKind {
+	if syms, ok := symbolCache[pkg.ImportPath]; ok {
+		return syms
+	}
+
+	syms := make(map[string]SymbolKind)
+	fset := token.NewFileSet()
+	for _, name := range pkg.Files {
+		f, err := parser.ParseFile(fset, name, nil, parser.SkipObjectResolution)
+		if err != nil {
+			continue
+		}
+
+		for _, decl := range f.Decls {
+			switch decl := decl.(type) {
+			case *ast.FuncDecl:
+				if decl.Recv == nil && decl.Name.IsExported() {
+					syms[decl.Name.Name] = SymbolFunc
+				}
+			case *ast.GenDecl:
+				for _, spec := range decl.Specs {
+					switch spec := spec.(type) {
+					case *ast.TypeSpec:
+						if spec.Name.IsExported() {
+							syms[spec.Name.Name] = SymbolType
+						}
+					case *ast.ValueSpec:
+						kind := SymbolVar
+						if decl.Tok == token.CONST {
+							kind = SymbolConst
+						}
+						for _, n := range spec.Names {
+							if n.IsExported() {
+								syms[n.Name] = kind
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+
+	symbolCache[pkg.ImportPath] = syms
+	return syms
+}
+
+// SymbolUsage counts how many times the files of from refer to each exported
+// symbol of the package imported as to.
+func SymbolUsage(g *Graph, from *Package, to string) map[string]int {
+	uses := make(map[string]int)
+	fset := token.NewFileSet()
+
+	for _, name := range from.Files {
+		f, err := parser.ParseFile(fset, name, nil, 0)
+		if err != nil {
+			continue
+		}
+
+		var local string
+		for _, imp := range f.Imports {
+			p, err := strconv.Unquote(imp.Path.Value)
+			if err != nil || p != to {
+				continue
+			}
+			if imp.Name != nil {
+				local = imp.Name.Name
+			} else if pkg, ok := g.Pkgs[to]; ok {
+				local = pkg.Name
+			} else {
+				local = ImportName(to)
+			}
+		}
+		if local == "" || local == "_" || local == "." {
+			continue
+		}
+
+		ast.Inspect(f, func(n ast.Node) bool {
+			sel, ok := n.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+			// identifiers that resolve to a local declaration shadow the
+			// import
+			if x, ok := sel.X.(*ast.Ident); ok && x.Name == local && x.Obj == nil {
+				uses[sel.Sel.Name]++
+			}
+			return true
+		})
+	}
+
+	return uses
+}
+
+type EdgeKind string
+
+const (
+	EdgeTypes     EdgeKind = "types-only"
+	EdgeConsts    EdgeKind = "constants-only"
+	EdgeVars      EdgeKind = "variables-only"
+	EdgeFunctions EdgeKind = "functions"
+	EdgeMixed     EdgeKind = "mixed"
+	EdgeUnknown   EdgeKind = "unknown"
+)
+
+type EdgeSymbols struct {
+	Edge
+	Kind EdgeKind
+	Uses map[string]int
+	// Symbols holds the used symbols grouped by their kind.
+	Symbols map[SymbolKind][]string
+}
+
+// ClassifyEdge works out which kinds of symbols the edge from -> to relies on.
+func ClassifyEdge(g *Graph, from, to string) EdgeSymbols {
+	es := EdgeSymbols{
+		Edge:    Edge{From: from, To: to},
+		Uses:    SymbolUsage(g, g.Pkgs[from], to),
+		Symbols: make(map[SymbolKind][]string),
+	}
+
+	var syms map[string]SymbolKind
+	if pkg, ok := g.Pkgs[to]; ok {
+		syms = PackageSymbols(pkg)
+	}
+
+	for s := range es.Uses {
+		k := syms[s]
+		es.Symbols[k] = append(es.Symbols[k], s)
+	}
+	for _, v := range es.Symbols {
+		slices.Sort(v)
+	}
+
+	switch {
+	case len(es.Symbols) == 0 || es.Symbols[SymbolUnknown] != nil:
+		es.Kind = EdgeUnknown
+	case len(es.Symbols) > 1:
+		es.Kind = EdgeMixed
+	case es.Symbols[SymbolType] != nil:
+		es.Kind = EdgeTypes
+	case es.Symbols[SymbolConst] != nil:
+		es.Kind = EdgeConsts
+	case es.Symbols[SymbolVar] != nil:
+		es.Kind = EdgeVars
+	default:
+		es.Kind = EdgeFunctions
+	}
+
+	return es
+}
+
+func (es EdgeSymbols) String() string {
+	var parts []string
+	for _, k := range []SymbolKind{SymbolType, SymbolConst, SymbolVar, SymbolFunc, SymbolUnknown} {
+		if len(es.Symbols[k]) != 0 {
+			parts = append(parts, k.String()+" "+strings.Join(es.Symbols[k], ", "))
+		}
+	}
+	return strings.Join(parts, "; ")
+}
@@ -0,0 +1,92 @@
+package wuw
+
+import (
+	"io"
+	"strings"
+	"text/template"
+)
+
+// TemplateReporter executes a text/template for each package, followed by a
+// newline, as 'go list -f' does.
+type TemplateReporter struct {
+	Tmpl *template.Template
+}
+
+// TemplatePackage is what a -format template is executed with.
+type TemplatePackage struct {
+	Name       string
+	Path       string
+	ImportPath string
+	// Category is that of the package itself, so internal unless it was
+	// only scanned to be imported.
+	Category     string
+	Deps         []TemplateDep
+	Importers    []string
+	Files        []string
+	Group        string
+	Generated    bool
+	TestSupport  bool
+	Experimental bool
+	Cgo          bool
+}
+
+// TemplateDep is an import of a package, which prints as its path.
+type TemplateDep struct {
+	Path       string
+	Category   string
+	Qualifiers []string
+	Aliases    []string
+}
+
+func (d TemplateDep) String() string {
+	return d.Path
+}
+
+var templateFuncs = template.FuncMap{
+	"join": func(elems any, sep string) string {
+		var s []string
+		switch e := elems.(type) {
+		case []string:
+			s = e
+		case []TemplateDep:
+			for _, d := range e {
+				s = append(s, d.Path)
+			}
+		}
+		return strings.Join(s, sep)
+	},
+}
+
+// ParseFormatTemplate parses a -format template, which can use the join
+// function on lists of strings or deps.
+func ParseFormatTemplate(text string) (*template.Template, error) {
+	return template.New("format").Funcs(templateFuncs).Parse(text)
+}
+
+func (t TemplateReporter) Report(w io.Writer, r *Result) error {
+	for _, p := range r.Pkgs {
+		data := TemplatePackage{
+			Name:         p.Name,
+			Path:         p.Path,
+			ImportPath:   p.ImportPath,
+			Category:     r.Graph.Category(p.ImportPath),
+			Importers:    r.Graph.Importers(p.ImportPath),
+			Files:        p.Files,
+			Group:        r.Groups.Of(p.ImportPath),
+			Generated:    len(p.Generated) != 0 && len(p.Generated) == len(p.Files),
+			TestSupport:  p.TestSupport,
+			Experimental: p.Experimental,
+			Cgo:          p.Cgo,
+		}
+		for _, d := range p.Deps {
+			data.Deps = append(data.Deps, TemplateDep{Path: d, Category: r.Graph.Category(d), Qualifiers: p.Qualifiers[d], Aliases: p.Aliases[d]})
+		}
+		if err := t.Tmpl.Execute(w, data); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, "\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
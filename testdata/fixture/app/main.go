@@ -0,0 +1,14 @@
+package main
+
+import (
+	"fmt"
+
+	_ "github.com/lib/pq"
+
+	"example.com/fixture/store"
+	"example.com/fixture/web"
+)
+
+func main() {
+	fmt.Println(store.Open(), web.Serve())
+}
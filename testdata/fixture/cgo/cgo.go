@@ -0,0 +1,10 @@
+package cgo
+
+// #include <stdlib.h>
+import "C"
+
+import "unsafe"
+
+func Free(p unsafe.Pointer) {
+	C.free(p)
+}
@@ -0,0 +1,5 @@
+package gen
+
+import "errors"
+
+var ErrEmpty = errors.New("empty")
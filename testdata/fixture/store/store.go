@@ -0,0 +1,12 @@
+package store
+
+import (
+	"database/sql"
+
+	"example.com/fixture/web"
+)
+
+func Open() *sql.DB {
+	web.Serve()
+	return nil
+}
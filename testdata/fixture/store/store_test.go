@@ -0,0 +1,12 @@
+package store_test
+
+import (
+	"testing"
+
+	"example.com/fixture/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpen(t *testing.T) {
+	assert.Nil(t, store.Open())
+}
@@ -0,0 +1,12 @@
+package web
+
+import (
+	"net/http"
+
+	"example.com/fixture/store"
+)
+
+func Serve() error {
+	store.Open()
+	return http.ListenAndServe(":8080", nil)
+}
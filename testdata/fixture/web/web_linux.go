@@ -0,0 +1,7 @@
+//go:build linux
+
+package web
+
+import "golang.org/x/sys/unix"
+
+var pid = unix.Getpid
@@ -0,0 +1,5 @@
+package cafe
+
+import "example.com/uni/日本/データ"
+
+var Menu = data.Items
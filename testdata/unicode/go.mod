@@ -0,0 +1,3 @@
+module example.com/uni
+
+go 1.24
@@ -0,0 +1,87 @@
+package wuw
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+)
+
+// DefaultTestSupport are the names conventionally given to packages that
+// only exist to support tests. Being imported by every test, they otherwise
+// look like the most depended on packages of a project.
+var DefaultTestSupport = []string{
+	"testutil", "testutils", "testhelper", "testhelpers", "testsupport", "testfixtures",
+	"mock", "mocks", "fake", "fakes", "stub", "stubs",
+}
+
+// TestSupportFlag registers the -test-support flag on fs.
+func TestSupportFlag(fs *flag.FlagSet) *string {
+	return fs.String("test-support", "show", "What to do with test helper packages such as .../testutil or .../mocks: show, dim (mark them) or exclude")
+}
+
+// TestSupport reports which packages only exist to support tests, by name
+// or by matching one of the test_support regexes of the config.
+type TestSupport struct {
+	patterns []*regexp.Regexp
+}
+
+// LoadTestSupport adds the test_support patterns of the config at path, if
+// there is one, to the default names.
+func LoadTestSupport(path string) (*TestSupport, error) {
+	ts := &TestSupport{}
+	c, err := LoadConfig(path)
+	if os.IsNotExist(err) {
+		return ts, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	for _, p := range c.TestSupport {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("%s: test_support: %w", path, err)
+		}
+		ts.patterns = append(ts.patterns, re)
+	}
+	return ts, nil
+}
+
+func (ts *TestSupport) Is(importPath string) bool {
+	if slices.Contains(DefaultTestSupport, path.Base(importPath)) {
+		return true
+	}
+	return slices.ContainsFunc(ts.patterns, func(re *regexp.Regexp) bool { return re.MatchString(importPath) })
+}
+
+// ApplyTestSupport marks the test support packages of pkgs, or drops them
+// and the imports of them when mode is exclude.
+func ApplyTestSupport(pkgs []Package, mode string) ([]Package, error) {
+	switch mode {
+	case "show":
+		return pkgs, nil
+	case "dim", "exclude":
+	default:
+		return nil, fmt.Errorf("unknown -test-support %q, want show, dim or exclude", mode)
+	}
+
+	ts, err := LoadTestSupport(DefaultConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if mode == "dim" {
+		for i := range pkgs {
+			pkgs[i].TestSupport = ts.Is(pkgs[i].ImportPath)
+		}
+		return pkgs, nil
+	}
+
+	pkgs = slices.DeleteFunc(pkgs, func(p Package) bool { return ts.Is(p.ImportPath) })
+	for i := range pkgs {
+		pkgs[i].Deps = slices.DeleteFunc(pkgs[i].Deps, ts.Is)
+	}
+	return pkgs, nil
+}
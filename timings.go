@@ -0,0 +1,49 @@
+package wuw
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// The phases of a run that -timings reports on, in the order they happen.
+const (
+	PhaseWalk     = "walk"
+	PhaseParse    = "parse"
+	PhaseClassify = "classify"
+	PhaseGraph    = "graph"
+	PhaseRender   = "render"
+)
+
+var phases = []string{PhaseWalk, PhaseParse, PhaseClassify, PhaseGraph, PhaseRender}
+
+// Timings adds up the wall time spent in each phase. A nil *Timings
+// records nothing, so callers don't need to check whether -timings is set.
+type Timings struct {
+	spent map[string]time.Duration
+}
+
+func NewTimings() *Timings {
+	return &Timings{spent: make(map[string]time.Duration)}
+}
+
+// Start starts timing phase, returning the func that stops it. A phase can
+// be started any number of times, and its times add up.
+func (t *Timings) Start(phase string) func() {
+	if t == nil {
+		return func() {}
+	}
+	start := time.Now()
+	return func() {
+		t.spent[phase] += time.Since(start)
+	}
+}
+
+func (t *Timings) Write(w io.Writer) {
+	var total time.Duration
+	for _, p := range phases {
+		fmt.Fprintf(w, "%-12s%12s\n", p, t.spent[p].Round(time.Microsecond))
+		total += t.spent[p]
+	}
+	fmt.Fprintf(w, "%-12s%12s\n", "total", total.Round(time.Microsecond))
+}
@@ -0,0 +1,71 @@
+package wuw
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+)
+
+// DefaultToolchainModules are the packages tied to a particular toolchain or
+// its internals, or to APIs that may change or go away, so that a Go release
+// can break them. The toolchain list of the config file adds to them.
+var DefaultToolchainModules = []string{
+	"golang.org/x/exp",
+	"plugin",
+	"github.com/modern-go/reflect2",
+	"github.com/goccy/go-reflect",
+	"github.com/bytedance/sonic",
+	"github.com/petermattis/goid",
+	"go4.org/unsafe/assume-no-moving-gc",
+}
+
+func (c *Config) ToolchainModules() []string {
+	return append(slices.Clone(DefaultToolchainModules), c.Toolchain...)
+}
+
+type ToolchainImport struct {
+	Edge
+	Module string
+	// Allowed is set when the importer matches one of the toolchain_allow
+	// patterns of the config.
+	Allowed bool
+}
+
+func (t ToolchainImport) String() string {
+	s := fmt.Sprintf("%s -> %s: toolchain-specific import of %s", t.From, t.To, t.Module)
+	if t.Allowed {
+		s += " (allowed)"
+	}
+	return s
+}
+
+// ToolchainImports finds the imports of g of one of the toolchain-specific
+// modules, allowed or not. Experimental packages are left out.
+func (c *Config) ToolchainImports(g *Graph) ([]ToolchainImport, error) {
+	var allow []*regexp.Regexp
+	for _, a := range c.ToolchainAllow {
+		re, err := regexp.Compile(a)
+		if err != nil {
+			return nil, fmt.Errorf("toolchain_allow: %w", err)
+		}
+		allow = append(allow, re)
+	}
+
+	modules := c.ToolchainModules()
+	var ret []ToolchainImport
+	for _, p := range g.Order {
+		if g.Pkgs[p].Experimental {
+			continue
+		}
+		allowed := slices.ContainsFunc(allow, func(re *regexp.Regexp) bool { return re.MatchString(p) })
+		for _, d := range g.Pkgs[p].Deps {
+			for _, m := range modules {
+				if MatchesPath(d, m) {
+					ret = append(ret, ToolchainImport{Edge: Edge{From: p, To: d}, Module: m, Allowed: allowed})
+					break
+				}
+			}
+		}
+	}
+	return ret, nil
+}
@@ -0,0 +1,118 @@
+package wuw
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// treeDir is a dir of the tree output, holding the package found in it, if
+// any, and the dirs below it.
+type treeDir struct {
+	name string
+	pkg  *Package
+	dirs map[string]*treeDir
+}
+
+func (d *treeDir) child(name string) *treeDir {
+	c, ok := d.dirs[name]
+	if !ok {
+		c = &treeDir{name: name, dirs: make(map[string]*treeDir)}
+		d.dirs[name] = c
+	}
+	return c
+}
+
+// WriteTree draws the packages of pkgs nested under their dirs, and their
+// deps under them, with box-drawing characters as tree(1) does. Dirs holding
+// nothing but a single dir are folded into it.
+func WriteTree(w io.Writer, pkgs []Package) {
+	root := &treeDir{name: ".", dirs: make(map[string]*treeDir)}
+	for i := range pkgs {
+		p := &pkgs[i]
+		d := root
+		if dir := filepath.ToSlash(filepath.Clean(p.Path)); dir != "." {
+			for i, elem := range strings.Split(dir, "/") {
+				if i == 0 && elem == "" {
+					elem = "/"
+				}
+				d = d.child(elem)
+			}
+		}
+		d.pkg = p
+	}
+
+	// a scan of a single dir elsewhere is drawn from there
+	for root.pkg == nil && len(root.dirs) == 1 {
+		for _, d := range root.dirs {
+			if root.name != "." {
+				d.name = filepath.Join(root.name, d.name)
+			}
+			root = d
+		}
+	}
+
+	fmt.Fprintln(w, treeLabel(root.name, root.pkg))
+	writeTreeEntries(w, root, "")
+}
+
+// treeLabel returns name, followed by what sets pkg apart if it isn't nil.
+func treeLabel(name string, pkg *Package) string {
+	if pkg == nil {
+		return name
+	}
+	var marks []string
+	if pkg.Name != filepath.Base(pkg.Path) {
+		marks = append(marks, "package "+pkg.Name)
+	}
+	if len(pkg.Generated) != 0 && len(pkg.Generated) == len(pkg.Files) {
+		marks = append(marks, "generated")
+	}
+	if pkg.TestSupport {
+		marks = append(marks, "test support")
+	}
+	if pkg.Experimental {
+		marks = append(marks, "experimental")
+	}
+	if len(marks) == 0 {
+		return name
+	}
+	return fmt.Sprintf("%s (%s)", name, strings.Join(marks, ", "))
+}
+
+func writeTreeEntries(w io.Writer, d *treeDir, prefix string) {
+	var deps []string
+	if d.pkg != nil {
+		deps = d.pkg.Deps
+	}
+	names := slices.Sorted(maps.Keys(d.dirs))
+
+	n := len(deps) + len(names)
+	branch := func(i int) (string, string) {
+		if i == n-1 {
+			return "└── ", "    "
+		}
+		return "├── ", "│   "
+	}
+
+	for i, dep := range deps {
+		b, _ := branch(i)
+		fmt.Fprintf(w, "%s%s→ %s\n", prefix, b, dep)
+	}
+	for i, name := range names {
+		c := d.dirs[name]
+		label := c.name
+		for c.pkg == nil && len(c.dirs) == 1 {
+			for _, only := range c.dirs {
+				label += "/" + only.name
+				c = only
+			}
+		}
+		b, indent := branch(len(deps) + i)
+		fmt.Fprintf(w, "%s%s%s\n", prefix, b, treeLabel(label, c.pkg))
+		writeTreeEntries(w, c, prefix+indent)
+	}
+}
@@ -0,0 +1,103 @@
+package wuw
+
+import (
+	"bytes"
+	"cmp"
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// ExitTruncated is the exit code of runs whose output -max-output-lines or
+// -max-nodes cut short, for CI to tell apart from both success and failure.
+const ExitTruncated = 3
+
+// MaxOutputFlags registers the -max-output-lines and -max-nodes flags on fs.
+func MaxOutputFlags(fs *flag.FlagSet) (lines, nodes *int) {
+	lines = fs.Int("max-output-lines", 0, "Stop writing output after `N` lines, ending it with how many more were omitted and exiting with status 3; 0 for no limit")
+	nodes = fs.Int("max-nodes", 0, "Only keep the `N` packages with the most importers, saying how many more were omitted in the legend of diagrams and on stderr and exiting with status 3; 0 for no limit")
+	return lines, nodes
+}
+
+// TruncateNodes keeps the max packages of pkgs with the most internal
+// importers, the ones holding the graph together, dropping the imports of
+// the others. It returns what is kept, in the order of pkgs, and how many
+// were dropped.
+func TruncateNodes(pkgs []Package, max int) ([]Package, int) {
+	if max <= 0 || len(pkgs) <= max {
+		return pkgs, 0
+	}
+	g := NewGraph(pkgs)
+	ranked := slices.Clone(g.Order)
+	slices.SortStableFunc(ranked, func(a, b string) int {
+		return cmp.Compare(len(g.Importers(b)), len(g.Importers(a)))
+	})
+	kept := ranked[:max]
+
+	sub := g.Subgraph(func(p *Package) bool { return slices.Contains(kept, p.ImportPath) })
+	var ret []Package
+	for _, p := range sub.Order {
+		ret = append(ret, *sub.Pkgs[p])
+	}
+	return ret, len(pkgs) - max
+}
+
+// lineLimitWriter passes on the first max lines written to it, and on Close
+// ends them with how many more there were.
+type lineLimitWriter struct {
+	w       io.WriteCloser
+	max     int
+	lines   int
+	omitted int
+	// unterminated is set when the last of the lines omitted lacks its
+	// newline, not being counted yet.
+	unterminated bool
+}
+
+// LimitLines returns w cut short after max lines, or w itself if max isn't
+// positive.
+func LimitLines(w io.WriteCloser, max int) io.WriteCloser {
+	if max <= 0 {
+		return w
+	}
+	return &lineLimitWriter{w: w, max: max}
+}
+
+func (l *lineLimitWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) != 0 && l.lines < l.max {
+		end := len(p)
+		if i := bytes.IndexByte(p, '\n'); i != -1 {
+			end = i + 1
+			l.lines++
+		}
+		if _, err := l.w.Write(p[:end]); err != nil {
+			return 0, err
+		}
+		p = p[end:]
+	}
+	if len(p) != 0 {
+		l.omitted += bytes.Count(p, []byte("\n"))
+		l.unterminated = p[len(p)-1] != '\n'
+	}
+	return n, nil
+}
+
+// Omitted returns how many lines were left out.
+func (l *lineLimitWriter) Omitted() int {
+	if l.unterminated {
+		return l.omitted + 1
+	}
+	return l.omitted
+}
+
+func (l *lineLimitWriter) Close() error {
+	if n := l.Omitted(); n != 0 {
+		if _, err := fmt.Fprintf(l.w, "... %d more lines omitted (-max-output-lines %d)\n", n, l.max); err != nil {
+			l.w.Close()
+			return err
+		}
+	}
+	return l.w.Close()
+}
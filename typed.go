@@ -0,0 +1,199 @@
+package wuw
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"io"
+	"slices"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// The contexts a TypedUse can be found in.
+const (
+	ContextBody      = "function body"
+	ContextSignature = "signature"
+	ContextField     = "struct field"
+	ContextInterface = "interface"
+	ContextType      = "type declaration"
+	ContextVar       = "package var"
+)
+
+// TypedUse is a use of an object of another package, as found by the type
+// checker rather than guessed from the syntax.
+type TypedUse struct {
+	Kind SymbolKind
+	Name string
+	// Recv is the receiver type of a method, or the struct type of a field.
+	Recv    string
+	Context string
+	Pos     token.Position
+}
+
+func (u TypedUse) String() string {
+	if u.Recv != "" {
+		return u.Recv + "." + u.Name
+	}
+	return u.Name
+}
+
+// LoadTyped type-checks the package in dir, along with everything it imports.
+// Type errors are left in the package's Errors, as most of it can usually
+// still be checked.
+func LoadTyped(dir string) (*packages.Package, error) {
+	cfg := &packages.Config{
+		Mode: packages.NeedName | packages.NeedTypes | packages.NeedTypesInfo | packages.NeedSyntax | packages.NeedImports | packages.NeedDeps,
+		Dir:  dir,
+	}
+	pkgs, err := packages.Load(cfg, ".")
+	if err != nil {
+		return nil, err
+	}
+	if len(pkgs) != 1 || pkgs[0].TypesInfo == nil {
+		return nil, fmt.Errorf("could not type-check the package in %s", dir)
+	}
+	return pkgs[0], nil
+}
+
+// TypedUses returns every use pkg makes of the objects of the package with
+// import path of, in the order they appear.
+func TypedUses(pkg *packages.Package, of string) []TypedUse {
+	var uses []TypedUse
+	for _, f := range pkg.Syntax {
+		var stack []ast.Node
+		ast.Inspect(f, func(n ast.Node) bool {
+			if n == nil {
+				stack = stack[:len(stack)-1]
+				return true
+			}
+			stack = append(stack, n)
+
+			id, ok := n.(*ast.Ident)
+			if !ok {
+				return true
+			}
+			obj := pkg.TypesInfo.Uses[id]
+			if obj == nil || obj.Pkg() == nil || obj.Pkg().Path() != of {
+				return true
+			}
+
+			u := TypedUse{Name: obj.Name(), Context: UseContext(stack), Pos: pkg.Fset.Position(id.Pos())}
+			switch obj := obj.(type) {
+			case *types.TypeName:
+				u.Kind = SymbolType
+			case *types.Const:
+				u.Kind = SymbolConst
+			case *types.Var:
+				u.Kind = SymbolVar
+				if obj.IsField() {
+					if sel, ok := stack[len(stack)-2].(*ast.SelectorExpr); ok {
+						u.Recv = NamedType(pkg.TypesInfo.TypeOf(sel.X))
+					}
+				}
+			case *types.Func:
+				u.Kind = SymbolFunc
+				if recv := obj.Type().(*types.Signature).Recv(); recv != nil {
+					u.Recv = NamedType(recv.Type())
+				}
+			default:
+				return true
+			}
+			uses = append(uses, u)
+			return true
+		})
+	}
+	return uses
+}
+
+// UseContext returns the context of the innermost node of stack, going by
+// the closest enclosing node that decides it.
+func UseContext(stack []ast.Node) string {
+	for i := len(stack) - 1; i >= 0; i-- {
+		switch stack[i].(type) {
+		case *ast.BlockStmt:
+			return ContextBody
+		case *ast.FuncType:
+			return ContextSignature
+		case *ast.StructType:
+			return ContextField
+		case *ast.InterfaceType:
+			return ContextInterface
+		case *ast.TypeSpec:
+			return ContextType
+		case *ast.ValueSpec:
+			return ContextVar
+		}
+	}
+	return ContextVar
+}
+
+// NamedType returns the name of the named type t, or points to.
+func NamedType(t types.Type) string {
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	if n, ok := t.(*types.Named); ok {
+		return n.Obj().Name()
+	}
+	return ""
+}
+
+// TypedSurface is CalledSurface with the functions and methods of b taken
+// from uses, so that it also catches methods called on values whose type was
+// inferred.
+func TypedSurface(b *Package, uses []TypedUse) *Surface {
+	s, funcs, methods := ParseSurface(b)
+
+	usedFuncs := make(map[string]bool)
+	usedMethods := make(map[string]map[string]bool)
+	for _, u := range uses {
+		if u.Kind != SymbolFunc {
+			continue
+		}
+		if u.Recv == "" {
+			if _, ok := funcs[u.Name]; ok {
+				usedFuncs[u.Name] = true
+			}
+			continue
+		}
+		if _, ok := methods[u.Recv][u.Name]; ok {
+			if usedMethods[u.Recv] == nil {
+				usedMethods[u.Recv] = make(map[string]bool)
+			}
+			usedMethods[u.Recv][u.Name] = true
+		}
+	}
+
+	s.Use(funcs, methods, usedFuncs, usedMethods)
+	return s
+}
+
+// WriteTypedUses lists each object in uses with the contexts it is used in
+// and how often.
+func WriteTypedUses(w io.Writer, uses []TypedUse) {
+	var names []string
+	counts := make(map[string]map[string]int)
+	kinds := make(map[string]SymbolKind)
+	for _, u := range uses {
+		name := u.String()
+		if counts[name] == nil {
+			counts[name] = make(map[string]int)
+			names = append(names, name)
+		}
+		counts[name][u.Context]++
+		kinds[name] = u.Kind
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		var contexts []string
+		for c, n := range counts[name] {
+			contexts = append(contexts, fmt.Sprintf("%s (%d)", c, n))
+		}
+		slices.Sort(contexts)
+		fmt.Fprintf(w, "\t%s %s: %s\n", kinds[name], name, strings.Join(contexts, ", "))
+	}
+}
@@ -0,0 +1,517 @@
+package wuw
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+)
+
+// VCS is the version control of the tree being scanned, as far as the
+// features comparing it with earlier versions need it. Paths are relative to
+// the dir it was opened at unless said otherwise.
+//
+// The ref of ListFiles, Files and ReadFile can be Index, for them to read the
+// contents staged for the next commit.
+type VCS interface {
+	// Root is the absolute path of the top of the working tree.
+	Root() (string, error)
+	// HooksDir is where the hooks of the repo go.
+	HooksDir() (string, error)
+	// Head is the abbreviated id of the checked out commit.
+	Head() (string, error)
+	// ListFiles returns the files directly in dir at ref.
+	ListFiles(ref, dir string) ([]string, error)
+	// Files returns every file at ref below the dir it was opened at.
+	Files(ref string) ([]string, error)
+	// ReadFile returns the contents of the file name at ref.
+	ReadFile(ref, name string) (string, error)
+	// Staged returns the files added, copied, modified or renamed in the
+	// index, relative to the root.
+	Staged() ([]string, error)
+	// GoFiles returns the Go files that are tracked, or untracked and not
+	// ignored.
+	GoFiles() ([]string, error)
+	// Changed returns the tracked files whose contents in the working tree
+	// differ from those at ref, including those added or removed since.
+	Changed(ref string) ([]string, error)
+}
+
+// Index is the ref of the index, what is staged for the next commit.
+const Index = ""
+
+// vcsOpeners are tried in turn by OpenVCS, the first to succeed winning. The
+// git binary comes first when there is one, as it keeps a stat cache of the
+// worktree where go-git hashes every file of it to tell what changed, which
+// takes seconds in big repos.
+var vcsOpeners = []func(dir string) (VCS, error){OpenExecGit, OpenGoGit}
+
+// OpenVCS returns the VCS of the repo holding dir.
+func OpenVCS(dir string) (VCS, error) {
+	var errs []error
+	for _, open := range vcsOpeners {
+		v, err := open(dir)
+		if err == nil {
+			return v, nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, fmt.Errorf("%s is not in a repo: %w", dir, errors.Join(errs...))
+}
+
+// GoGit reads git repos in process, without needing the git binary.
+type GoGit struct {
+	repo *git.Repository
+	root string
+	// dir is where the repo was opened, relative to root
+	dir string
+}
+
+func OpenGoGit(dir string) (VCS, error) {
+	r, err := git.PlainOpenWithOptions(dir, &git.PlainOpenOptions{DetectDotGit: true, EnableDotGitCommonDir: true})
+	if err != nil {
+		return nil, err
+	}
+	wt, err := r.Worktree()
+	if err != nil {
+		return nil, err
+	}
+
+	root := wt.Filesystem.Root()
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil {
+		return nil, err
+	}
+	return &GoGit{repo: r, root: root, dir: filepath.ToSlash(rel)}, nil
+}
+
+func (g *GoGit) Root() (string, error) {
+	return g.root, nil
+}
+
+func (g *GoGit) HooksDir() (string, error) {
+	cfg, err := g.repo.Config()
+	if err != nil {
+		return "", err
+	}
+	if hooks := cfg.Raw.Section("core").Option("hooksPath"); hooks != "" {
+		if rest, ok := strings.CutPrefix(hooks, "~/"); ok {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return "", err
+			}
+			return filepath.Join(home, rest), nil
+		}
+		if filepath.IsAbs(hooks) {
+			return hooks, nil
+		}
+		// relative to where hooks run, the top of the working tree
+		return filepath.Join(g.root, hooks), nil
+	}
+	return filepath.Join(g.commonDir(), "hooks"), nil
+}
+
+// commonDir returns the git dir shared by the worktrees of the repo. That of
+// a linked worktree, which its .git file points to, only holds what is its
+// own, and names the shared one in its commondir file.
+func (g *GoGit) commonDir() string {
+	s, ok := g.repo.Storer.(*filesystem.Storage)
+	if !ok {
+		return filepath.Join(g.root, ".git")
+	}
+	dir := s.Filesystem().Root()
+	data, err := os.ReadFile(filepath.Join(dir, "commondir"))
+	if err != nil {
+		return dir
+	}
+	common := strings.TrimSpace(string(data))
+	if !filepath.IsAbs(common) {
+		common = filepath.Join(dir, common)
+	}
+	return filepath.Clean(common)
+}
+
+func (g *GoGit) Head() (string, error) {
+	ref, err := g.repo.Head()
+	if err != nil {
+		return "", err
+	}
+	return ref.Hash().String()[:7], nil
+}
+
+// tree returns the tree of the commit ref names.
+func (g *GoGit) tree(ref string) (*object.Tree, error) {
+	h, err := g.repo.ResolveRevision(plumbing.Revision(ref))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", ref, err)
+	}
+	c, err := g.repo.CommitObject(*h)
+	if err != nil {
+		return nil, err
+	}
+	return c.Tree()
+}
+
+// path returns name, relative to the dir g was opened at, relative to root.
+func (g *GoGit) path(name string) string {
+	return path.Clean(path.Join(g.dir, filepath.ToSlash(name)))
+}
+
+// index returns the entries of the index below the dir g was opened at, by
+// their path relative to it.
+func (g *GoGit) index() (map[string]*index.Entry, error) {
+	idx, err := g.repo.Storer.Index()
+	if err != nil {
+		return nil, err
+	}
+	ret := make(map[string]*index.Entry, len(idx.Entries))
+	for _, e := range idx.Entries {
+		name := e.Name
+		if g.dir != "." {
+			var ok bool
+			if name, ok = strings.CutPrefix(name, g.dir+"/"); !ok {
+				continue
+			}
+		}
+		ret[name] = e
+	}
+	return ret, nil
+}
+
+func (g *GoGit) ListFiles(ref, dir string) ([]string, error) {
+	if ref == Index {
+		entries, err := g.index()
+		if err != nil {
+			return nil, err
+		}
+		var files []string
+		for name := range entries {
+			if path.Dir(name) == path.Clean(filepath.ToSlash(dir)) {
+				files = append(files, filepath.Join(dir, path.Base(name)))
+			}
+		}
+		slices.Sort(files)
+		return files, nil
+	}
+
+	t, err := g.tree(ref)
+	if err != nil {
+		return nil, err
+	}
+	if p := g.path(dir); p != "." {
+		if t, err = t.Tree(p); errors.Is(err, object.ErrDirectoryNotFound) {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	var files []string
+	for _, e := range t.Entries {
+		if e.Mode != filemode.Dir && e.Mode != filemode.Submodule {
+			files = append(files, filepath.Join(dir, e.Name))
+		}
+	}
+	return files, nil
+}
+
+func (g *GoGit) Files(ref string) ([]string, error) {
+	if ref == Index {
+		entries, err := g.index()
+		if err != nil {
+			return nil, err
+		}
+		var files []string
+		for name := range entries {
+			files = append(files, filepath.FromSlash(name))
+		}
+		slices.Sort(files)
+		return files, nil
+	}
+
+	t, err := g.tree(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	err = t.Files().ForEach(func(f *object.File) error {
+		name := f.Name
+		if g.dir != "." {
+			var ok bool
+			if name, ok = strings.CutPrefix(name, g.dir+"/"); !ok {
+				return nil
+			}
+		}
+		files = append(files, filepath.FromSlash(name))
+		return nil
+	})
+	return files, err
+}
+
+func (g *GoGit) ReadFile(ref, name string) (string, error) {
+	if ref == Index {
+		idx, err := g.repo.Storer.Index()
+		if err != nil {
+			return "", err
+		}
+		e, err := idx.Entry(g.path(name))
+		if err != nil {
+			return "", fmt.Errorf(":%s: %w", name, err)
+		}
+		b, err := g.repo.BlobObject(e.Hash)
+		if err != nil {
+			return "", err
+		}
+		r, err := b.Reader()
+		if err != nil {
+			return "", err
+		}
+		defer r.Close()
+		data, err := io.ReadAll(r)
+		return string(data), err
+	}
+
+	t, err := g.tree(ref)
+	if err != nil {
+		return "", err
+	}
+	f, err := t.File(g.path(name))
+	if err != nil {
+		return "", fmt.Errorf("%s:%s: %w", ref, name, err)
+	}
+	return f.Contents()
+}
+
+// Staged compares the index with the tree of HEAD, leaving the worktree
+// alone.
+func (g *GoGit) Staged() ([]string, error) {
+	idx, err := g.repo.Storer.Index()
+	if err != nil {
+		return nil, err
+	}
+	was := make(map[string]object.File)
+	// a repo without commits yet has everything staged
+	if t, err := g.tree("HEAD"); err == nil {
+		err = t.Files().ForEach(func(f *object.File) error {
+			was[f.Name] = *f
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var files []string
+	for _, e := range idx.Entries {
+		if f, ok := was[e.Name]; !ok || f.Hash != e.Hash || f.Mode != e.Mode {
+			files = append(files, filepath.FromSlash(e.Name))
+		}
+	}
+	slices.Sort(files)
+	// entries of a conflict are there once per stage
+	return slices.Compact(files), nil
+}
+
+func (g *GoGit) GoFiles() ([]string, error) {
+	idx, err := g.repo.Storer.Index()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(idx.Entries))
+	for _, e := range idx.Entries {
+		names = append(names, e.Name)
+	}
+
+	wt, err := g.repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	status, err := wt.Status()
+	if err != nil {
+		return nil, err
+	}
+	for name, s := range status {
+		if s.Worktree == git.Untracked {
+			names = append(names, name)
+		}
+	}
+
+	var files []string
+	for _, name := range names {
+		if path.Ext(name) != ".go" {
+			continue
+		}
+		if g.dir != "." {
+			var ok bool
+			if name, ok = strings.CutPrefix(name, g.dir+"/"); !ok {
+				continue
+			}
+		}
+		files = append(files, filepath.FromSlash(name))
+	}
+	slices.Sort(files)
+	return slices.Compact(files), nil
+}
+
+func (g *GoGit) Changed(ref string) ([]string, error) {
+	t, err := g.tree(ref)
+	if err != nil {
+		return nil, err
+	}
+	wt, err := g.repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+
+	was := make(map[string]plumbing.Hash)
+	err = t.Files().ForEach(func(f *object.File) error {
+		was[f.Name] = f.Hash
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	names := slices.Collect(maps.Keys(was))
+	if idx, err := g.repo.Storer.Index(); err == nil {
+		for _, e := range idx.Entries {
+			if _, ok := was[e.Name]; !ok {
+				names = append(names, e.Name)
+			}
+		}
+	}
+
+	var files []string
+	for _, name := range names {
+		rel := name
+		if g.dir != "." {
+			var ok bool
+			if rel, ok = strings.CutPrefix(name, g.dir+"/"); !ok {
+				continue
+			}
+		}
+
+		data, err := util.ReadFile(wt.Filesystem, name)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		h, ok := was[name]
+		if err != nil || !ok || plumbing.ComputeHash(plumbing.BlobObject, data) != h {
+			files = append(files, filepath.FromSlash(rel))
+		}
+	}
+	slices.Sort(files)
+	return files, nil
+}
+
+// ExecGit runs the git binary, for the repos GoGit can't read.
+type ExecGit struct {
+	Dir string
+}
+
+func OpenExecGit(dir string) (VCS, error) {
+	g := ExecGit{Dir: dir}
+	if _, err := g.Root(); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+func (g ExecGit) git(args ...string) (string, error) {
+	return Git(append([]string{"-C", g.Dir}, args...)...)
+}
+
+func (g ExecGit) Root() (string, error) {
+	return g.git("rev-parse", "--show-toplevel")
+}
+
+func (g ExecGit) HooksDir() (string, error) {
+	hooks, err := g.git("rev-parse", "--git-path", "hooks")
+	if err != nil || filepath.IsAbs(hooks) {
+		return hooks, err
+	}
+	return filepath.Join(g.Dir, hooks), nil
+}
+
+func (g ExecGit) Head() (string, error) {
+	return g.git("rev-parse", "--short", "HEAD")
+}
+
+func (g ExecGit) ListFiles(ref, dir string) ([]string, error) {
+	if ref == Index {
+		out, err := g.git("ls-files", "-z", "--", ":(glob)"+filepath.ToSlash(filepath.Join(dir, "*")))
+		return splitNUL(out), err
+	}
+	out, err := g.git("ls-tree", "-z", "--name-only", ref, "--", dir+"/")
+	return splitNUL(out), err
+}
+
+func (g ExecGit) Files(ref string) ([]string, error) {
+	if ref == Index {
+		out, err := g.git("ls-files", "-z")
+		return splitNUL(out), err
+	}
+	out, err := g.git("ls-tree", "-r", "-z", "--name-only", ref)
+	return splitNUL(out), err
+}
+
+func (g ExecGit) ReadFile(ref, name string) (string, error) {
+	return g.git("show", ref+":./"+filepath.ToSlash(name))
+}
+
+func (g ExecGit) Staged() ([]string, error) {
+	out, err := g.git("diff", "--cached", "-z", "--name-only", "--diff-filter=ACMR")
+	return splitNUL(out), err
+}
+
+func (g ExecGit) GoFiles() ([]string, error) {
+	out, err := g.git("ls-files", "-z", "--cached", "--others", "--exclude-standard", "--", "*.go")
+	return splitNUL(out), err
+}
+
+func (g ExecGit) Changed(ref string) ([]string, error) {
+	out, err := g.git("diff", "-z", "--name-only", "--relative", ref, "--")
+	return splitNUL(out), err
+}
+
+func splitNUL(s string) []string {
+	var ret []string
+	for _, e := range strings.Split(s, "\x00") {
+		if e != "" {
+			ret = append(ret, e)
+		}
+	}
+	return ret
+}
+
+// Git runs git with args and returns its trimmed output.
+func Git(args ...string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,161 @@
+package wuw
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestVCSPaths checks that both VCS backends list files with non-ASCII names
+// and spaces as they are, rather than as the quoted paths git prints by
+// default.
+func TestVCSPaths(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("no git binary")
+	}
+
+	dir := t.TempDir()
+	run := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=wuw", "-c", "user.email=wuw@example.com"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+	write := func(name, src string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	run("init", "-q")
+	write("café x/cafe.go", "package cafe\n")
+	run("add", "-A")
+	run("commit", "-q", "-m", "init")
+	write("日本/データ/data.go", "package data\n")
+	run("add", "-A")
+
+	tests := []struct {
+		name string
+		open func(dir string) (VCS, error)
+	}{
+		{"go-git", OpenGoGit},
+		{"exec", OpenExecGit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := tt.open(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			files, err := v.GoFiles()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := []string{"café x/cafe.go", "日本/データ/data.go"}; !slices.Equal(files, want) {
+				t.Errorf("GoFiles() = %q, want %q", files, want)
+			}
+
+			staged, err := v.Staged()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := []string{"日本/データ/data.go"}; !slices.Equal(staged, want) {
+				t.Errorf("Staged() = %q, want %q", staged, want)
+			}
+
+			head, err := v.ListFiles("HEAD", "café x")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := []string{"café x/cafe.go"}; !slices.Equal(head, want) {
+				t.Errorf("ListFiles(HEAD, café x) = %q, want %q", head, want)
+			}
+
+			src, err := v.ReadFile(Index, "日本/データ/data.go")
+			if err != nil {
+				t.Fatal(err)
+			}
+			// exec git trims the output of git show
+			if want := "package data"; strings.TrimSpace(src) != want {
+				t.Errorf("ReadFile(Index, 日本/データ/data.go) = %q, want %q", src, want)
+			}
+		})
+	}
+}
+
+// TestHooksDir checks where both VCS backends put hooks: in the git dir
+// shared by the worktrees of a repo, or at core.hooksPath, which is taken
+// as it is when absolute and relative to the top of the working tree when
+// not.
+func TestHooksDir(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("no git binary")
+	}
+
+	tmp, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	main, linked := filepath.Join(tmp, "main"), filepath.Join(tmp, "linked")
+	run := func(dir string, args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=wuw", "-c", "user.email=wuw@example.com"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+	if err := os.Mkdir(main, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	run(main, "init", "-q")
+	run(main, "commit", "-q", "--allow-empty", "-m", "init")
+	run(main, "worktree", "add", "-q", linked)
+
+	abs := filepath.Join(tmp, "shared-hooks")
+	tests := []struct {
+		name      string
+		hooksPath string
+		dir       string
+		want      string
+	}{
+		{"default", "", main, filepath.Join(main, ".git", "hooks")},
+		{"linked worktree", "", linked, filepath.Join(main, ".git", "hooks")},
+		{"absolute hooksPath", abs, linked, abs},
+		{"relative hooksPath", ".githooks", linked, filepath.Join(linked, ".githooks")},
+	}
+	// the cases without a hooksPath come first, as it is never unset
+	for _, tt := range tests {
+		if tt.hooksPath != "" {
+			run(main, "config", "core.hooksPath", tt.hooksPath)
+		}
+
+		for _, open := range []struct {
+			name string
+			open func(dir string) (VCS, error)
+		}{{"go-git", OpenGoGit}, {"exec", OpenExecGit}} {
+			t.Run(tt.name+"/"+open.name, func(t *testing.T) {
+				v, err := open.open(tt.dir)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := v.HooksDir()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if filepath.Clean(got) != tt.want {
+					t.Errorf("HooksDir() = %s, want %s", got, tt.want)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package wuw
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// skipDirs are dirs that never hold packages of the project, and can be big
+// enough in polyglot repos to make walking them the slowest part of a scan.
+// A trailing * matches any rest of the name, for the bazel-* output trees,
+// and external is only skipped at the root of a Bazel workspace, where it
+// holds the external repos Bazel fetched. Set with -skip-dirs.
+var skipDirs = []string{"node_modules", "vendor", "testdata", "bazel-*", "external"}
+
+// ExpandDirs replaces each arg of the form dir/... with the dirs below dir
+// that have Go files, like the go command does.
+func ExpandDirs(args []string) []string {
+	var ret []string
+	for _, a := range args {
+		root, ok := strings.CutSuffix(a, "...")
+		if !ok || (root != "" && !strings.HasSuffix(root, "/")) {
+			ret = append(ret, a)
+			continue
+		}
+		root = filepath.Clean(root + ".")
+		for _, d := range GoDirs(root) {
+			if !slices.Contains(ret, d) {
+				ret = append(ret, d)
+			}
+		}
+	}
+	return ret
+}
+
+func goFiles(root string) ([]string, error) {
+	v, err := OpenVCS(root)
+	if err != nil {
+		return nil, err
+	}
+	return v.GoFiles()
+}
+
+// GoDirs returns the dirs that have Go files in the tree at root, sorted. In
+// a repo, they are found from the index rather than by walking the tree,
+// so that the size of the non-Go parts doesn't matter.
+func GoDirs(root string) []string {
+	var files []string
+	if tracked, err := goFiles(root); err == nil {
+		for _, f := range tracked {
+			files = append(files, filepath.Join(root, f))
+		}
+	} else {
+		fs.WalkDir(OS, root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				scanCoverage.Skip(path, SkipUnreadable, err.Error())
+				return nil
+			}
+			if d.IsDir() && path != root {
+				if rule := skipRule(filepath.Dir(path), d.Name()); rule != "" {
+					scanCoverage.Skip(path, SkipIgnored, rule)
+					return fs.SkipDir
+				}
+			}
+			if !d.IsDir() && filepath.Ext(path) == ".go" {
+				files = append(files, path)
+			}
+			return nil
+		})
+	}
+
+	var dirs, ignored []string
+	for _, f := range files {
+		d := filepath.Dir(f)
+		rel, err := filepath.Rel(root, d)
+		if err != nil {
+			continue
+		}
+		if dir, rule := skipPath(root, rel); rule != "" {
+			if !slices.Contains(ignored, dir) {
+				ignored = append(ignored, dir)
+				scanCoverage.Skip(dir, SkipIgnored, rule)
+			}
+			continue
+		}
+		if !slices.Contains(dirs, d) {
+			dirs = append(dirs, d)
+		}
+	}
+	slices.Sort(dirs)
+	return dirs
+}
+
+// skipPath returns the dir, root included, that leaves the dir rel below
+// root out of dir/..., by its own name or that of a dir above it, and the
+// rule it matched. The rule is "" if rel isn't left out.
+func skipPath(root, rel string) (string, string) {
+	elems := strings.Split(rel, string(filepath.Separator))
+	for i, e := range elems {
+		parent := filepath.Join(root, filepath.Join(elems[:i]...))
+		if e == "." {
+			continue
+		}
+		if rule := skipRule(parent, e); rule != "" {
+			return filepath.Join(parent, e), rule
+		}
+	}
+	return "", ""
+}
+
+// skipRule returns the rule leaving the dir name in parent out of dir/...,
+// along with everything below it, or "" if none does. The go command
+// ignores dirs starting with . or _ too, which takes care of .cache.
+func skipRule(parent, name string) string {
+	if strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") {
+		return "starts with " + name[:1]
+	}
+	for _, s := range skipDirs {
+		prefix, wildcard := strings.CutSuffix(s, "*")
+		switch {
+		case wildcard && strings.HasPrefix(name, prefix):
+			return "-skip-dirs " + s
+		case name == s && s == "external":
+			if IsBazelWorkspace(parent) {
+				return "-skip-dirs external, in a Bazel workspace"
+			}
+		case name == s:
+			return "-skip-dirs " + s
+		}
+	}
+	return ""
+}
+
+var bazelWorkspaces = make(map[string]bool)
+
+// IsBazelWorkspace reports whether dir is the root of a Bazel workspace.
+func IsBazelWorkspace(dir string) bool {
+	if ws, ok := bazelWorkspaces[dir]; ok {
+		return ws
+	}
+	ws := slices.ContainsFunc([]string{"MODULE.bazel", "WORKSPACE", "WORKSPACE.bazel"}, func(name string) bool {
+		_, err := os.Stat(filepath.Join(dir, name))
+		return err == nil
+	})
+	bazelWorkspaces[dir] = ws
+	return ws
+}
+
+// SkipDirsFlag is the flag.Value of -skip-dirs, a comma separated list that
+// replaces skipDirs.
+type SkipDirsFlag struct{}
+
+func (SkipDirsFlag) String() string {
+	return strings.Join(skipDirs, ",")
+}
+
+func (SkipDirsFlag) Set(s string) error {
+	skipDirs = nil
+	for _, d := range strings.Split(s, ",") {
+		if d = strings.TrimSpace(d); d != "" {
+			skipDirs = append(skipDirs, d)
+		}
+	}
+	return nil
+}
+
+// Island is a part of the tree holding Go code, going by the module its
+// packages belong to.
+type Island struct {
+	Root     string
+	Module   string
+	Packages int
+}
+
+// Islands groups dirs by the module they belong to. Dirs outside of any
+// module are grouped by their dir instead.
+func Islands(dirs []string) []*Island {
+	var ret []*Island
+	byRoot := make(map[string]*Island)
+	for _, d := range dirs {
+		i := &Island{Root: d}
+		if m := FindModule(d); m != nil {
+			i = &Island{Root: m.Root, Module: m.Path}
+		}
+		if prev, ok := byRoot[i.Root]; ok {
+			i = prev
+		} else {
+			byRoot[i.Root] = i
+			ret = append(ret, i)
+		}
+		i.Packages++
+	}
+	slices.SortFunc(ret, func(a, b *Island) int { return strings.Compare(a.Root, b.Root) })
+	return ret
+}
+
+func WriteIslands(w io.Writer, islands []*Island) {
+	for _, i := range islands {
+		module := i.Module
+		if module == "" {
+			module = "(no module)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d packages\n", RelPath(i.Root), module, i.Packages)
+	}
+}
@@ -0,0 +1,114 @@
+package wuw
+
+import (
+	"cmp"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+// ModuleWeight is how many external modules building an internal package
+// drags in.
+type ModuleWeight struct {
+	Pkg string
+	// Direct are the external modules the package and the internal
+	// packages it imports, directly or not, import packages of.
+	Direct []string
+	// Modules are the module@versions Direct need, themselves included, or
+	// just Direct when the module graph couldn't be read.
+	Modules []string
+}
+
+// ModuleWeights works out the module weight of every package of g, those
+// dragging in the most modules first.
+func ModuleWeights(g *Graph) []ModuleWeight {
+	var ret []ModuleWeight
+	for _, p := range g.Order {
+		root := ""
+		mv := &ModuleVersions{}
+		if m := FindModule(g.Pkgs[p].Path); m != nil {
+			root = m.Root
+			mv = LoadModuleVersions(m.Root)
+		}
+
+		w := ModuleWeight{Pkg: p}
+		for _, q := range g.Reachable(p) {
+			for _, d := range g.Pkgs[q].Deps {
+				if g.Category(d) != CategoryExternal {
+					continue
+				}
+				if m := mv.ModuleOf(d); !slices.Contains(w.Direct, m) {
+					w.Direct = append(w.Direct, m)
+				}
+			}
+		}
+		slices.Sort(w.Direct)
+
+		for _, m := range w.Direct {
+			closure := []string{m}
+			if v := mv.Versions[m]; v != "" && root != "" {
+				if c := ModuleClosure(root, m+"@"+v); c != nil {
+					closure = c
+				}
+			}
+			for _, c := range closure {
+				if !slices.Contains(w.Modules, c) {
+					w.Modules = append(w.Modules, c)
+				}
+			}
+		}
+		slices.Sort(w.Modules)
+		ret = append(ret, w)
+	}
+
+	slices.SortStableFunc(ret, func(a, b ModuleWeight) int {
+		return cmp.Compare(len(b.Modules), len(a.Modules))
+	})
+	return ret
+}
+
+// WriteModuleWeights writes the first top of weights, or all of them if top
+// isn't positive, with the external modules each imports.
+func WriteModuleWeights(w io.Writer, weights []ModuleWeight, top int) {
+	if top > 0 && len(weights) > top {
+		weights = weights[:top]
+	}
+	for _, mw := range weights {
+		unit := "modules"
+		if len(mw.Modules) == 1 {
+			unit = "module"
+		}
+		fmt.Fprintf(w, "%5d %-7s  %s", len(mw.Modules), unit, mw.Pkg)
+		if len(mw.Direct) != 0 {
+			fmt.Fprintf(w, " (via %s)", strings.Join(mw.Direct, ", "))
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+func RunWeight(args []string) {
+	fs := flag.NewFlagSet("weight", flag.ExitOnError)
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintln(w, "'wuw weight' ranks the internal packages by their module weight: how many external modules building them drags in, through everything they import directly or not, as 'go mod graph' has it. The heaviest are where builds bloat.")
+		fmt.Fprintf(w, "Usage: %s weight [-opts] [dirs...]\nopts:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+
+	topVar := fs.Int("top", 20, "How many packages to list, or 0 for all")
+	outVar := OutputFlag(fs)
+
+	ParseFlags(fs, args)
+
+	dirs := ReadArgs(fs.Args(), fs.Usage)
+	pkgs, errs := ScanDirs(dirs, true)
+
+	PrintErrors(errs)
+
+	w := OpenOutput(*outVar)
+	defer w.Close()
+	WriteModuleWeights(w, ModuleWeights(NewGraph(pkgs)), *topVar)
+}